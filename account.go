@@ -21,6 +21,7 @@ type Account struct {
 	Description string
 	CommodityId string
 	Closed      time.Time // the date the account was closed
+	ImportIds   []string  // ids of the account in imported statements (import-id)
 
 	Commodity *Commodity
 	Parent    *Account
@@ -35,6 +36,7 @@ type Account struct {
 	OFXBankId string // obsolete; left here for backward compatibility
 	OFXAcctId string // obsolete; left here for backward compatibility
 	CSVAcctId string // obsolete; left here for backward compatibility
+	IBAN      string // obsolete; left here for backward compatibility
 	Type      string // (obsolete) used for gnucash conversion only
 	Code      string // (obsolete) used for gnucash conversion only
 
@@ -69,6 +71,14 @@ func (a *Account) Write(w io.Writer, ledger bool) error {
 	if a.CSVAcctId != "" && !ledger {
 		lines = append(lines, `  csv_acctid `, a.CSVAcctId, "\n")
 	}
+	if a.IBAN != "" && !ledger {
+		lines = append(lines, `  iban `, a.IBAN, "\n")
+	}
+	if !ledger {
+		for _, id := range a.ImportIds {
+			lines = append(lines, `  import-id `, id, "\n")
+		}
+	}
 	for _, line := range lines {
 		_, err := io.WriteString(w, line)
 		if err != nil {
@@ -88,7 +98,9 @@ var accountBodyREX = rex.MustCompile(``+
 	`(\s+closed\s+%s)|`+
 	`(\s+ofx_bankid\s+(?P<ofx_bankid>\d+))|`+
 	`(\s+ofx_acctid\s+(?P<ofx_acctid>\d+))|`+
-	`(\s+csv_acctid\s+(?P<csv_acctid>\w+))`,
+	`(\s+csv_acctid\s+(?P<csv_acctid>\w+))|`+
+	`(\s+iban\s+(?P<iban>[A-Z]{2}\d{2}[A-Z0-9]{1,30}))|`+
+	`(\s+import-id\s+(?P<import_id>[\w.-]+))`,
 	CommodityREX, DateREX)
 
 func accountFromName(fullName string) *Account {
@@ -139,11 +151,44 @@ func (p *Parser) parseAccount(fn string) (*Account, error) {
 			a.OFXAcctId = i
 		} else if i := match["csv_acctid"]; i != "" {
 			a.CSVAcctId = i
+		} else if i := match["iban"]; i != "" {
+			a.IBAN = i
+		} else if i := match["import_id"]; i != "" {
+			a.ImportIds = append(a.ImportIds, i)
 		}
 	}
 	return a, p.Err()
 }
 
+// ImportId returns the first id of the account in imported statements,
+// either from the import-id directives or the obsolete ofx_acctid, csv_acctid and iban directives.
+func (a *Account) ImportId() string {
+	if ids := a.importIds(); len(ids) > 0 {
+		return ids[0]
+	}
+	return ""
+}
+
+// HasImportId returns true if the account has the id in imported statements.
+func (a *Account) HasImportId(id string) bool {
+	for _, id2 := range a.importIds() {
+		if id2 == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *Account) importIds() (ids []string) {
+	ids = append(ids, a.ImportIds...)
+	for _, id := range []string{a.OFXAcctId, a.CSVAcctId, a.IBAN} {
+		if id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
 func (a *Account) String() string {
 	return fmt.Sprintf("%*a %-10s %s [%d]",
 		a.Balance().Width(a.Commodity.Decimals),
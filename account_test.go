@@ -30,6 +30,8 @@ account Assets:Investments:IVL:US
 	closed 2000/10/01
 	ofx_bankid 200000100
 	ofx_acctid 500766075509175102
+	iban CA12345678901234567890
+	import-id 0012-345.67
 `)
 	p := NewParser(r)
 	i, err := p.Next("")
@@ -43,6 +45,9 @@ account Assets:Investments:IVL:US
 	assert.Equal(t, a.Description, "Investorline")
 	assert.Equal(t, a.OFXAcctId, "500766075509175102")
 	assert.Equal(t, a.OFXBankId, "200000100")
+	assert.Equal(t, a.IBAN, "CA12345678901234567890")
+	assert.EqualStrings(t, a.ImportIds, "0012-345.67")
+	assert.True(t, a.HasImportId("CA12345678901234567890"))
 	assert.True(t, a.IsClosed())
 	assert.Equal(t, "2000/10/01", a.Closed.Format(DateFormat))
 }
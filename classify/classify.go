@@ -0,0 +1,142 @@
+// Package classify predicts the counter account of imported transactions
+// from the categorized transactions already in the ledger.
+// It is the fallback for transactions that didn't match any explicit import rule.
+package classify
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/mkobetic/coin"
+)
+
+// Classifier is a naive Bayes classifier of counter accounts.
+// The features of a transaction are the words of its description,
+// the sign and magnitude of its amount and its source account.
+// The classifier is trained from scratch on every run, there is no model file.
+type Classifier struct {
+	MinConfidence float64 // minimum confidence (0 to 1) of a prediction
+
+	classes  map[*coin.Account]int            // number of training transactions of each account
+	features map[*coin.Account]map[string]int // feature counts of each account
+	totals   map[*coin.Account]int            // total feature count of each account
+	known    map[string]bool                  // all features seen in training
+	samples  int
+}
+
+// New returns a classifier trained on the transactions.
+func New(transactions []*coin.Transaction, minConfidence float64) *Classifier {
+	c := &Classifier{
+		MinConfidence: minConfidence,
+		classes:       map[*coin.Account]int{},
+		features:      map[*coin.Account]map[string]int{},
+		totals:        map[*coin.Account]int{},
+		known:         map[string]bool{},
+	}
+	for _, t := range transactions {
+		c.Train(t)
+	}
+	return c
+}
+
+// Train adds the transaction to the training set.
+// Only transactions with two postings and no Unbalanced posting are used,
+// each posting is a sample with the other posting as its counter account.
+func (c *Classifier) Train(t *coin.Transaction) {
+	if len(t.Postings) != 2 {
+		return
+	}
+	for _, p := range t.Postings {
+		if p.Account == coin.Unbalanced {
+			return
+		}
+	}
+	for _, p := range t.Postings {
+		to := t.Other(p).Account
+		fs := c.features[to]
+		if fs == nil {
+			fs = map[string]int{}
+			c.features[to] = fs
+		}
+		for _, f := range Features(t.Description, p.Quantity, p.Account) {
+			fs[f]++
+			c.totals[to]++
+			c.known[f] = true
+		}
+		c.classes[to]++
+		c.samples++
+	}
+}
+
+// Predict returns the most likely counter account of the transaction posting the amount to the source account,
+// and the confidence of the prediction (the probability of the account among the candidates).
+// The candidates are open accounts with the commodity of the amount, other than the source account.
+// Returns nil if no candidate reaches MinConfidence.
+func (c *Classifier) Predict(description string, amount *coin.Amount, source *coin.Account) (*coin.Account, float64) {
+	if c == nil || c.samples == 0 {
+		return nil, 0
+	}
+	fs := Features(description, amount, source)
+	var best *coin.Account
+	var scores []float64
+	max := math.Inf(-1)
+	for a, n := range c.classes {
+		if a == source || a.Commodity != amount.Commodity || a.IsClosed() {
+			continue
+		}
+		// log probability with add-one smoothing
+		score := math.Log(float64(n) / float64(c.samples))
+		denominator := math.Log(float64(c.totals[a] + len(c.known) + 1))
+		for _, f := range fs {
+			score += math.Log(float64(c.features[a][f]+1)) - denominator
+		}
+		scores = append(scores, score)
+		if score > max || score == max && a.FullName < best.FullName {
+			max, best = score, a
+		}
+	}
+	if best == nil {
+		return nil, 0
+	}
+	var sum float64
+	for _, s := range scores {
+		sum += math.Exp(s - max)
+	}
+	confidence := 1 / sum
+	if confidence < c.MinConfidence {
+		return nil, confidence
+	}
+	return best, confidence
+}
+
+// Features returns the features of a transaction:
+// the lower case words of the description (ignoring numbers and single letters),
+// the amount sign and number of digits (e.g. -2 for -10.00 to -99.99) and the source account name.
+func Features(description string, amount *coin.Amount, source *coin.Account) (fs []string) {
+	words := strings.FieldsFunc(strings.ToLower(description), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	for _, w := range words {
+		if len(w) > 1 {
+			fs = append(fs, "w:"+w)
+		}
+	}
+	if amount != nil {
+		units := amount.Magnitude().String()
+		digits := len(units) - amount.Decimals
+		if digits < 1 {
+			digits = 1
+		}
+		sign := "+"
+		if amount.Sign() < 0 {
+			sign = "-"
+		}
+		fs = append(fs, "a:"+sign+strconv.Itoa(digits))
+	}
+	if source != nil {
+		fs = append(fs, "s:"+source.FullName)
+	}
+	return fs
+}
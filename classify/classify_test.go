@@ -0,0 +1,105 @@
+package classify
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mkobetic/coin"
+	"github.com/mkobetic/coin/assert"
+)
+
+func init() {
+	coin.Load(strings.NewReader(`
+commodity CAD
+  format 1.00 CAD
+
+account Assets:Bank:Checking
+account Liabilities:Credit:MC
+account Expenses:Groceries
+account Expenses:Phone
+account Expenses:Dining
+account Income:Salary
+
+2019/01/01 ACME PAY
+  Assets:Bank:Checking     2000.00 CAD
+  Income:Salary
+
+2019/01/03 FRESHCO #123
+  Expenses:Groceries         42.10 CAD
+  Liabilities:Credit:MC
+
+2019/01/07 FRESHCO #456
+  Expenses:Groceries         35.15 CAD
+  Liabilities:Credit:MC
+
+2019/01/09 COSTCO WHOLESALE
+  Expenses:Groceries        120.00 CAD
+  Liabilities:Credit:MC
+
+2019/01/10 ROGERS TOP UP
+  Expenses:Phone             25.00 CAD
+  Assets:Bank:Checking
+
+2019/01/12 JOE'S DINER
+  Expenses:Dining            18.50 CAD
+  Liabilities:Credit:MC
+
+2019/01/13 JOE'S DINER
+  Expenses:Dining            22.00 CAD
+  Liabilities:Credit:MC
+
+2019/01/14 MYSTERY
+  Unbalanced                 10.00 CAD
+  Liabilities:Credit:MC
+
+2019/01/15 ACME PAY
+  Assets:Bank:Checking     2000.00 CAD
+  Income:Salary
+`), "ledger.coin")
+	coin.ResolveAll()
+}
+
+func Test_Predict(t *testing.T) {
+	c := New(coin.Transactions, 0.5)
+	checking := coin.MustFindAccount("Assets:Bank:Checking")
+	mc := coin.MustFindAccount("Liabilities:Credit:MC")
+	cad := coin.Commodities["CAD"]
+	for _, fix := range []struct {
+		description string
+		amount      string
+		source      *coin.Account
+		account     string
+	}{
+		{"FRESHCO #789", "-38.00", mc, "Expenses:Groceries"},
+		{"ROGERS TOP UP", "-25.00", checking, "Expenses:Phone"},
+		{"ACME PAY", "2000.00", checking, "Income:Salary"},
+		{"JOE'S DINER", "-21.00", mc, "Expenses:Dining"},
+	} {
+		account, confidence := c.Predict(fix.description, coin.MustParseAmount(fix.amount, cad), fix.source)
+		if assert.True(t, account != nil, fix.description) {
+			assert.Equal(t, account.FullName, fix.account, fix.description)
+			assert.True(t, confidence >= 0.5 && confidence <= 1, fix.description)
+		}
+	}
+
+	c.MinConfidence = 0.99
+	account, confidence := c.Predict("SOMETHING NEW", coin.MustParseAmount("-5.00", cad), mc)
+	assert.True(t, account == nil)
+	assert.True(t, confidence < 0.99)
+}
+
+func Test_Features(t *testing.T) {
+	fs := Features("FRESHCO #123 A Street", coin.MustParseAmount("-42.10", coin.Commodities["CAD"]), coin.MustFindAccount("Liabilities:Credit:MC"))
+	assert.EqualStrings(t, fs, "w:freshco", "w:street", "a:-2", "s:Liabilities:Credit:MC")
+	fs = Features("Interest", coin.MustParseAmount("0.05", coin.Commodities["CAD"]), nil)
+	assert.EqualStrings(t, fs, "w:interest", "a:+1")
+}
+
+func Test_PredictEmpty(t *testing.T) {
+	var c *Classifier
+	account, _ := c.Predict("FRESHCO", coin.MustParseAmount("-5.00", coin.Commodities["CAD"]), nil)
+	assert.True(t, account == nil)
+	c = New(nil, 0)
+	account, _ = c.Predict("FRESHCO", coin.MustParseAmount("-5.00", coin.Commodities["CAD"]), nil)
+	assert.True(t, account == nil)
+}
@@ -0,0 +1,428 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The beancount files are parsed into an intermediate model first,
+// because commodity precision and account commodities are known
+// only after all the entries were read.
+
+type commodity struct {
+	id       string // coin id
+	name     string
+	decimals int
+	uses     int // number of postings in this commodity
+}
+
+type amount struct {
+	value     *big.Rat
+	commodity *commodity
+}
+
+type posting struct {
+	account string
+	amount  *amount // nil if elided
+	weight  *amount // amount used to balance the transaction (cost or price if specified)
+	notes   []string
+}
+
+type transaction struct {
+	payee     string
+	narration string
+	notes     []string
+	postings  []*posting
+	invalid   bool // set if any of the postings couldn't be parsed
+}
+
+// entry kinds in the order beancount processes entries of the same day
+const (
+	openEntry = iota
+	balanceEntry
+	padEntry
+	transactionEntry
+	closeEntry
+)
+
+type entry struct {
+	date       time.Time
+	kind       int
+	location   string
+	account    string
+	source     string   // pad source account
+	currencies []string // open currency constraints
+	amount     *amount  // balance
+	t          *transaction
+}
+
+type price struct {
+	date      time.Time
+	commodity *commodity
+	value     *amount
+}
+
+type beancount struct {
+	commodities map[string]*commodity // by beancount currency
+	entries     []*entry
+	prices      []*price
+	operating   string // operating currency option
+	tags        []string
+	log         io.Writer
+}
+
+func newBeancount(log io.Writer) *beancount {
+	return &beancount{
+		commodities: map[string]*commodity{},
+		log:         log,
+	}
+}
+
+func (b *beancount) warn(location string, format string, args ...interface{}) {
+	fmt.Fprintf(b.log, "%s: %s\n", location, fmt.Sprintf(format, args...))
+}
+
+func (b *beancount) readFile(fn string) error {
+	file, err := os.Open(fn)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return b.read(file, fn)
+}
+
+const (
+	numberPattern   = `[-+]?(?:\d[\d,]*(?:\.\d*)?|\.\d+)`
+	currencyPattern = `[A-Z][A-Z0-9'._-]*`
+)
+
+var (
+	tokenREX    = regexp.MustCompile(`"(?:[^"\\]|\\.)*"|;.*|\S+`)
+	metadataREX = regexp.MustCompile(`^(?P<key>[a-z][\w-]*):\s*(?P<value>.*)$`)
+	amountREX   = regexp.MustCompile(`^(?P<number>` + numberPattern + `)\s+(?P<currency>` + currencyPattern + `)$`)
+	postingREX  = regexp.MustCompile(`^([*!]\s+)?(?P<account>[^\s;]+)` +
+		`(\s+(?P<amount>` + numberPattern + `\s+` + currencyPattern + `))?` +
+		`\s*(?P<cost>\{\{?[^}]*\}\}?)?` +
+		`\s*((?P<at>@@?)\s*(?P<price>` + numberPattern + `\s+` + currencyPattern + `))?` +
+		`\s*(;.*)?$`)
+	balanceREX = regexp.MustCompile(`^(?P<account>\S+)\s+(?P<number>` + numberPattern + `)(\s+~\s+` + numberPattern + `)?\s+(?P<currency>` + currencyPattern + `)`)
+)
+
+// read parses beancount entries from r, fn is used for error locations and to resolve includes.
+// Unsupported constructs are reported to the log and skipped.
+func (b *beancount) read(r io.Reader, fn string) error {
+	scanner := bufio.NewScanner(r)
+	var lineNr int
+	var current *entry // current entry, if it can have indented lines
+	var c *commodity   // current commodity directive
+	for scanner.Scan() {
+		lineNr++
+		location := fmt.Sprintf("%s:%d", fn, lineNr)
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" {
+			current, c = nil, nil
+			continue
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			line = strings.TrimSpace(line)
+			switch {
+			case current != nil && current.t != nil:
+				b.readTransactionLine(current.t, line, location)
+			case c != nil:
+				if match := metadataREX.FindStringSubmatch(line); match != nil && match[1] == "name" {
+					c.name = unquote(match[2])
+				}
+			}
+			continue
+		}
+		current, c = nil, nil
+		if line[0] == ';' || line[0] == '*' || line[0] == '#' {
+			continue
+		}
+		tokens := tokenREX.FindAllString(line, -1)
+		if len(tokens) > 0 && strings.HasPrefix(tokens[len(tokens)-1], ";") {
+			tokens = tokens[:len(tokens)-1]
+		}
+		if line[0] < '0' || line[0] > '9' {
+			if err := b.readUndated(tokens, fn, location); err != nil {
+				return err
+			}
+			continue
+		}
+		date, err := time.Parse("2006-01-02", strings.ReplaceAll(tokens[0], "/", "-"))
+		if err != nil || len(tokens) < 2 {
+			b.warn(location, "invalid entry: %s", line)
+			continue
+		}
+		date = date.Add(12 * time.Hour) // coin dates are at noon
+		e := &entry{date: date, location: location}
+		args := tokens[2:]
+		rest := strings.TrimSpace(strings.SplitN(line, tokens[1], 2)[1])
+		switch kind := tokens[1]; kind {
+		case "open":
+			if len(args) == 0 {
+				b.warn(location, "invalid open: %s", line)
+				continue
+			}
+			e.kind, e.account = openEntry, args[0]
+			if len(args) > 1 && !strings.HasPrefix(args[1], `"`) {
+				e.currencies = strings.Split(args[1], ",")
+				for _, c := range e.currencies {
+					b.commodity(c, location)
+				}
+			}
+			b.entries = append(b.entries, e)
+		case "close":
+			if len(args) == 0 {
+				b.warn(location, "invalid close: %s", line)
+				continue
+			}
+			e.kind, e.account = closeEntry, args[0]
+			b.entries = append(b.entries, e)
+		case "commodity":
+			if len(args) == 0 {
+				b.warn(location, "invalid commodity: %s", line)
+				continue
+			}
+			c = b.commodity(args[0], location)
+		case "price":
+			if len(args) < 3 {
+				b.warn(location, "invalid price: %s", line)
+				continue
+			}
+			value := b.parseAmount(args[1]+" "+args[2], location, false)
+			if value == nil {
+				continue
+			}
+			b.prices = append(b.prices, &price{
+				date:      date,
+				commodity: b.commodity(args[0], location),
+				value:     value,
+			})
+		case "balance":
+			match := balanceREX.FindStringSubmatch(rest)
+			if match == nil {
+				b.warn(location, "invalid balance: %s", line)
+				continue
+			}
+			e.kind, e.account = balanceEntry, match[balanceREX.SubexpIndex("account")]
+			e.amount = b.parseAmount(match[balanceREX.SubexpIndex("number")]+" "+match[balanceREX.SubexpIndex("currency")], location, true)
+			if e.amount == nil {
+				continue
+			}
+			b.entries = append(b.entries, e)
+		case "pad":
+			if len(args) < 2 {
+				b.warn(location, "invalid pad: %s", line)
+				continue
+			}
+			e.kind, e.account, e.source = padEntry, args[0], args[1]
+			b.entries = append(b.entries, e)
+		case "note", "document", "event", "query", "custom":
+			b.warn(location, "ignoring %s entry", kind)
+		default:
+			if kind != "txn" && len(kind) != 1 {
+				b.warn(location, "unsupported entry: %s", kind)
+				continue
+			}
+			e.kind, e.t = transactionEntry, b.readTransaction(args)
+			current = e
+			b.entries = append(b.entries, e)
+		}
+	}
+	return scanner.Err()
+}
+
+func (b *beancount) readUndated(tokens []string, fn, location string) error {
+	directive, args := tokens[0], tokens[1:]
+	switch directive {
+	case "option":
+		if len(args) == 2 && unquote(args[0]) == "operating_currency" && b.operating == "" {
+			b.operating = unquote(args[1])
+		}
+	case "include":
+		if len(args) == 0 {
+			b.warn(location, "invalid include")
+			return nil
+		}
+		pattern := unquote(args[0])
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(fn), pattern)
+		}
+		files, err := filepath.Glob(pattern)
+		if err != nil || len(files) == 0 {
+			b.warn(location, "no files to include: %s", pattern)
+			return nil
+		}
+		for _, f := range files {
+			if err := b.readFile(f); err != nil {
+				return err
+			}
+		}
+	case "pushtag":
+		if len(args) > 0 {
+			b.tags = append(b.tags, strings.TrimPrefix(args[0], "#"))
+		}
+	case "poptag":
+		if len(args) > 0 {
+			tag := strings.TrimPrefix(args[0], "#")
+			for i := len(b.tags) - 1; i >= 0; i-- {
+				if b.tags[i] == tag {
+					b.tags = append(b.tags[:i], b.tags[i+1:]...)
+					break
+				}
+			}
+		}
+	default:
+		b.warn(location, "ignoring %s directive", directive)
+	}
+	return nil
+}
+
+// readTransaction parses the transaction header, the payee becomes the description,
+// narration, tags and links become notes.
+func (b *beancount) readTransaction(args []string) *transaction {
+	t := &transaction{}
+	var strs []string
+	var tags []string
+	for _, a := range args {
+		switch {
+		case strings.HasPrefix(a, `"`):
+			strs = append(strs, unquote(a))
+		case strings.HasPrefix(a, "#"), strings.HasPrefix(a, "^"):
+			tags = append(tags, "#"+a[1:])
+		}
+	}
+	for _, tag := range b.tags {
+		tags = append(tags, "#"+tag)
+	}
+	switch len(strs) {
+	case 1:
+		t.payee = strs[0]
+	case 2:
+		t.payee, t.narration = strs[0], strs[1]
+		if t.payee == "" {
+			t.payee, t.narration = t.narration, ""
+		}
+	}
+	if t.narration != "" {
+		t.notes = append(t.notes, t.narration)
+	}
+	if len(tags) > 0 {
+		t.notes = append(t.notes, strings.Join(tags, " "))
+	}
+	return t
+}
+
+func (b *beancount) readTransactionLine(t *transaction, line string, location string) {
+	var note string
+	switch {
+	case line[0] == ';':
+		note = strings.TrimSpace(line[1:])
+	case metadataREX.MatchString(line):
+		match := metadataREX.FindStringSubmatch(line)
+		note = "#" + match[1] + ": " + unquote(match[2])
+	}
+	if note != "" {
+		if len(t.postings) == 0 {
+			t.notes = append(t.notes, note)
+		} else {
+			s := t.postings[len(t.postings)-1]
+			s.notes = append(s.notes, note)
+		}
+		return
+	}
+	match := postingREX.FindStringSubmatch(line)
+	if match == nil {
+		b.warn(location, "invalid posting: %s", line)
+		t.invalid = true
+		return
+	}
+	s := &posting{account: match[postingREX.SubexpIndex("account")]}
+	if i := strings.Index(line, ";"); i >= 0 {
+		s.notes = append(s.notes, strings.TrimSpace(line[i+1:]))
+	}
+	if amt := match[postingREX.SubexpIndex("amount")]; amt != "" {
+		if s.amount = b.parseAmount(amt, location, true); s.amount == nil {
+			t.invalid = true
+			return
+		}
+		s.amount.commodity.uses++
+		s.weight = s.amount
+	}
+	if p := match[postingREX.SubexpIndex("price")]; p != "" && s.amount != nil {
+		s.weight = b.parseAmount(p, location, false)
+		if s.weight != nil && match[postingREX.SubexpIndex("at")] == "@" {
+			s.weight.value.Mul(s.weight.value, s.amount.value)
+		}
+	}
+	if cost := match[postingREX.SubexpIndex("cost")]; cost != "" {
+		b.warn(location, "cost basis is not supported: %s", cost)
+		total := strings.HasPrefix(cost, "{{")
+		cost, _, _ = strings.Cut(strings.Trim(cost, "{}"), ",")
+		if cost = strings.TrimSpace(cost); cost != "" && s.amount != nil {
+			if s.weight = b.parseAmount(cost, location, false); s.weight != nil && !total {
+				s.weight.value.Mul(s.weight.value, s.amount.value)
+			}
+		} else if s.amount != nil {
+			b.warn(location, "cannot balance posting with empty cost")
+			t.invalid = true
+			return
+		}
+	}
+	if s.weight != nil && s.amount != nil && s.weight.value.Sign() != s.amount.value.Sign() {
+		s.weight.value.Neg(s.weight.value)
+	}
+	t.postings = append(t.postings, s)
+}
+
+// parseAmount parses beancount amounts like -1,000.00 USD.
+// If precision is set, the commodity decimals are updated to accommodate the amount.
+func (b *beancount) parseAmount(s string, location string, precision bool) *amount {
+	match := amountREX.FindStringSubmatch(strings.TrimSpace(s))
+	if match == nil {
+		b.warn(location, "invalid amount: %s", s)
+		return nil
+	}
+	number := strings.TrimPrefix(strings.ReplaceAll(match[1], ",", ""), "+")
+	value, ok := new(big.Rat).SetString(number)
+	if !ok {
+		b.warn(location, "invalid amount: %s", s)
+		return nil
+	}
+	c := b.commodity(match[2], location)
+	if _, decimals, ok := strings.Cut(number, "."); ok && precision && len(decimals) > c.decimals {
+		c.decimals = len(decimals)
+	}
+	return &amount{value, c}
+}
+
+var invalidIdREX = regexp.MustCompile(`\W+`)
+
+func (b *beancount) commodity(currency string, location string) *commodity {
+	if c := b.commodities[currency]; c != nil {
+		return c
+	}
+	id := invalidIdREX.ReplaceAllString(currency, "_")
+	if id != currency {
+		b.warn(location, "commodity %s is converted to %s", currency, id)
+	}
+	c := &commodity{id: id}
+	b.commodities[currency] = c
+	return c
+}
+
+func unquote(s string) string {
+	if u, err := strconv.Unquote(s); err == nil {
+		return u
+	}
+	return s
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/mkobetic/coin"
+	"github.com/mkobetic/coin/convert"
+)
+
+// converter keeps the state of the conversion, the entries are processed in chronological order
+// to be able to resolve pad and balance entries.
+type converter struct {
+	b           *beancount
+	l           *convert.Ledger
+	commodities map[string]*coin.Commodity
+	subAccounts map[string][]*coin.Account // beancount account => coin accounts
+	balances    map[*coin.Account]*coin.Amount
+	last        map[*coin.Account]*coin.Posting // last posting of the account
+	pads        map[string]*entry               // pending pad entries by account
+}
+
+// convert maps the beancount entries to coin objects.
+// Coin accounts hold a single commodity, postings in other commodities
+// are moved to sub-accounts named after the commodity.
+func (b *beancount) convert() *convert.Ledger {
+	cv := &converter{
+		b:           b,
+		l:           &convert.Ledger{},
+		commodities: map[string]*coin.Commodity{},
+		subAccounts: map[string][]*coin.Account{},
+		balances:    map[*coin.Account]*coin.Amount{},
+		last:        map[*coin.Account]*coin.Posting{},
+		pads:        map[string]*entry{},
+	}
+	var defaultC *commodity
+	for _, c := range b.commodities {
+		cc := &coin.Commodity{Id: c.id, Name: c.name, Decimals: c.decimals}
+		cv.commodities[c.id] = cc
+		cv.l.Commodities = append(cv.l.Commodities, cc)
+		if defaultC == nil || c.uses > defaultC.uses || (c.uses == defaultC.uses && c.id < defaultC.id) {
+			defaultC = c
+		}
+	}
+	sort.Slice(cv.l.Commodities, func(i, j int) bool {
+		return cv.l.Commodities[i].Id < cv.l.Commodities[j].Id
+	})
+	if c := b.commodities[b.operating]; c != nil {
+		defaultC = c
+	}
+	if defaultC != nil {
+		cv.l.Default = cv.commodities[defaultC.id]
+	}
+
+	sort.SliceStable(b.entries, func(i, j int) bool {
+		ei, ej := b.entries[i], b.entries[j]
+		return ei.date.Before(ej.date) || (ei.date.Equal(ej.date) && ei.kind < ej.kind)
+	})
+	for _, e := range b.entries {
+		switch e.kind {
+		case openEntry:
+			cv.open(e)
+		case closeEntry:
+			if len(cv.subAccounts[e.account]) == 0 && cv.l.Default != nil {
+				cv.accountFor(e.account, cv.l.Default, e.location)
+			}
+			for _, a := range cv.subAccounts[e.account] {
+				a.Closed = e.date
+			}
+		case padEntry:
+			cv.pads[e.account] = e
+		case balanceEntry:
+			cv.balance(e)
+		case transactionEntry:
+			cv.transaction(e)
+		}
+	}
+	// opened accounts without postings
+	for _, e := range b.entries {
+		if e.kind == openEntry && len(cv.subAccounts[e.account]) == 0 && cv.l.Default != nil {
+			cv.accountFor(e.account, cv.l.Default, e.location)
+		}
+	}
+	sort.SliceStable(cv.l.Transactions, func(i, j int) bool {
+		return cv.l.Transactions[i].Posted.Before(cv.l.Transactions[j].Posted)
+	})
+	sort.Slice(cv.l.Accounts, func(i, j int) bool {
+		return cv.l.Accounts[i].FullName < cv.l.Accounts[j].FullName
+	})
+
+	sort.SliceStable(b.prices, func(i, j int) bool {
+		return b.prices[i].date.Before(b.prices[j].date)
+	})
+	for _, p := range b.prices {
+		currency := cv.commodities[p.value.commodity.id]
+		cv.l.Prices = append(cv.l.Prices, &coin.Price{
+			Commodity: cv.commodities[p.commodity.id],
+			Currency:  currency,
+			Value:     convert.Round(p.value.value, currency),
+			Time:      p.date,
+		})
+	}
+	return cv.l
+}
+
+func (cv *converter) open(e *entry) {
+	if len(e.currencies) > 1 {
+		cv.b.warn(e.location, "multi-commodity account %s, postings in %s are moved to sub-accounts",
+			e.account, strings.Join(e.currencies[1:], ","))
+	}
+	if len(e.currencies) > 0 {
+		cv.accountFor(e.account, cv.commodities[cv.b.commodities[e.currencies[0]].id], e.location)
+	}
+}
+
+// accountFor returns the coin account for the beancount account name and commodity.
+func (cv *converter) accountFor(name string, c *coin.Commodity, location string) *coin.Account {
+	for _, a := range cv.subAccounts[name] {
+		if a.Commodity == c {
+			return a
+		}
+	}
+	fullName := convert.AccountName(name)
+	if len(cv.subAccounts[name]) > 0 {
+		fullName += ":" + c.Id
+		cv.b.warn(location, "%s postings in %s are moved to %s", c.Id, name, fullName)
+	}
+	a := &coin.Account{
+		Name:        fullName[strings.LastIndex(fullName, ":")+1:],
+		FullName:    fullName,
+		CommodityId: c.Id,
+		Commodity:   c,
+	}
+	cv.subAccounts[name] = append(cv.subAccounts[name], a)
+	cv.l.Accounts = append(cv.l.Accounts, a)
+	return a
+}
+
+func (cv *converter) post(t *coin.Transaction, a *coin.Account, quantity *coin.Amount, notes []string) *coin.Posting {
+	s := &coin.Posting{
+		Transaction: t,
+		Account:     a,
+		Quantity:    quantity,
+		Notes:       notes,
+	}
+	t.Postings = append(t.Postings, s)
+	if cv.balances[a] == nil {
+		cv.balances[a] = coin.NewZeroAmount(a.Commodity)
+	}
+	cv.balances[a].AddIn(quantity)
+	cv.last[a] = s
+	return s
+}
+
+func (cv *converter) transaction(e *entry) {
+	t := e.t
+	if t.invalid || len(t.postings) == 0 {
+		cv.b.warn(e.location, "skipping transaction")
+		return
+	}
+	var elided *posting
+	totals := map[*commodity]*big.Rat{}
+	var order []*commodity
+	for _, s := range t.postings {
+		switch {
+		case s.amount == nil && elided != nil:
+			cv.b.warn(e.location, "skipping transaction with multiple postings without amount")
+			return
+		case s.amount == nil:
+			elided = s
+			continue
+		}
+		if totals[s.weight.commodity] == nil {
+			totals[s.weight.commodity] = new(big.Rat)
+			order = append(order, s.weight.commodity)
+		}
+		totals[s.weight.commodity].Add(totals[s.weight.commodity], s.weight.value)
+		if s.weight != s.amount {
+			cv.b.prices = append(cv.b.prices, &price{
+				date:      e.date,
+				commodity: s.amount.commodity,
+				value:     &amount{new(big.Rat).Quo(s.weight.value, s.amount.value), s.weight.commodity},
+			})
+		}
+	}
+	ct := &coin.Transaction{
+		Posted:      e.date,
+		Description: t.payee,
+		Notes:       t.notes,
+	}
+	for _, s := range t.postings {
+		if s != elided {
+			c := cv.commodities[s.amount.commodity.id]
+			cv.post(ct, cv.accountFor(s.account, c, e.location), convert.Round(s.amount.value, c), s.notes)
+			continue
+		}
+		for _, bc := range order {
+			if totals[bc].Sign() == 0 {
+				continue
+			}
+			c := cv.commodities[bc.id]
+			cv.post(ct, cv.accountFor(s.account, c, e.location), convert.Round(new(big.Rat).Neg(totals[bc]), c), s.notes)
+		}
+	}
+	cv.l.Transactions = append(cv.l.Transactions, ct)
+}
+
+// balance converts the balance entry to a balance assertion on the last posting of the account.
+// Pending pad entry for the account is resolved with a padding transaction.
+func (cv *converter) balance(e *entry) {
+	c := cv.commodities[e.amount.commodity.id]
+	a := cv.accountFor(e.account, c, e.location)
+	target := convert.Round(e.amount.value, c)
+	balance := cv.balances[a]
+	if balance == nil {
+		balance = coin.NewZeroAmount(c)
+	}
+	if pad := cv.pads[e.account]; pad != nil {
+		delete(cv.pads, e.account)
+		diff := target.Copy()
+		diff.AddIn(balance.Negated())
+		if !diff.IsZero() {
+			t := &coin.Transaction{Posted: pad.date, Description: "Padding"}
+			s := cv.post(t, a, diff, nil)
+			s.Balance, s.BalanceAsserted = target, true
+			cv.post(t, cv.accountFor(pad.source, c, pad.location), diff.Negated(), nil)
+			cv.l.Transactions = append(cv.l.Transactions, t)
+			return
+		}
+	}
+	s := cv.last[a]
+	switch {
+	case s == nil && target.IsZero():
+	case s == nil:
+		cv.b.warn(e.location, "cannot assert balance of %s without postings", e.account)
+	case s.BalanceAsserted:
+		if !s.Balance.IsEqual(target) {
+			cv.b.warn(e.location, "conflicting balance assertion for %s", e.account)
+		}
+	default:
+		if !balance.IsEqual(target) {
+			cv.b.warn(e.location, "balance of %s is %a, should be %a", e.account, balance, target)
+		}
+		s.Balance, s.BalanceAsserted = target, true
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/mkobetic/coin"
+	"github.com/mkobetic/coin/check"
+)
+
+const usage = `Usage: bean2coin [flags] files...
+
+Converts beancount files to a coin database.
+Constructs that coin can't represent are reported with their location.
+
+Flags:`
+
+var (
+	yearly = flag.Bool("y", false, "split transactions into separate files by year (requires COINDB directory)")
+)
+
+func init() {
+	flag.Usage = func() {
+		w := flag.CommandLine.Output()
+		fmt.Fprintln(w, usage)
+		flag.PrintDefaults()
+	}
+}
+
+func main() {
+	flag.Parse()
+	if flag.NArg() == 0 {
+		fmt.Println("missing beancount file")
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *yearly && coin.DB == "" {
+		fmt.Println("-y requires $COINDB set")
+		flag.Usage()
+		os.Exit(1)
+	}
+	b := newBeancount(os.Stderr)
+	for _, fn := range flag.Args() {
+		check.NoError(b.readFile(fn), "reading %s", fn)
+	}
+	b.convert().Save(*yearly)
+}
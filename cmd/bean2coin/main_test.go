@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/mkobetic/coin"
+	"github.com/mkobetic/coin/assert"
+)
+
+const sample = `; sample ledger
+option "operating_currency" "USD"
+plugin "beancount.plugins.auto_accounts"
+
+2020-01-01 commodity USD
+  name: "US Dollar"
+
+2020-01-01 open Assets:Checking USD
+2020-01-01 open Assets:Broker USD,VTI "FIFO"
+2020-01-01 open Equity:Opening-Balances
+2020-01-01 open Expenses:Food
+2020-01-01 open Assets:Old USD
+
+2020-01-01 pad Assets:Checking Equity:Opening-Balances
+2020-01-02 balance Assets:Checking 1000.00 USD
+
+pushtag #trip
+2020-01-05 * "Grocery Store" "weekly groceries" #food ^receipt-123
+  category: "groceries"
+  Expenses:Food  45.67 USD ; inline
+    item: "bread"
+  Assets:Checking
+poptag #trip
+
+2020-01-10 * "Buy VTI"
+  Assets:Broker  10 VTI {150.00 USD}
+  Assets:Checking  -1,500.00 USD
+
+2020-01-11 balance Assets:Checking  -545.67 USD
+2020-01-12 price VTI 155.00 USD
+2020-01-12 note Assets:Broker "called the broker"
+2020-01-15 close Assets:Old
+`
+
+func Test_Convert(t *testing.T) {
+	var log bytes.Buffer
+	b := newBeancount(&log)
+	assert.NoError(t, b.read(strings.NewReader(sample), "sample"))
+	assert.EqualStrings(t, strings.Split(strings.TrimSpace(log.String()), "\n"),
+		"sample:3: ignoring plugin directive",
+		"sample:26: cost basis is not supported: {150.00 USD}",
+		"sample:31: ignoring note entry",
+	)
+	log.Reset()
+	l := b.convert()
+	assert.EqualStrings(t, strings.Split(strings.TrimSpace(log.String()), "\n"),
+		"sample:9: multi-commodity account Assets:Broker, postings in VTI are moved to sub-accounts",
+		"sample:25: VTI postings in Assets:Broker are moved to Assets:Broker:VTI",
+	)
+
+	var w bytes.Buffer
+	l.WriteCommodities(&w)
+	l.WriteAccounts(&w)
+	l.WritePrices(&w, l.Prices)
+	l.WriteTransactions(&w, l.Transactions)
+	assert.Equal(t, w.String(), `commodity USD
+  note US Dollar
+  format 1.00 USD
+  default
+
+commodity VTI
+  format 1 VTI
+
+account Assets:Broker
+  commodity USD
+
+account Assets:Broker:VTI
+  commodity VTI
+
+account Assets:Checking
+  commodity USD
+
+account Assets:Old
+  commodity USD
+  closed 2020/01/15
+
+account Equity:Opening-Balances
+  commodity USD
+
+account Expenses:Food
+  commodity USD
+
+P 2020/01/10 VTI 150.00 USD
+P 2020/01/12 VTI 155.00 USD
+2020/01/01 Padding
+  Assets:Checking           1000.00 USD = 1000.00 USD
+  Equity:Opening-Balances  -1000.00 USD
+
+2020/01/05 Grocery Store ; weekly groceries
+  ; #food #receipt-123 #trip
+  ; #category: groceries
+  Expenses:Food     45.67 USD ; inline
+    ; #item: bread
+  Assets:Checking  -45.67 USD
+
+2020/01/10 Buy VTI
+  Assets:Broker:VTI        10 VTI
+  Assets:Checking    -1500.00 USD = -545.67 USD
+
+`)
+
+	coin.Load(&w, "converted")
+	coin.ResolveAll()
+	assert.Equal(t, len(coin.Transactions), 3)
+	assert.Equal(t, coin.AccountsByName["Assets:Checking"].Balance().String(), "-545.67")
+}
@@ -0,0 +1,433 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/mkobetic/coin"
+	"github.com/mkobetic/coin/check"
+	"github.com/mkobetic/coin/dedup"
+	"github.com/mkobetic/coin/importer"
+)
+
+func init() {
+	(&cmdCategorize{}).newCommand("categorize", "cat")
+}
+
+type cmdCategorize struct {
+	flagsWithUsage
+	apply      string
+	emitRules  bool
+	similarity float64
+
+	rules *coin.RuleIndex
+}
+
+func (*cmdCategorize) newCommand(names ...string) command {
+	var cmd cmdCategorize
+	cmd.FlagSet = newCommand(&cmd, names...)
+	setUsage(cmd.FlagSet, `(categorize|cat) [flags]
+
+Propose accounts for the postings to Unbalanced, the proposals are written as a decisions file:
+
+  location date account ; description | amount | reason
+
+Review the decisions (fix the accounts, delete the lines that should not be applied)
+and apply them with -apply, the files are rewritten in place.`)
+	cmd.StringVar(&cmd.apply, "apply", "", "apply the decisions from this file")
+	cmd.BoolVar(&cmd.emitRules, "rules", false, "with -apply print ofx.rules lines for the applied decisions")
+	cmd.Float64Var(&cmd.similarity, "similarity", 0.5, "minimum similarity (0 to 1) of similar descriptions")
+	return &cmd
+}
+
+func (cmd *cmdCategorize) init() {
+	if cmd.apply == "" {
+		coin.LoadAll()
+	} else {
+		coin.LoadFile(coin.CommoditiesFile)
+		coin.LoadFile(coin.AccountsFile)
+		coin.ResolveAccounts()
+		coin.ResolveRules()
+	}
+	var err error
+	cmd.rules, err = importer.LoadRules("ofx.rules")
+	check.NoError(err, "Failed to load rules")
+}
+
+func (cmd *cmdCategorize) execute(f io.Writer) {
+	if cmd.apply != "" {
+		file, err := os.Open(cmd.apply)
+		check.NoError(err, "Failed to open %s", cmd.apply)
+		defer file.Close()
+		decisions, err := readDecisions(file)
+		check.NoError(err, "Failed to read %s", cmd.apply)
+		applied := cmd.applyDecisions(decisions)
+		if cmd.emitRules {
+			cmd.writeRules(f, applied)
+		}
+		return
+	}
+	h := newHistory(coin.Transactions)
+	for _, t := range coin.Transactions {
+		u, s := unbalancedPosting(t)
+		if u == nil {
+			continue
+		}
+		var account *coin.Account
+		var reason string
+		if s == nil {
+			reason = "split transaction"
+		} else {
+			account, reason = cmd.propose(h, t, s)
+		}
+		prefix := ""
+		if account == nil {
+			account = coin.Unbalanced
+			prefix = "; "
+		}
+		fmt.Fprintf(f, "%s%s %s %s ; %s | %a %s | %s\n",
+			prefix,
+			t.Location(),
+			t.Posted.Format(coin.DateFormat),
+			account.FullName,
+			t.Description,
+			u.Quantity.Negated(), u.Quantity.Commodity.Id,
+			reason)
+	}
+}
+
+// unbalancedPosting returns the Unbalanced posting of the transaction,
+// and the other posting if there are only two postings.
+func unbalancedPosting(t *coin.Transaction) (u, s *coin.Posting) {
+	for _, p := range t.Postings {
+		if p.Account == coin.Unbalanced {
+			u = p
+		}
+	}
+	if u != nil && len(t.Postings) == 2 {
+		s = t.Other(u)
+	}
+	return u, s
+}
+
+// propose returns the proposed counter account of the posting s of the transaction with the reason,
+// from the matching rules, the same payee past transactions or similar descriptions, in that order.
+func (cmd *cmdCategorize) propose(h *history, t *coin.Transaction, s *coin.Posting) (*coin.Account, string) {
+	if ars := cmd.accountRules(s.Account); ars != nil {
+		rule := ars.RuleForInput(&coin.RuleInput{
+			Payee:   t.Description,
+			Amount:  ratOf(s.Quantity),
+			Posted:  t.Posted,
+			Account: s.Account,
+		})
+		if rule != nil && rule.Account != nil {
+			return rule.Account, "rule " + rule.String()
+		}
+	}
+	if account, n, total := h.payee(s.Account, t.Description); account != nil {
+		return account, fmt.Sprintf("payee %d/%d", n, total)
+	}
+	if account, similarity, description := h.similar(s.Account, t.Description, cmd.similarity); account != nil {
+		return account, fmt.Sprintf("similar %.2f %s", similarity, description)
+	}
+	return nil, "no proposal"
+}
+
+func ratOf(a *coin.Amount) *big.Rat {
+	return new(big.Rat).SetFrac(a.Int, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(a.Decimals)), nil))
+}
+
+// accountRules returns the rules for the account or nil.
+func (cmd *cmdCategorize) accountRules(a *coin.Account) *coin.AccountRules {
+	if cmd.rules == nil {
+		return nil
+	}
+	for _, ars := range cmd.rules.Accounts {
+		if ars.Account == a {
+			return ars
+		}
+	}
+	return nil
+}
+
+// history indexes the categorized transactions with two postings by their accounts.
+type history struct {
+	payees  map[payeeKey]map[*coin.Account]int
+	samples map[*coin.Account][]sample
+}
+
+type payeeKey struct {
+	account *coin.Account
+	payee   string
+}
+
+type sample struct {
+	description string
+	account     *coin.Account
+}
+
+func newHistory(transactions []*coin.Transaction) *history {
+	h := &history{
+		payees:  map[payeeKey]map[*coin.Account]int{},
+		samples: map[*coin.Account][]sample{},
+	}
+	for _, t := range transactions {
+		if len(t.Postings) != 2 {
+			continue
+		}
+		p1, p2 := t.Postings[0], t.Postings[1]
+		if p1.Account == coin.Unbalanced || p2.Account == coin.Unbalanced {
+			continue
+		}
+		for _, ps := range [][2]*coin.Posting{{p1, p2}, {p2, p1}} {
+			key := payeeKey{ps[0].Account, payeeOf(t.Description)}
+			counts := h.payees[key]
+			if counts == nil {
+				counts = map[*coin.Account]int{}
+				h.payees[key] = counts
+			}
+			counts[ps[1].Account]++
+			h.samples[ps[0].Account] = append(h.samples[ps[0].Account], sample{t.Description, ps[1].Account})
+		}
+	}
+	return h
+}
+
+// payee returns the most frequent counter account of the account transactions with the same payee,
+// with its count and the total count.
+func (h *history) payee(account *coin.Account, description string) (best *coin.Account, n, total int) {
+	payee := payeeOf(description)
+	if payee == "" {
+		return nil, 0, 0
+	}
+	for a, c := range h.payees[payeeKey{account, payee}] {
+		total += c
+		if c > n || c == n && a.FullName < best.FullName {
+			best, n = a, c
+		}
+	}
+	return best, n, total
+}
+
+// similar returns the counter account of the account transaction with the most similar description,
+// if the similarity is at least min.
+func (h *history) similar(account *coin.Account, description string, min float64) (best *coin.Account, similarity float64, bestDescription string) {
+	for _, s := range h.samples[account] {
+		if sim := dedup.Similarity(description, s.description); sim >= min && sim > similarity {
+			best, similarity, bestDescription = s.account, sim, s.description
+		}
+	}
+	return best, similarity, bestDescription
+}
+
+// payeeOf returns the payee part of the description, the words before the first word with a digit or #,
+// e.g. `FRESHCO #123 TORONTO` => `FRESHCO`.
+func payeeOf(description string) string {
+	var words []string
+	for _, w := range strings.Fields(description) {
+		if strings.IndexFunc(w, func(r rune) bool { return unicode.IsDigit(r) || r == '#' }) >= 0 {
+			break
+		}
+		words = append(words, w)
+	}
+	return strings.Join(words, " ")
+}
+
+// decision moves the Unbalanced posting of the transaction at the location to the account.
+type decision struct {
+	file    string
+	line    int
+	date    string
+	account string
+}
+
+func (d *decision) location() string {
+	return d.file + ":" + strconv.Itoa(d.line)
+}
+
+var decisionREX = regexp.MustCompile(`^(\S+):(\d+)\s+(\S+)\s+(\S+)`)
+
+// readDecisions reads the decisions file, empty lines and lines starting with ; are ignored.
+func readDecisions(r io.Reader) (decisions []*decision, err error) {
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, ";") {
+			continue
+		}
+		match := decisionREX.FindStringSubmatch(line)
+		if match == nil {
+			return nil, fmt.Errorf("invalid decision on line %d: %s", n, line)
+		}
+		ln, _ := strconv.Atoi(match[2])
+		decisions = append(decisions, &decision{file: match[1], line: ln, date: match[3], account: match[4]})
+	}
+	return decisions, s.Err()
+}
+
+// categorized is an applied decision, from is the statement account (nil for split transactions).
+type categorized struct {
+	description string
+	from, to    *coin.Account
+}
+
+// applyDecisions moves the Unbalanced postings and rewrites the files,
+// returns the applied decisions.
+func (cmd *cmdCategorize) applyDecisions(decisions []*decision) (applied []*categorized) {
+	var files []string
+	byLocation := map[string]*decision{}
+	for _, d := range decisions {
+		if _, ok := byLocation[d.location()]; ok {
+			continue
+		}
+		byLocation[d.location()] = d
+		files = append(files, d.file)
+	}
+	sort.Strings(files)
+	for i, fn := range files {
+		if i > 0 && files[i-1] == fn {
+			continue
+		}
+		applied = append(applied, cmd.applyFile(fn, byLocation)...)
+	}
+	return applied
+}
+
+// applyFile applies the decisions to the transactions of the file and rewrites the updated transactions in place,
+// the rest of the file (prices, rules, comments, includes, ...) is kept as is.
+func (cmd *cmdCategorize) applyFile(fn string, byLocation map[string]*decision) (applied []*categorized) {
+	content, err := os.ReadFile(fn)
+	check.NoError(err, "reading %s", fn)
+	// only the transactions are needed, loading the file would also follow its includes
+	parser := coin.NewParser(bytes.NewReader(content))
+	for {
+		i, err := parser.Next(fn)
+		check.NoError(err, "parsing %s", fn)
+		if i == nil {
+			break
+		}
+		if t, ok := i.(*coin.Transaction); ok {
+			coin.Transactions = append(coin.Transactions, t)
+		}
+	}
+	coin.ResolveTransactions(false)
+	defer coin.DropTransactions()
+	updated := map[int]*coin.Transaction{}
+	for _, t := range coin.Transactions {
+		if d := byLocation[t.Location()]; d != nil {
+			if p := cmd.applyDecision(d, t); p != nil {
+				c := &categorized{description: t.Description, to: p.Account}
+				if len(t.Postings) == 2 {
+					c.from = t.Other(p).Account
+				}
+				applied = append(applied, c)
+				updated[d.line] = t
+			}
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Updated %d transactions in %s\n", len(updated), fn)
+	if len(updated) == 0 {
+		return applied
+	}
+	tf, err := os.CreateTemp(path.Dir(fn), path.Base(fn))
+	check.NoError(err, "creating temp file")
+	lines := strings.SplitAfter(string(content), "\n")
+	for i := 0; i < len(lines); i++ {
+		t := updated[i+1]
+		if t == nil {
+			_, err = io.WriteString(tf, lines[i])
+			check.NoError(err, "writing %s", tf.Name())
+			continue
+		}
+		check.NoError(t.Write(tf, false), "writing %s", tf.Name())
+		// skip the posting lines of the original transaction
+		for i+1 < len(lines) && isIndented(lines[i+1]) {
+			i++
+		}
+	}
+	check.NoError(tf.Close(), "closing %s", tf.Name())
+	err = os.Remove(fn)
+	check.NoError(err, "deleting old file")
+	err = os.Rename(tf.Name(), fn)
+	check.NoError(err, "renaming temp file")
+	return applied
+}
+
+// isIndented returns true for non-blank lines starting with white space, e.g. transaction postings.
+func isIndented(line string) bool {
+	return len(line) > 0 && (line[0] == ' ' || line[0] == '\t') && strings.TrimSpace(line) != ""
+}
+
+// applyDecision moves the Unbalanced posting of the transaction, returns the moved posting or nil.
+func (cmd *cmdCategorize) applyDecision(d *decision, t *coin.Transaction) *coin.Posting {
+	if t.Posted.Format(coin.DateFormat) != d.date {
+		fmt.Fprintf(os.Stderr, "Skipping %s, transaction date %s doesn't match %s\n",
+			d.location(), t.Posted.Format(coin.DateFormat), d.date)
+		return nil
+	}
+	u, _ := unbalancedPosting(t)
+	if u == nil {
+		fmt.Fprintf(os.Stderr, "Skipping %s, transaction has no Unbalanced posting\n", d.location())
+		return nil
+	}
+	if d.account == coin.Unbalanced.FullName {
+		return nil
+	}
+	u.MoveTo(coin.MustFindAccount(d.account))
+	return u
+}
+
+// writeRules prints the rules for the applied decisions that don't match any existing rule,
+// grouped by the import id of the statement accounts (see coin.Account.ImportId), the lines can be appended to ofx.rules.
+func (cmd *cmdCategorize) writeRules(f io.Writer, applied []*categorized) {
+	type group struct {
+		header string
+		lines  []string
+	}
+	var groups []*group
+	byAccount := map[*coin.Account]*group{}
+	seen := map[string]bool{}
+	for _, c := range applied {
+		if c.from == nil {
+			continue
+		}
+		id := c.from.ImportId()
+		payee := payeeOf(c.description)
+		if id == "" || payee == "" {
+			continue
+		}
+		if ars := cmd.accountRules(c.from); ars != nil {
+			if rule := ars.RuleFor(c.description); rule != nil && rule.Account == c.to {
+				continue
+			}
+		}
+		line := fmt.Sprintf("  %s  %s", c.to.FullName, regexp.QuoteMeta(payee))
+		if seen[id+line] {
+			continue
+		}
+		seen[id+line] = true
+		g := byAccount[c.from]
+		if g == nil {
+			g = &group{header: id + " " + c.from.FullName}
+			byAccount[c.from] = g
+			groups = append(groups, g)
+		}
+		g.lines = append(g.lines, line)
+	}
+	for _, g := range groups {
+		fmt.Fprintln(f, g.header)
+		for _, l := range g.lines {
+			fmt.Fprintln(f, l)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mkobetic/coin"
+	"github.com/mkobetic/coin/assert"
+)
+
+func Test_ReadDecisions(t *testing.T) {
+	decisions, err := readDecisions(strings.NewReader(`
+/db/2000.coin:26 2000/02/03 Expenses:Groceries ; FRESHCO #789 | -35.00 CAD | payee 2/2
+; /db/2000.coin:30 2000/02/07 Unbalanced ; ROGERS WIRELESS INC | -25.00 CAD | no proposal
+  /db/2001.coin:4 2001/01/07 Expenses:Phone
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, len(decisions), 2)
+	assert.Equal(t, *decisions[0], decision{file: "/db/2000.coin", line: 26, date: "2000/02/03", account: "Expenses:Groceries"})
+	assert.Equal(t, decisions[1].location(), "/db/2001.coin:4")
+
+	_, err = readDecisions(strings.NewReader("Expenses:Phone\n"))
+	assert.True(t, err != nil)
+}
+
+func Test_PayeeOf(t *testing.T) {
+	for _, tc := range []struct {
+		in, out string
+	}{
+		{"FRESHCO #123 TORONTO", "FRESHCO"},
+		{"ROGERS WIRELESS", "ROGERS WIRELESS"},
+		{"JOE'S DINER 416-555-1234", "JOE'S DINER"},
+		{"12345 ACME", ""},
+	} {
+		assert.Equal(t, payeeOf(tc.in), tc.out)
+	}
+}
+
+func Test_ApplyDecisions(t *testing.T) {
+	coin.Load(strings.NewReader(`
+commodity CAD
+  format 1.00 CAD
+
+account Liabilities:Visa
+account Expenses:Groceries
+`), "")
+	coin.ResolveAccounts()
+	fn := filepath.Join(t.TempDir(), "2000.coin")
+	assert.NoError(t, os.WriteFile(fn, []byte(`; imported from visa.ofx
+P 2000/02/01 USD 1.40 CAD
+
+rule Liabilities:Visa
+  Expenses:Groceries  FRESHCO
+
+2000/02/03 FRESHCO #789
+  Unbalanced 35 CAD ; note
+  Visa
+
+include 2001.coin
+
+2000/02/07 ROGERS
+  Unbalanced 25 CAD
+  Visa
+`), 0644))
+	applied := (&cmdCategorize{}).applyDecisions([]*decision{
+		{file: fn, line: 7, date: "2000/02/03", account: "Expenses:Groceries"},
+	})
+	assert.Equal(t, len(applied), 1)
+	content, err := os.ReadFile(fn)
+	assert.NoError(t, err)
+	assert.Equal(t, string(content), `; imported from visa.ofx
+P 2000/02/01 USD 1.40 CAD
+
+rule Liabilities:Visa
+  Expenses:Groceries  FRESHCO
+
+2000/02/03 FRESHCO #789
+  Expenses:Groceries   35.00 CAD ; note
+  Liabilities:Visa    -35.00 CAD
+
+include 2001.coin
+
+2000/02/07 ROGERS
+  Unbalanced 25 CAD
+  Visa
+`)
+}
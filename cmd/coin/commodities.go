@@ -4,17 +4,13 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"strings"
-	"time"
+	"path/filepath"
+	"strconv"
 
 	"github.com/mkobetic/coin"
-	finance "github.com/piquette/finance-go"
-	"github.com/piquette/finance-go/forex"
-	"github.com/piquette/finance-go/quote"
+	"github.com/mkobetic/coin/check"
 )
 
-var ()
-
 func init() {
 	(&cmdCommodities{}).newCommand("commodities", "com", "c")
 }
@@ -23,7 +19,8 @@ type cmdCommodities struct {
 	flagsWithUsage
 	getQuotes bool
 	prices    bool
-	location bool
+	location  bool
+	dryRun    bool
 }
 
 func (*cmdCommodities) newCommand(names ...string) command {
@@ -32,7 +29,8 @@ func (*cmdCommodities) newCommand(names ...string) command {
 	setUsage(cmd.FlagSet, `(commodities|com|c) [flags] [commodity]
 
 Lists commodities and prices.`)
-	cmd.BoolVar(&cmd.getQuotes, "q", false, "get current quotes for all commodities")
+	cmd.BoolVar(&cmd.getQuotes, "q", false, "get current quotes for all commodities and add them to the current year prices")
+	cmd.BoolVar(&cmd.dryRun, "n", false, "print the quotes without adding them to the prices")
 	cmd.BoolVar(&cmd.prices, "p", false, "print commodity price stats")
 	cmd.BoolVar(&cmd.location, "f", false, "include file location on price list")
 	return &cmd
@@ -50,7 +48,7 @@ func (cmd *cmdCommodities) init() {
 func (cmd *cmdCommodities) execute(f io.Writer) {
 	if cmd.NArg() > 0 {
 		commodity := coin.Commodities[cmd.Arg(0)]
-		if (commodity == nil) {
+		if commodity == nil {
 			fmt.Fprintf(os.Stderr, "%s: unknown commodity\n", cmd.Arg(0))
 			return
 		}
@@ -66,34 +64,7 @@ func (cmd *cmdCommodities) execute(f io.Writer) {
 		return
 	}
 	if cmd.getQuotes {
-		coin.CommoditiesDo(func(c *coin.Commodity) {
-			if !(c.NoMarket || c.Id == coin.DefaultCommodityId) {
-				var q *finance.Quote
-				var err error
-				if c.Symbol != "" {
-					q, err = quote.Get(c.Symbol)
-				} else {
-					var fx *finance.ForexPair
-					fx, err = forex.Get(c.Id + coin.DefaultCommodityId + "=X")
-					if err == nil {
-						q = &fx.Quote
-					}
-				}
-				if err != nil {
-					fmt.Fprintf(os.Stderr, "%s: %s\n", c.Id, err)
-					return
-				}
-				cur := coin.Commodities[q.CurrencyID]
-				if cur == nil {
-					fmt.Fprintf(os.Stderr, "%s: no commodity for %s\n", c.Id, q.CurrencyID)
-					return
-				}
-				amt := cur.NewAmountFloat(q.RegularMarketPrice)
-				var b strings.Builder
-				amt.Write(&b, false)
-				fmt.Fprintf(f, "P %s %s %s\n", time.Now().Format(coin.DateFormat), c.Id, b.String())
-			}
-		})
+		cmd.writeQuotes(f)
 		return
 	}
 	coin.CommoditiesDo(func(c *coin.Commodity) {
@@ -109,3 +80,40 @@ func (cmd *cmdCommodities) execute(f io.Writer) {
 		}
 	})
 }
+
+// writeQuotes fetches current quotes for all commodities from their quote providers
+// and appends them to the prices file for the current year.
+func (cmd *cmdCommodities) writeQuotes(f io.Writer) {
+	w := f
+	if !cmd.dryRun {
+		fn := pricesFile()
+		file, err := os.OpenFile(fn, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		check.NoError(err, "Failed to open %s", fn)
+		defer file.Close()
+		w = io.MultiWriter(f, file)
+	}
+	coin.CommoditiesDo(func(c *coin.Commodity) {
+		if c.NoMarket || c.Id == coin.DefaultCommodityId {
+			return
+		}
+		provider, err := quoteProvider(c)
+		if err == nil {
+			var p *coin.Price
+			if p, err = provider.Quote(c); err == nil {
+				err = p.Write(w, false)
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", c.Id, err)
+		}
+	})
+}
+
+// pricesFile returns the file that new prices should be added to.
+// That is the prices file if it exists, otherwise the prices file for the current year.
+func pricesFile() string {
+	if _, err := os.Stat(coin.PricesFile); err == nil {
+		return coin.PricesFile
+	}
+	return filepath.Join(coin.DB, strconv.Itoa(coin.Year)+coin.PricesExtension)
+}
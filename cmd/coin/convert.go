@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/mkobetic/coin"
+	"github.com/mkobetic/coin/check"
+)
+
+func init() {
+	(&cmdConvert{}).newCommand("convert", "conv")
+}
+
+type cmdConvert struct {
+	flagsWithUsage
+	at      coin.Date
+	explain bool
+}
+
+func (*cmdConvert) newCommand(names ...string) command {
+	var cmd cmdConvert
+	cmd.FlagSet = newCommand(&cmd, names...)
+	setUsage(cmd.FlagSet, `(convert|conv) [flags] AMOUNT FROM TO
+
+Convert AMOUNT of commodity FROM to commodity TO using known prices.`)
+	cmd.Var(&cmd.at, "at", "use prices as of this date (default: latest prices)")
+	cmd.BoolVar(&cmd.explain, "explain", false, "print the prices used for each conversion step")
+	return &cmd
+}
+
+func (cmd *cmdConvert) init() {
+	coin.LoadPrices()
+	coin.ResolvePrices()
+}
+
+func (cmd *cmdConvert) execute(f io.Writer) {
+	check.If(cmd.NArg() == 3, "convert requires AMOUNT FROM TO arguments")
+	from := coin.MustFindCommodity(cmd.Arg(1), "convert FROM")
+	to := coin.MustFindCommodity(cmd.Arg(2), "convert TO")
+	amount := coin.MustParseAmount(cmd.Arg(0), from)
+	converted, prices, err := to.ConvertAt(amount, from, cmd.at.Time)
+	check.NoError(err, "converting %s %s", amount, from.Id)
+	fmt.Fprintf(f, "%a %s = %a %s\n", amount, from.Id, converted, to.Id)
+	if !cmd.explain {
+		return
+	}
+	for _, p := range prices {
+		fmt.Fprintf(f, "  %s => %s: %s : %s\n",
+			p.Commodity.Id, p.Currency.Id,
+			strings.TrimSuffix(p.String(), "\n"),
+			trimLocation(p.Location()))
+	}
+}
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/mkobetic/coin"
+	"github.com/mkobetic/coin/check"
+)
+
+func init() {
+	(&cmdExport{}).newCommand("export", "exp")
+}
+
+type cmdExport struct {
+	flagsWithUsage
+	begin, end coin.Date
+	account    string
+}
+
+func (*cmdExport) newCommand(names ...string) command {
+	var cmd cmdExport
+	cmd.FlagSet = newCommand(&cmd, names...)
+	setUsage(cmd.FlagSet, `(export|exp) [flags] (beancount|ledger)
+
+Export the whole ledger (commodities, accounts, prices and transactions) in the specified format.
+Balance assertions are dropped when the transactions are limited with -b, -e or -a,
+because they cannot hold without the omitted transactions.`)
+	cmd.Var(&cmd.begin, "b", "export transactions and prices from this date")
+	cmd.Var(&cmd.end, "e", "export transactions and prices up to this date")
+	cmd.StringVar(&cmd.account, "a", "", "export only transactions with postings in this account subtree")
+	return &cmd
+}
+
+func (cmd *cmdExport) init() {
+	coin.LoadAll()
+}
+
+func (cmd *cmdExport) execute(f io.Writer) {
+	check.If(cmd.NArg() == 1, "export format is required")
+	prices, transactions := cmd.prices(), cmd.transactions()
+	// balance assertions cannot hold without the omitted transactions
+	assertions := cmd.begin.IsZero() && cmd.end.IsZero() && cmd.account == ""
+	switch cmd.Arg(0) {
+	case "beancount":
+		writeBeancount(f, prices, transactions, assertions)
+	case "ledger":
+		writeLedger(f, prices, transactions, assertions)
+	default:
+		check.If(false, "unknown export format %s", cmd.Arg(0))
+	}
+}
+
+func (cmd *cmdExport) inRange(t time.Time) bool {
+	return (cmd.begin.IsZero() || !t.Before(cmd.begin.Time)) &&
+		(cmd.end.IsZero() || t.Before(cmd.end.Time))
+}
+
+func (cmd *cmdExport) prices() (prices []*coin.Price) {
+	for _, p := range coin.Prices {
+		if cmd.inRange(p.Time) {
+			prices = append(prices, p)
+		}
+	}
+	return prices
+}
+
+func (cmd *cmdExport) transactions() (transactions []*coin.Transaction) {
+	var acc *coin.Account
+	if cmd.account != "" {
+		acc = coin.MustFindAccount(cmd.account)
+	}
+	for _, t := range coin.Transactions {
+		if !cmd.inRange(t.Posted) {
+			continue
+		}
+		if acc != nil && !hasPostingIn(t, acc) {
+			continue
+		}
+		transactions = append(transactions, t)
+	}
+	return transactions
+}
+
+// hasPostingIn checks if any of the postings of t is in the acc subtree.
+func hasPostingIn(t *coin.Transaction, acc *coin.Account) bool {
+	for _, s := range t.Postings {
+		if s.Account == acc || strings.HasPrefix(s.Account.FullName, acc.FullName+":") {
+			return true
+		}
+	}
+	return false
+}
+
+// writeLedger writes a self-contained ledger-cli journal,
+// the balance assertions are written only if assertions is set.
+func writeLedger(f io.Writer, prices []*coin.Price, transactions []*coin.Transaction, assertions bool) {
+	coin.CommoditiesDo(func(c *coin.Commodity) {
+		c.Write(f, true)
+		fmt.Fprintln(f)
+	})
+	coin.AccountsDo(func(a *coin.Account) {
+		if a == coin.Root {
+			return
+		}
+		a.Write(f, true)
+		fmt.Fprintln(f)
+	})
+	for _, p := range prices {
+		p.Write(f, true)
+	}
+	for _, t := range transactions {
+		fmt.Fprintln(f)
+		if !assertions {
+			t = withoutAssertions(t)
+		}
+		t.Write(f, true)
+	}
+}
+
+// withoutAssertions returns a copy of t with the balance assertions removed,
+// t and its postings are left intact.
+func withoutAssertions(t *coin.Transaction) *coin.Transaction {
+	t2 := *t
+	t2.Postings = make([]*coin.Posting, len(t.Postings))
+	for i, s := range t.Postings {
+		s2 := *s
+		s2.Transaction = &t2
+		s2.BalanceAsserted = false
+		t2.Postings[i] = &s2
+	}
+	return &t2
+}
+
+const beanDateFormat = "2006-01-02"
+
+// writeBeancount writes the ledger in beancount format.
+// Balance assertions become balance directives dated the next day,
+// because beancount checks balances at the beginning of the day,
+// they are written only if assertions is set.
+func writeBeancount(f io.Writer, prices []*coin.Price, transactions []*coin.Transaction, assertions bool) {
+	start := beanStart(prices, transactions)
+	if coin.DefaultCommodityId != "" {
+		fmt.Fprintf(f, "option \"operating_currency\" %q\n\n", beanCommodity(coin.DefaultCommodity()))
+	}
+	coin.CommoditiesDo(func(c *coin.Commodity) {
+		fmt.Fprintf(f, "%s commodity %s\n", start.Format(beanDateFormat), beanCommodity(c))
+		if c.Name != "" {
+			fmt.Fprintf(f, "  name: %s\n", beanString(c.Name))
+		}
+	})
+	fmt.Fprintln(f)
+	coin.AccountsDo(func(a *coin.Account) {
+		if a == coin.Root {
+			return
+		}
+		// no currency constraint, coin accounts can hold postings in other commodities (e.g. Unbalanced)
+		fmt.Fprintf(f, "%s open %s\n", start.Format(beanDateFormat), beanAccount(a))
+		if a.Description != "" {
+			fmt.Fprintf(f, "  description: %s\n", beanString(a.Description))
+		}
+	})
+	coin.AccountsDo(func(a *coin.Account) {
+		if a == coin.Root || a.Closed.IsZero() {
+			return
+		}
+		fmt.Fprintf(f, "%s close %s\n", a.Closed.Format(beanDateFormat), beanAccount(a))
+	})
+	fmt.Fprintln(f)
+	var asserted map[*coin.Posting]bool
+	if assertions {
+		asserted = lastAssertions(transactions)
+	}
+	for _, p := range prices {
+		fmt.Fprintf(f, "%s price %s %a %s\n",
+			p.Time.Format(beanDateFormat),
+			beanCommodity(p.Commodity),
+			p.Value,
+			beanCommodity(p.Currency))
+	}
+	for _, t := range transactions {
+		fmt.Fprintln(f)
+		writeBeanTransaction(f, t, asserted)
+	}
+}
+
+// lastAssertions returns the postings with the last balance assertion of each account and day,
+// the earlier assertions of the day would become conflicting directives for the same date.
+func lastAssertions(transactions []*coin.Transaction) map[*coin.Posting]bool {
+	type key struct {
+		account *coin.Account
+		day     string
+	}
+	last := map[key]*coin.Posting{}
+	for _, t := range transactions {
+		for _, s := range t.Postings {
+			if s.BalanceAsserted {
+				last[key{s.Account, t.Posted.Format(beanDateFormat)}] = s
+			}
+		}
+	}
+	asserted := map[*coin.Posting]bool{}
+	for _, s := range last {
+		asserted[s] = true
+	}
+	return asserted
+}
+
+// writeBeanTransaction writes the transaction,
+// followed by the balance directives of its asserted postings.
+func writeBeanTransaction(f io.Writer, t *coin.Transaction, asserted map[*coin.Posting]bool) {
+	line := t.Posted.Format(beanDateFormat) + " * " + beanString(t.Description)
+	for _, k := range t.Tags.Keys() {
+		if v := t.Tags[k]; v != "" {
+			line += " ^" + beanTag(k+"-"+v)
+		} else {
+			line += " #" + beanTag(k)
+		}
+	}
+	fmt.Fprintln(f, line)
+	if t.Code != "" {
+		fmt.Fprintf(f, "  code: %s\n", beanString(t.Code))
+	}
+	// the tags are already on the transaction line
+	if notes := beanNotes(t.Notes); len(notes) > 0 {
+		fmt.Fprintf(f, "  note: %s\n", beanString(strings.Join(notes, "\n")))
+	}
+	// beancount needs an explicit total price for postings in different commodities,
+	// the postings are priced in the commodity of the last posting
+	var values []*coin.Amount
+	var currency *coin.Commodity
+	if len(t.Postings) > 0 {
+		currency = t.Postings[len(t.Postings)-1].Quantity.Commodity
+	}
+	for _, s := range t.Postings {
+		if s.Quantity.Commodity != currency {
+			var err error
+			values, err = t.Values(currency)
+			check.NoError(err, "pricing postings")
+			break
+		}
+	}
+	for i, s := range t.Postings {
+		line := fmt.Sprintf("  %s  %a %s", beanAccount(s.Account), s.Quantity, beanCommodity(s.Quantity.Commodity))
+		if values != nil && s.Quantity.Commodity != currency {
+			total := values[i]
+			if total.Sign() < 0 {
+				total = total.Negated()
+			}
+			line += fmt.Sprintf(" @@ %a %s", total, beanCommodity(currency))
+		}
+		fmt.Fprintln(f, line)
+		if len(s.Notes) > 0 {
+			fmt.Fprintf(f, "    note: %s\n", beanString(strings.Join(s.Notes, "\n")))
+		}
+	}
+	for _, s := range t.Postings {
+		if !asserted[s] {
+			continue
+		}
+		fmt.Fprintf(f, "%s balance %s  %a %s\n",
+			t.Posted.AddDate(0, 0, 1).Format(beanDateFormat),
+			beanAccount(s.Account),
+			s.Balance,
+			beanCommodity(s.Balance.Commodity))
+	}
+}
+
+// beanNotes returns the notes with the tags removed, dropping the notes left empty.
+func beanNotes(notes []string) (stripped []string) {
+	for _, n := range notes {
+		idxs := coin.TagIndexes(n)
+		for i := len(idxs) - 1; i >= 0; i-- {
+			n = n[:idxs[i][0]] + n[idxs[i][1]:]
+		}
+		if n = strings.TrimSpace(n); n != "" {
+			stripped = append(stripped, n)
+		}
+	}
+	return stripped
+}
+
+// beanStart returns the date of the earliest transaction or price,
+// used to date the commodity and open directives.
+func beanStart(prices []*coin.Price, transactions []*coin.Transaction) time.Time {
+	var start time.Time
+	if len(transactions) > 0 {
+		start = transactions[0].Posted
+	}
+	if len(prices) > 0 && (start.IsZero() || prices[0].Time.Before(start)) {
+		start = prices[0].Time
+	}
+	if start.IsZero() {
+		start = time.Date(1970, 1, 1, 12, 0, 0, 0, time.UTC)
+	}
+	return start
+}
+
+var beanRoots = map[string]bool{"Assets": true, "Liabilities": true, "Equity": true, "Income": true, "Expenses": true}
+var beanInvalid = regexp.MustCompile(`[^\p{L}\p{N}-]+`)
+
+// beanAccount maps coin account name to a valid beancount account name.
+// Accounts outside of the standard beancount roots are moved under Equity.
+func beanAccount(a *coin.Account) string {
+	names := strings.Split(a.FullName, ":")
+	for i, n := range names {
+		n = strings.Trim(beanInvalid.ReplaceAllString(n, "-"), "-")
+		if n == "" {
+			n = "X"
+		}
+		r := []rune(n)
+		r[0] = unicode.ToUpper(r[0])
+		names[i] = string(r)
+	}
+	if !beanRoots[names[0]] {
+		names = append([]string{"Equity"}, names...)
+	}
+	return strings.Join(names, ":")
+}
+
+var beanInvalidCommodity = regexp.MustCompile(`[^A-Z0-9'._-]+`)
+
+// beanCommodity maps coin commodity id to a valid beancount currency name.
+func beanCommodity(c *coin.Commodity) string {
+	id := beanInvalidCommodity.ReplaceAllString(strings.ToUpper(c.Id), "-")
+	id = strings.Trim(id, "'._-")
+	if id == "" || id[0] < 'A' || id[0] > 'Z' {
+		id = "C" + id
+	}
+	return id
+}
+
+var beanInvalidTag = regexp.MustCompile(`[^A-Za-z0-9_/.-]+`)
+
+func beanTag(tag string) string {
+	return beanInvalidTag.ReplaceAllString(tag, "-")
+}
+
+func beanString(s string) string {
+	return strconv.Quote(s)
+}
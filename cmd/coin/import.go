@@ -0,0 +1,119 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/mkobetic/coin"
+	"github.com/mkobetic/coin/check"
+	"github.com/mkobetic/coin/classify"
+	"github.com/mkobetic/coin/importer"
+	"github.com/mkobetic/coin/importer/camt"
+	"github.com/mkobetic/coin/importer/csv"
+	"github.com/mkobetic/coin/importer/mt940"
+	"github.com/mkobetic/coin/importer/ofx"
+	"github.com/mkobetic/coin/importer/qif"
+)
+
+func init() {
+	(&cmdImport{}).newCommand("import", "imp")
+}
+
+type cmdImport struct {
+	flagsWithUsage
+	keepDupes      bool
+	dupeDays       int
+	dupeSimilarity float64
+	review         string
+	learn          bool
+	confidence     float64
+	bmo            bool
+	source         string
+	qifAccount     string
+	dmy            bool
+}
+
+func (*cmdImport) newCommand(names ...string) command {
+	var cmd cmdImport
+	cmd.FlagSet = newCommand(&cmd, names...)
+	setUsage(cmd.FlagSet, `(import|imp) [flags] FILES...
+
+Convert statement files into transactions, the importer is picked by the file type (ofx/qfx, camt.053, mt940, qif, csv).`)
+	cmd.BoolVar(&cmd.keepDupes, "keep-dupes", false, "keep duplicate transactions")
+	cmd.IntVar(&cmd.dupeDays, "dupe-days", 3, "maximum date difference in days of duplicate transactions and transfers")
+	cmd.Float64Var(&cmd.dupeSimilarity, "dupe-similarity", 0.5, "minimum description similarity (0 to 1) of duplicate transactions")
+	cmd.StringVar(&cmd.review, "review", "", "write possible duplicates that were kept to this file")
+	cmd.BoolVar(&cmd.learn, "learn", false, "predict the accounts of transactions not matching any rule from the ledger")
+	cmd.Float64Var(&cmd.confidence, "confidence", 0.5, "minimum confidence (0 to 1) of predicted accounts")
+	cmd.BoolVar(&cmd.bmo, "bmo", false, "handle invalid qfx files from Bank of Montreal")
+	cmd.StringVar(&cmd.source, "source", "", "csv source rules to use to read csv files")
+	cmd.StringVar(&cmd.qifAccount, "qif-account", "", "account id (import-id or full account name) of qif files without !Account sections")
+	cmd.BoolVar(&cmd.dmy, "dmy", false, "qif dates are day first (D/M/Y)")
+	return &cmd
+}
+
+func (cmd *cmdImport) init() {
+	coin.LoadAll()
+}
+
+func (cmd *cmdImport) execute(f io.Writer) {
+	check.If(cmd.NArg() > 0, "import requires FILES")
+	importers := []importer.Importer{
+		cmd.ofxImporter(),
+		&camt.Importer{Rules: cmd.rules("camt.rules")},
+		&mt940.Importer{Rules: cmd.rules("mt940.rules")},
+		&qif.Importer{Rules: cmd.rules("qif.rules"), Account: cmd.qifAccount, DMY: cmd.dmy, Log: os.Stderr},
+	}
+	if imp := cmd.csvImporter(); imp != nil {
+		importers = append(importers, imp)
+	}
+
+	p := importer.NewPipeline()
+	p.KeepDupes = cmd.keepDupes
+	p.Days = cmd.dupeDays
+	p.Similarity = cmd.dupeSimilarity
+	if cmd.learn {
+		p.Classifier = classify.New(coin.Transactions, cmd.confidence)
+	}
+	if cmd.review != "" {
+		file, err := os.Create(cmd.review)
+		check.NoError(err, "Failed to create %s", cmd.review)
+		defer file.Close()
+		p.Review = file
+	}
+	for _, fileName := range cmd.Args() {
+		imp, r, file, err := importer.Open(importers, fileName)
+		check.NoError(err, "Failed to open %s", fileName)
+		defer file.Close()
+		check.NoError(p.Read(imp, r), "Cannot parse file %s", fileName)
+	}
+	p.Write(f)
+}
+
+func (cmd *cmdImport) ofxImporter() importer.Importer {
+	return &ofx.Importer{Rules: cmd.rules(), BMO: cmd.bmo}
+}
+
+// rules loads the rules from the first existing of the rules files or ofx.rules (see importer.LoadRules).
+func (cmd *cmdImport) rules(names ...string) *coin.RuleIndex {
+	rules, err := importer.LoadRules(append(names, "ofx.rules")...)
+	check.NoError(err, "Failed to load rules")
+	return rules
+}
+
+// csvImporter returns the csv importer if a source is specified.
+func (cmd *cmdImport) csvImporter() importer.Importer {
+	if cmd.source == "" {
+		return nil
+	}
+	fn := filepath.Join(coin.DB, "csv.rules")
+	file, err := os.Open(fn)
+	check.NoError(err, "Failed to open %s", fn)
+	defer file.Close()
+	rules := csv.ReadRules(file)
+	rules.RuleIndex = coin.ImportRules.Merge(rules.RuleIndex)
+	src := rules.Source(cmd.source)
+	check.If(src != nil, "Unknown source %s", cmd.source)
+	return &csv.Importer{Rules: rules, Source: src}
+}
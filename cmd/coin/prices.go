@@ -0,0 +1,278 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mkobetic/coin"
+	"github.com/mkobetic/coin/check"
+)
+
+func init() {
+	(&cmdPrices{}).newCommand("prices", "pri", "p")
+}
+
+type cmdPrices struct {
+	flagsWithUsage
+	before coin.Date
+	weekly bool
+	gap    int
+	jump   float64
+	write  bool
+
+	commodity *coin.Commodity
+}
+
+func (*cmdPrices) newCommand(names ...string) command {
+	var cmd cmdPrices
+	cmd.FlagSet = newCommand(&cmd, names...)
+	setUsage(cmd.FlagSet, `(prices|pri|p) [flags] (dedupe|thin|gaps|outliers|split) [commodity]
+
+Maintain the price database.
+  dedupe   - drop duplicate prices (same commodity, currency and date), last loaded wins
+  thin     - keep only the last price of a month (or week) for prices older than -b
+  gaps     - report gaps between consecutive prices longer than -gap days
+  outliers - report changes between consecutive prices bigger than -jump percent
+  split    - rewrite all prices into per-year .prices files
+The dedupe and thin results are printed, unless -i is used to rewrite the prices files.
+With a commodity, only the prices of the commodity are changed, the other prices are rewritten as they are.`)
+	cmd.Var(&cmd.before, "b", "thin prices before this date (default: -1y)")
+	cmd.BoolVar(&cmd.weekly, "w", false, "thin to week-end prices instead of month-end")
+	cmd.IntVar(&cmd.gap, "gap", 30, "report gaps longer than this many days")
+	cmd.Float64Var(&cmd.jump, "jump", 20, "report changes bigger than this percentage")
+	cmd.BoolVar(&cmd.write, "i", false, "rewrite prices into per-year .prices files in place")
+	return &cmd
+}
+
+func (cmd *cmdPrices) init() {
+	coin.LoadPrices()
+	coin.ResolvePrices()
+}
+
+func (cmd *cmdPrices) execute(f io.Writer) {
+	check.If(cmd.NArg() > 0, "prices action is required")
+	action := cmd.Arg(0)
+	if cmd.NArg() > 1 {
+		cmd.commodity = coin.MustFindCommodity(cmd.Arg(1), "prices command")
+	}
+	series := priceSeries(cmd.commodity)
+	switch action {
+	case "dedupe":
+		cmd.output(f, series.dedupe(os.Stderr))
+	case "thin":
+		before := cmd.before.Time
+		if before.IsZero() {
+			before = coin.MustParseDate("-1y")
+		}
+		cmd.output(f, series.thin(before, cmd.weekly))
+	case "gaps":
+		series.gaps(f, cmd.gap)
+	case "outliers":
+		series.outliers(f, cmd.jump)
+	case "split":
+		cmd.write = true
+		cmd.output(f, series)
+	default:
+		check.If(false, "unknown prices action %s", action)
+	}
+}
+
+func (cmd *cmdPrices) output(f io.Writer, series pricesByCommodity) {
+	if !cmd.write {
+		for _, p := range series.all() {
+			p.Write(f, false)
+		}
+		return
+	}
+	if cmd.commodity != nil {
+		// the files are replaced, keep the prices of the other commodities
+		series = priceSeries(nil).replace(series)
+	}
+	writePricesByYear(series.all())
+}
+
+// pricesByCommodity maps "commodity/currency" to the corresponding price list sorted by time.
+type pricesByCommodity map[string][]*coin.Price
+
+func priceSeries(commodity *coin.Commodity) pricesByCommodity {
+	series := make(pricesByCommodity)
+	for _, p := range coin.Prices {
+		if commodity != nil && p.Commodity != commodity {
+			continue
+		}
+		key := p.Commodity.Id + "/" + p.Currency.Id
+		series[key] = append(series[key], p)
+	}
+	return series
+}
+
+// replace returns the series with the price lists of the other series replacing the lists with the same keys.
+func (series pricesByCommodity) replace(other pricesByCommodity) pricesByCommodity {
+	replaced := make(pricesByCommodity)
+	for k, prices := range series {
+		replaced[k] = prices
+	}
+	for k, prices := range other {
+		replaced[k] = prices
+	}
+	return replaced
+}
+
+func (series pricesByCommodity) keys() (keys []string) {
+	for k := range series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// all returns all the prices sorted by time, commodity and currency
+func (series pricesByCommodity) all() (prices []*coin.Price) {
+	for _, k := range series.keys() {
+		prices = append(prices, series[k]...)
+	}
+	sort.SliceStable(prices, func(i, j int) bool {
+		return prices[i].Time.Before(prices[j].Time)
+	})
+	return prices
+}
+
+func (series pricesByCommodity) dedupe(log io.Writer) pricesByCommodity {
+	deduped := make(pricesByCommodity)
+	for _, k := range series.keys() {
+		var kept []*coin.Price
+		for _, p := range series[k] {
+			if last := len(kept) - 1; last >= 0 && kept[last].Time.Equal(p.Time) {
+				fmt.Fprintf(log, "DROPPING DUPLICATE PRICE: %s: %s", trimLocation(kept[last].Location()), kept[last])
+				kept[last] = p
+				continue
+			}
+			kept = append(kept, p)
+		}
+		deduped[k] = kept
+	}
+	return deduped
+}
+
+func (series pricesByCommodity) thin(before time.Time, weekly bool) pricesByCommodity {
+	period := func(t time.Time) string {
+		if weekly {
+			y, w := t.ISOWeek()
+			return fmt.Sprintf("%d/%d", y, w)
+		}
+		return t.Format(coin.MonthFormat)
+	}
+	thinned := make(pricesByCommodity)
+	for _, k := range series.keys() {
+		var kept []*coin.Price
+		for i, p := range series[k] {
+			if p.Time.Before(before) && i+1 < len(series[k]) {
+				next := series[k][i+1]
+				if next.Time.Before(before) && period(next.Time) == period(p.Time) {
+					// a later price from the same period is coming up
+					continue
+				}
+			}
+			kept = append(kept, p)
+		}
+		thinned[k] = kept
+	}
+	return thinned
+}
+
+func (series pricesByCommodity) gaps(f io.Writer, days int) {
+	max := time.Duration(days) * 24 * time.Hour
+	for _, k := range series.keys() {
+		prices := series[k]
+		for i := 1; i < len(prices); i++ {
+			from, to := prices[i-1], prices[i]
+			if gap := to.Time.Sub(from.Time); gap > max {
+				fmt.Fprintf(f, "GAP %s %s - %s (%d days): %s\n",
+					k,
+					from.Time.Format(coin.DateFormat),
+					to.Time.Format(coin.DateFormat),
+					int(gap.Hours()/24),
+					trimLocation(to.Location()))
+			}
+		}
+	}
+}
+
+func (series pricesByCommodity) outliers(f io.Writer, percent float64) {
+	limit := new(big.Rat).SetFloat64(percent / 100)
+	for _, k := range series.keys() {
+		prices := series[k]
+		for i := 1; i < len(prices); i++ {
+			from, to := prices[i-1], prices[i]
+			if from.Value.Sign() == 0 {
+				continue
+			}
+			change := new(big.Rat).SetFrac(new(big.Int).Sub(to.Value.Int, from.Value.Int), from.Value.Int)
+			if new(big.Rat).Abs(change).Cmp(limit) > 0 {
+				pct, _ := change.Float64()
+				fmt.Fprintf(f, "OUTLIER %s %s %a => %s %a (%+.1f%%): %s\n",
+					k,
+					from.Time.Format(coin.DateFormat), from.Value,
+					to.Time.Format(coin.DateFormat), to.Value,
+					pct*100,
+					trimLocation(to.Location()))
+			}
+		}
+	}
+}
+
+// writePricesByYear writes prices into $COINDB/YYYY.prices files replacing existing files.
+// Other price files, that the prices were loaded from, are removed.
+func writePricesByYear(prices []*coin.Price) {
+	sources := map[string]bool{}
+	for _, p := range prices {
+		if loc := p.Location(); loc != "" {
+			file, _, _ := strings.Cut(loc, ":")
+			sources[file] = true
+		}
+	}
+	var file *os.File
+	var year int
+	var files = map[string]string{} // target file name => temp file name
+	var targets []string
+	for _, p := range prices {
+		if file == nil || p.Time.Year() != year {
+			if file != nil {
+				check.NoError(file.Close(), "closing %s", file.Name())
+			}
+			year = p.Time.Year()
+			fn := filepath.Join(coin.DB, strconv.Itoa(year)+coin.PricesExtension)
+			tf, err := os.CreateTemp(coin.DB, filepath.Base(fn))
+			check.NoError(err, "creating temp file")
+			file = tf
+			files[fn] = tf.Name()
+			targets = append(targets, fn)
+		}
+		check.NoError(p.Write(file, false), "writing %s", file.Name())
+	}
+	if file != nil {
+		check.NoError(file.Close(), "closing %s", file.Name())
+	}
+	for _, fn := range targets {
+		check.NoError(os.Rename(files[fn], fn), "renaming temp file %s to %s", files[fn], fn)
+		fmt.Fprintf(os.Stderr, "Wrote %s\n", fn)
+	}
+	for fn := range sources {
+		if _, ok := files[fn]; ok {
+			continue
+		}
+		if filepath.Ext(fn) == coin.PricesExtension || filepath.Base(fn) == coin.PricesFilename {
+			check.NoError(os.Remove(fn), "deleting old file %s", fn)
+			fmt.Fprintf(os.Stderr, "Removed %s\n", fn)
+		} else {
+			fmt.Fprintf(os.Stderr, "Prices in %s were not removed\n", fn)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/mkobetic/coin"
+	"github.com/mkobetic/coin/assert"
+)
+
+func Test_PricesReplace(t *testing.T) {
+	vxf, usd := &coin.Commodity{Id: "VXF", Decimals: 2}, &coin.Commodity{Id: "USD", Decimals: 4}
+	price := func(c *coin.Commodity, date, value string) *coin.Price {
+		return &coin.Price{Commodity: c, Currency: CAD, Time: coin.MustParseDate(date), Value: coin.MustParseAmount(value, CAD)}
+	}
+	all := pricesByCommodity{
+		"USD/CAD": {price(usd, "2020/01/10", "1.30"), price(usd, "2020/01/20", "1.31")},
+		"VXF/CAD": {price(vxf, "2020/01/10", "40.00"), price(vxf, "2020/01/20", "41.00")},
+	}
+	thinned := pricesByCommodity{"VXF/CAD": all["VXF/CAD"]}.thin(coin.MustParseDate("2021/01/01"), false)
+	var got []string
+	for _, p := range all.replace(thinned).all() {
+		got = append(got, p.String())
+	}
+	assert.EqualStrings(t, got,
+		"P 2020/01/10 USD 1.30 CAD\n",
+		"P 2020/01/20 USD 1.31 CAD\n",
+		"P 2020/01/20 VXF 41.00 CAD\n",
+	)
+}
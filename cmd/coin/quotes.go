@@ -0,0 +1,238 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mkobetic/coin"
+	"github.com/mkobetic/coin/fx"
+	finance "github.com/piquette/finance-go"
+	"github.com/piquette/finance-go/forex"
+	"github.com/piquette/finance-go/quote"
+)
+
+// QuoteProvider retrieves the current price of a commodity.
+// The provider is selected by the commodity source directive:
+//
+//	source yahoo            - Yahoo Finance (default)
+//	source ecb              - European Central Bank daily reference rates
+//	source csv:path         - latest row of a local CSV file (date,price,currency)
+//	source http:url         - response body of a GET request (e.g. 12.34 CAD)
+//
+// Relative csv paths are resolved relative to $COINDB.
+// The http url can reference the commodity symbol (or id) as ${symbol}.
+type QuoteProvider interface {
+	Quote(c *coin.Commodity) (*coin.Price, error)
+}
+
+const ecbDailyURL = "https://www.ecb.europa.eu/stats/eurofxref/eurofxref-daily.xml"
+
+func quoteProvider(c *coin.Commodity) (QuoteProvider, error) {
+	kind, arg, _ := strings.Cut(c.Source, ":")
+	switch kind {
+	case "", "yahoo":
+		return yahooProvider{}, nil
+	case "ecb":
+		return ecbProvider{url: ecbDailyURL}, nil
+	case "csv":
+		if !filepath.IsAbs(arg) {
+			arg = filepath.Join(coin.DB, arg)
+		}
+		return csvProvider{path: arg}, nil
+	case "http", "https":
+		if strings.HasPrefix(arg, "//") {
+			// the source is the url itself
+			arg = c.Source
+		}
+		return httpProvider{url: arg}, nil
+	}
+	return nil, fmt.Errorf("unknown quote source %s", c.Source)
+}
+
+func symbol(c *coin.Commodity) string {
+	if c.Symbol != "" {
+		return c.Symbol
+	}
+	return c.Id
+}
+
+func findCurrency(id string) (*coin.Commodity, error) {
+	cur := coin.Commodities[id]
+	if cur == nil {
+		cur = coin.CommoditiesBySymbol[id]
+	}
+	if cur == nil {
+		return nil, fmt.Errorf("no commodity for %s", id)
+	}
+	return cur, nil
+}
+
+func today() time.Time {
+	return time.Date(coin.Year, coin.Month, coin.Day, 12, 0, 0, 0, time.UTC)
+}
+
+// yahooProvider gets quotes from Yahoo Finance.
+// Commodities without a symbol are assumed to be currencies.
+type yahooProvider struct{}
+
+func (yahooProvider) Quote(c *coin.Commodity) (*coin.Price, error) {
+	var q *finance.Quote
+	var err error
+	if c.Symbol != "" {
+		q, err = quote.Get(c.Symbol)
+	} else {
+		var fx *finance.ForexPair
+		fx, err = forex.Get(c.Id + coin.DefaultCommodityId + "=X")
+		if err == nil {
+			q = &fx.Quote
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if q == nil {
+		return nil, fmt.Errorf("no quote for %s", symbol(c))
+	}
+	cur, err := findCurrency(q.CurrencyID)
+	if err != nil {
+		return nil, err
+	}
+	return &coin.Price{
+		Commodity: c,
+		Currency:  cur,
+		Value:     cur.NewAmountFloat(q.RegularMarketPrice),
+		Time:      today(),
+	}, nil
+}
+
+// ecbProvider computes the price of a currency in the default commodity
+// from the ECB daily reference rates, which are all quoted against EUR.
+type ecbProvider struct {
+	url string
+}
+
+func (p ecbProvider) Quote(c *coin.Commodity) (*coin.Price, error) {
+	resp, err := http.Get(p.url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", p.url, resp.Status)
+	}
+	days, err := fx.ReadECBXML(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if len(days) == 0 {
+		return nil, fmt.Errorf("no rates found at %s", p.url)
+	}
+	rates := days[len(days)-1]
+	cur := coin.DefaultCommodity()
+	value := rates.Rate(c.Id, cur.Id)
+	if value == nil {
+		return nil, fmt.Errorf("no ECB rate for %s/%s", c.Id, cur.Id)
+	}
+	return &coin.Price{
+		Commodity: c,
+		Currency:  cur,
+		Value:     coin.NewAmountFrac(value.Num(), value.Denom(), cur),
+		Time:      rates.Date,
+	}, nil
+}
+
+// csvProvider reads the most recent price from a local CSV file,
+// where each row is date,price,currency.
+type csvProvider struct {
+	path string
+}
+
+func (p csvProvider) Quote(c *coin.Commodity) (*coin.Price, error) {
+	file, err := os.Open(p.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	r := csv.NewReader(file)
+	r.FieldsPerRecord = 3
+	r.TrimLeadingSpace = true
+	var latest *coin.Price
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var date coin.Date
+		if err := date.Set(row[0]); err != nil {
+			return nil, fmt.Errorf("%s: %s", p.path, err)
+		}
+		if latest != nil && !date.After(latest.Time) {
+			continue
+		}
+		cur, err := findCurrency(row[2])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", p.path, err)
+		}
+		value, err := parseQuoteValue(row[1], cur)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", p.path, err)
+		}
+		latest = &coin.Price{Commodity: c, Currency: cur, Value: value, Time: date.Time}
+	}
+	if latest == nil {
+		return nil, fmt.Errorf("%s: no prices found", p.path)
+	}
+	return latest, nil
+}
+
+// httpProvider expects the response body to be an amount with a commodity, e.g. 12.34 CAD
+type httpProvider struct {
+	url string
+}
+
+func (p httpProvider) Quote(c *coin.Commodity) (*coin.Price, error) {
+	url := strings.ReplaceAll(p.url, "${symbol}", symbol(c))
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	match := coin.AmountREX.Match(body)
+	if match == nil {
+		return nil, fmt.Errorf("%s: invalid quote %q", url, body)
+	}
+	cur, err := findCurrency(match["commodity"])
+	if err != nil {
+		return nil, err
+	}
+	value, err := parseQuoteValue(match["amount"], cur)
+	if err != nil {
+		return nil, err
+	}
+	return &coin.Price{Commodity: c, Currency: cur, Value: value, Time: today()}, nil
+}
+
+func parseQuoteValue(s string, cur *coin.Commodity) (*coin.Amount, error) {
+	value, ok := new(big.Rat).SetString(strings.TrimSpace(s))
+	if !ok {
+		return nil, fmt.Errorf("invalid price %s", s)
+	}
+	return coin.NewAmountFrac(value.Num(), value.Denom(), cur), nil
+}
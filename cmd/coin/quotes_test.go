@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mkobetic/coin"
+	"github.com/mkobetic/coin/assert"
+)
+
+const ecbDaily = `<?xml version="1.0" encoding="UTF-8"?>
+<gesmes:Envelope xmlns:gesmes="http://www.gesmes.org/xml/2002-08-01" xmlns="http://www.ecb.int/vocabulary/2002-08-01/eurofxref">
+	<gesmes:subject>Reference rates</gesmes:subject>
+	<Cube>
+		<Cube time='2024-01-05'>
+			<Cube currency='USD' rate='1.0921'/>
+			<Cube currency='JPY' rate='158.08'/>
+			<Cube currency='CAD' rate='1.4608'/>
+		</Cube>
+	</Cube>
+</gesmes:Envelope>`
+
+func quotesServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ecb":
+			fmt.Fprint(w, ecbDaily)
+		case "/quote/VXF":
+			fmt.Fprint(w, "123.4567 USD\n")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func Test_QuoteProviders(t *testing.T) {
+	cad := &coin.Commodity{Id: "CAD", Decimals: 4}
+	usd := &coin.Commodity{Id: "USD", Decimals: 2}
+	vxf := &coin.Commodity{Id: "VXF", Decimals: 3, Symbol: "VXF"}
+	for _, c := range []*coin.Commodity{cad, usd, vxf} {
+		coin.Commodities[c.Id] = c
+	}
+	defer func() {
+		for _, c := range []*coin.Commodity{cad, usd, vxf} {
+			delete(coin.Commodities, c.Id)
+		}
+	}()
+	was := coin.DefaultCommodityId
+	coin.DefaultCommodityId = "CAD"
+	defer func() { coin.DefaultCommodityId = was }()
+
+	srv := quotesServer()
+	defer srv.Close()
+
+	dir := t.TempDir()
+	csvFile := filepath.Join(dir, "vxf.csv")
+	err := os.WriteFile(csvFile, []byte("2024/01/03,40.50,CAD\n2024/01/05, 41.25, CAD\n2024/01/04,40.75,CAD\n"), 0644)
+	assert.NoError(t, err)
+
+	coin.WithDate("2024/01/06", func() {
+		for _, fix := range []struct {
+			provider  QuoteProvider
+			commodity *coin.Commodity
+			price     string
+		}{
+			{ecbProvider{url: srv.URL + "/ecb"}, usd, "P 2024/01/05 USD 1.3376 CAD\n"},
+			{csvProvider{path: csvFile}, vxf, "P 2024/01/05 VXF 41.2500 CAD\n"},
+			{httpProvider{url: srv.URL + "/quote/${symbol}"}, vxf, "P 2024/01/06 VXF 123.45 USD\n"},
+		} {
+			p, err := fix.provider.Quote(fix.commodity)
+			if assert.NoError(t, err) {
+				assert.Equal(t, p.String(), fix.price)
+			}
+		}
+		_, err := httpProvider{url: srv.URL + "/quote/XXX"}.Quote(usd)
+		assert.True(t, err != nil, "expected error for missing quote")
+	})
+}
+
+func Test_QuoteProviderSelection(t *testing.T) {
+	for _, fix := range []struct {
+		source   string
+		provider QuoteProvider
+	}{
+		{"", yahooProvider{}},
+		{"yahoo", yahooProvider{}},
+		{"ecb", ecbProvider{url: ecbDailyURL}},
+		{"csv:/tmp/quotes.csv", csvProvider{path: "/tmp/quotes.csv"}},
+		{"http:https://example.com/q", httpProvider{url: "https://example.com/q"}},
+		{"https://example.com/q/${symbol}", httpProvider{url: "https://example.com/q/${symbol}"}},
+	} {
+		p, err := quoteProvider(&coin.Commodity{Id: "X", Source: fix.source})
+		assert.NoError(t, err)
+		assert.Equal(t, p, fix.provider)
+	}
+	_, err := quoteProvider(&coin.Commodity{Id: "X", Source: "bogus"})
+	assert.True(t, err != nil, "expected error for unknown source")
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"sort"
+
+	"github.com/mkobetic/coin"
+	"github.com/mkobetic/coin/check"
+	"github.com/mkobetic/coin/importer"
+)
+
+func init() {
+	(&cmdRules{}).newCommand("rules", "rul")
+}
+
+type cmdRules struct {
+	flagsWithUsage
+	file   string
+	amount string
+	date   coin.Date
+
+	rules *coin.RuleIndex
+}
+
+func (*cmdRules) newCommand(names ...string) command {
+	var cmd cmdRules
+	cmd.FlagSet = newCommand(&cmd, names...)
+	setUsage(cmd.FlagSet, `(rules|rul) [flags] (test DESCRIPTION [acct-id]|coverage)
+
+Diagnose the import rules (rule directives and ofx.rules).
+  test     - list the rules matching the description in the order they are tried,
+             for the account group with the acct-id or all account groups,
+             the first rule is the one used, the following ones are shadowed by it
+  coverage - run the ledger transactions through the rules of their accounts and report
+             rules that never match (UNUSED), rules that match only after an earlier rule (SHADOWED)
+             and rules whose account disagrees with the ledger (MISMATCH)`)
+	cmd.StringVar(&cmd.file, "f", "", "rules file merged with the rule directives (default $COINDB/ofx.rules)")
+	cmd.StringVar(&cmd.amount, "amount", "", "test with this amount posted to the statement account")
+	cmd.Var(&cmd.date, "d", "test with this date")
+	return &cmd
+}
+
+func (cmd *cmdRules) init() {
+	coin.LoadAll()
+}
+
+func (cmd *cmdRules) execute(f io.Writer) {
+	check.If(cmd.NArg() > 0, "rules action is required")
+	cmd.loadRules()
+	switch cmd.Arg(0) {
+	case "test":
+		check.If(cmd.NArg() > 1, "test description is required")
+		cmd.test(f, cmd.Arg(1), cmd.Arg(2))
+	case "coverage":
+		cmd.coverage(f)
+	default:
+		check.If(false, "unknown rules action %s", cmd.Arg(0))
+	}
+}
+
+func (cmd *cmdRules) loadRules() {
+	if cmd.file == "" {
+		var err error
+		cmd.rules, err = importer.LoadRules("ofx.rules")
+		check.NoError(err, "Failed to load rules")
+		return
+	}
+	file, err := os.Open(cmd.file)
+	check.NoError(err, "Failed to open %s", cmd.file)
+	defer file.Close()
+	rules, err := coin.ReadRules(file)
+	check.NoError(err, "Failed to read %s", cmd.file)
+	cmd.rules = coin.ImportRules.Merge(rules)
+}
+
+// groups returns the account groups sorted by account id.
+func (cmd *cmdRules) groups() (ids []string) {
+	for id := range cmd.rules.Accounts {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (cmd *cmdRules) test(f io.Writer, description, acctId string) {
+	in := &coin.RuleInput{Payee: description, Posted: cmd.date.Time}
+	if cmd.amount != "" {
+		amount, ok := new(big.Rat).SetString(cmd.amount)
+		check.If(ok, "invalid amount %s", cmd.amount)
+		in.Amount = amount
+	}
+	ids := []string{acctId}
+	if acctId == "" {
+		ids = cmd.groups()
+	}
+	for _, id := range ids {
+		ars := cmd.rules.AccountRulesFor(id)
+		fmt.Fprintln(f, id, ars.Account.FullName)
+		matches := ars.Matches(in)
+		if len(matches) == 0 {
+			fmt.Fprintln(f, "  no match")
+		}
+		for i, m := range matches {
+			var path string
+			for _, rs := range m.Path {
+				path += "@" + rs.Name() + " "
+			}
+			status := "used"
+			if i > 0 {
+				status = "shadowed"
+			}
+			fmt.Fprintf(f, "  %d %s%s %s (%s)\n", m.Order, path, m.Rule.Name(), ruleString(m.Rule), status)
+		}
+	}
+}
+
+// ruleString returns the regex and the conditions of the rule.
+func ruleString(r *coin.Rule) string {
+	s := r.String()
+	for _, c := range r.Conditions {
+		s += " ? " + c.String()
+	}
+	return s
+}
+
+// ruleStats collects the coverage of a rule.
+type ruleStats struct {
+	used       int
+	shadowed   int
+	shadowedBy map[*coin.Rule]int
+	mismatches map[*coin.Account][]*coin.Transaction
+}
+
+func (cmd *cmdRules) coverage(f io.Writer) {
+	stats := map[*coin.Rule]*ruleStats{}
+	statsOf := func(r *coin.Rule) *ruleStats {
+		s := stats[r]
+		if s == nil {
+			s = &ruleStats{shadowedBy: map[*coin.Rule]int{}, mismatches: map[*coin.Account][]*coin.Transaction{}}
+			stats[r] = s
+		}
+		return s
+	}
+	groups := map[*coin.Account]*coin.AccountRules{}
+	for _, ars := range cmd.rules.Accounts {
+		groups[ars.Account] = ars
+	}
+	for _, t := range coin.Transactions {
+		for _, p := range t.Postings {
+			ars := groups[p.Account]
+			if ars == nil {
+				continue
+			}
+			matches := ars.Matches(&coin.RuleInput{
+				Payee:   t.Description,
+				Amount:  ratOf(p.Quantity),
+				Posted:  t.Posted,
+				Account: p.Account,
+			})
+			if len(matches) == 0 {
+				continue
+			}
+			used := matches[0].Rule
+			statsOf(used).used++
+			for _, m := range matches[1:] {
+				s := statsOf(m.Rule)
+				s.shadowed++
+				s.shadowedBy[used]++
+			}
+			if actual := counterAccount(t, p); actual != nil && !agrees(used, actual) {
+				s := statsOf(used)
+				s.mismatches[actual] = append(s.mismatches[actual], t)
+			}
+		}
+	}
+
+	// report the rules in the order of the rules file
+	seen := map[*coin.Rule]bool{}
+	report := func(group string) func(r *coin.Rule, path []*coin.RuleSet) {
+		return func(r *coin.Rule, path []*coin.RuleSet) {
+			if len(path) > 0 || seen[r] {
+				return // rules of referenced sets are reported with the set
+			}
+			seen[r] = true
+			rule := fmt.Sprintf("%s %s %s", group, r.Name(), ruleString(r))
+			s := stats[r]
+			if s == nil {
+				fmt.Fprintf(f, "UNUSED %s\n", rule)
+				return
+			}
+			if s.used == 0 {
+				fmt.Fprintf(f, "SHADOWED %s : %d by %s\n", rule, s.shadowed, mostFrequent(s.shadowedBy))
+			}
+			var accounts []*coin.Account
+			for a := range s.mismatches {
+				accounts = append(accounts, a)
+			}
+			sort.Slice(accounts, func(i, j int) bool { return accounts[i].FullName < accounts[j].FullName })
+			for _, a := range accounts {
+				ts := s.mismatches[a]
+				fmt.Fprintf(f, "MISMATCH %s : %d/%d %s %s\n", rule, len(ts), s.used, a.FullName, ts[0].Location())
+			}
+		}
+	}
+	for _, rs := range cmd.rules.Sets {
+		rs.Walk(report("@" + rs.Name()))
+	}
+	for _, id := range cmd.groups() {
+		cmd.rules.Accounts[id].Walk(report(id))
+	}
+}
+
+// counterAccount returns the account of the other posting of a categorized two posting transaction.
+// Returns nil for other transactions.
+func counterAccount(t *coin.Transaction, p *coin.Posting) *coin.Account {
+	if len(t.Postings) != 2 {
+		return nil
+	}
+	if a := t.Other(p).Account; a != coin.Unbalanced {
+		return a
+	}
+	return nil
+}
+
+// agrees returns true if the rule drops the transaction or posts to the account (including splits).
+func agrees(r *coin.Rule, account *coin.Account) bool {
+	if r.Account == nil || r.Account == account {
+		return true
+	}
+	for _, sp := range r.Splits {
+		if sp.Account == account {
+			return true
+		}
+	}
+	return false
+}
+
+// mostFrequent returns the rule shadowing a rule most often.
+func mostFrequent(rules map[*coin.Rule]int) string {
+	var names []string
+	var max int
+	for r, n := range rules {
+		name := r.Name() + " " + ruleString(r)
+		if n > max {
+			names, max = []string{name}, n
+		} else if n == max {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names[0]
+}
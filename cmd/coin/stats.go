@@ -4,8 +4,11 @@ import (
 	"fmt"
 	"io"
 	"sort"
+	"strings"
 
 	"github.com/mkobetic/coin"
+	"github.com/mkobetic/coin/importer"
+	"github.com/mkobetic/coin/redact"
 )
 
 func init() {
@@ -17,6 +20,9 @@ type cmdStats struct {
 	dupes               bool
 	unbalanced          bool
 	commodityMismatches bool
+	unredacted          bool
+	transfers           bool
+	days                int
 	begin, end          coin.Date
 }
 
@@ -29,6 +35,9 @@ Perform checks and print statistics about the ledger.`)
 	cmd.BoolVar(&cmd.dupes, "d", false, "check for duplicate transactions")
 	cmd.BoolVar(&cmd.unbalanced, "u", false, "check for unbalanced transactions")
 	cmd.BoolVar(&cmd.commodityMismatches, "c", false, "check for commodity mismatches")
+	cmd.BoolVar(&cmd.unredacted, "r", false, "check for unredacted card, phone and account numbers")
+	cmd.BoolVar(&cmd.transfers, "t", false, "check for transfers between accounts posted to Unbalanced on both sides")
+	cmd.IntVar(&cmd.days, "days", 3, "maximum date difference in days of transfers")
 	cmd.Var(&cmd.begin, "b", "begin register from this date")
 	cmd.Var(&cmd.end, "e", "end register on this date")
 	return &cmd
@@ -65,7 +74,34 @@ func (cmd *cmdStats) execute(f io.Writer) {
 		return
 	}
 
+	if cmd.transfers {
+		for _, tr := range importer.FindTransfers(transactions, cmd.days) {
+			fmt.Fprintf(f,
+				"TRANSFER?\n%s\n%s\n%s\n%s\n",
+				tr.First.Location(), tr.First,
+				tr.Second.Location(), tr.Second)
+		}
+		return
+	}
+
+	red := redact.Builtin()
 	for _, t := range transactions {
+		if cmd.unredacted {
+			lines := append([]string{t.Description}, t.Notes...)
+			for _, p := range t.Postings {
+				lines = append(lines, p.Notes...)
+			}
+			for _, l := range lines {
+				for _, m := range red.Find(l) {
+					fmt.Fprintf(f,
+						"UNREDACTED %s NUMBER? %s : %s\n",
+						strings.ToUpper(m.Kind),
+						m.Text,
+						t.Location(),
+					)
+				}
+			}
+		}
 		if cmd.commodityMismatches && len(t.Postings) == 2 {
 			if p1, p2 := t.Postings[0], t.Postings[1]; p1.Quantity.IsEqual(p2.Quantity.Negated()) &&
 				p1.Quantity.Commodity != p2.Quantity.Commodity {
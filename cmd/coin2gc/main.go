@@ -0,0 +1,46 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/mkobetic/coin"
+	"github.com/mkobetic/coin/check"
+	"github.com/mkobetic/coin/gnucash"
+)
+
+const usage = `Usage: coin2gc [flags] FILE
+
+Converts the coin database ($COINDB) to a GnuCash XML database (v2) FILE (gzipped).
+
+Flags:`
+
+var (
+	begin, end coin.Date
+)
+
+func init() {
+	flag.Var(&begin, "b", "export transactions from this date, with the opening balances as of the date")
+	flag.Var(&end, "e", "export transactions until this date (excluded)")
+	flag.Usage = func() {
+		w := flag.CommandLine.Output()
+		fmt.Fprintln(w, usage)
+		flag.PrintDefaults()
+	}
+}
+
+func main() {
+	flag.Parse()
+	if flag.NArg() != 1 {
+		fmt.Println("missing gnucash db filename")
+		flag.Usage()
+		os.Exit(1)
+	}
+	coin.LoadAll()
+	book, err := gnucash.NewBook(begin.Time, end.Time)
+	check.NoError(err, "Failed to convert the ledger")
+	check.NoError(book.Save(flag.Arg(0)), "Failed to write %s", flag.Arg(0))
+	fmt.Fprintf(os.Stderr, "Accounts: %d\nTransactions: %d\nPrices: %d\n",
+		len(book.Accounts), len(book.Transactions), len(book.Prices))
+}
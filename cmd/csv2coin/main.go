@@ -1,19 +1,16 @@
 package main
 
 import (
-	"encoding/csv"
 	"flag"
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
-	"sort"
-	"strconv"
-	"strings"
-	"time"
 
 	"github.com/mkobetic/coin"
 	"github.com/mkobetic/coin/check"
+	"github.com/mkobetic/coin/classify"
+	"github.com/mkobetic/coin/importer"
+	"github.com/mkobetic/coin/importer/csv"
 )
 
 const usage = `Usage: csv2coin [flags] files...
@@ -23,9 +20,15 @@ Converts CSV files to coin transactions based on a set of rules (see README).
 Flags:`
 
 var (
-	fields    = flag.String("fields", "", "ordered list of column indexes to use as transaction fields")
-	source    = flag.String("source", "", "which source rules to use to read the files")
-	dumpRules = flag.Bool("rules", false, "dump the loaded account rules (useful for formatting)")
+	fields         = flag.String("fields", "", "ordered list of column indexes to use as transaction fields")
+	source         = flag.String("source", "", "which source rules to use to read the files")
+	dumpRules      = flag.Bool("rules", false, "dump the loaded account rules (useful for formatting)")
+	keepDupes      = flag.Bool("keep-dupes", false, "keep duplicate transactions")
+	dupeDays       = flag.Int("dupe-days", 3, "maximum date difference in days of duplicate transactions and transfers")
+	dupeSimilarity = flag.Float64("dupe-similarity", 0.5, "minimum description similarity (0 to 1) of duplicate transactions")
+	review         = flag.String("review", "", "write possible duplicates that were kept to this file")
+	learn          = flag.Bool("learn", false, "predict the accounts of transactions not matching any rule from the ledger")
+	confidence     = flag.Float64("confidence", 0.5, "minimum confidence (0 to 1) of predicted accounts")
 )
 
 func init() {
@@ -38,197 +41,55 @@ func init() {
 
 func main() {
 	flag.Parse()
+	coin.LoadAll()
 
-	coin.LoadFile(coin.CommoditiesFile)
-	coin.LoadFile(coin.AccountsFile)
-	coin.ResolveAccounts()
-
-	var rules *Rules
+	var rules *csv.Rules
 	fn := filepath.Join(coin.DB, "csv.rules")
 	if _, err := os.Stat(fn); !os.IsNotExist(err) {
 		file, err := os.Open(fn)
 		check.NoError(err, "Failed to open %s", fn)
 		defer file.Close()
-		rules = ReadRules(file)
+		rules = csv.ReadRules(file)
+	} else {
+		rules = &csv.Rules{}
 	}
+	rules.RuleIndex = coin.ImportRules.Merge(rules.RuleIndex)
 
 	if *dumpRules {
 		rules.Write(os.Stdout)
 		return
 	}
 
-	var src *Source
+	var src *csv.Source
 	if *fields != "" {
-		src = &Source{fields: parseFields(*fields)}
+		src = csv.FieldsSource(*fields)
 	} else if *source != "" {
-		src = rules.sources[*source]
+		src = rules.Source(*source)
 		check.If(src != nil, "Unknown source %s", *source)
 	} else {
 		fmt.Fprintf(os.Stderr, "One of -source or -fields must be specified\n")
 		os.Exit(1)
 	}
 
-	var transactions coin.TransactionsByTime
+	p := importer.NewPipeline()
+	p.KeepDupes = *keepDupes
+	p.Days = *dupeDays
+	p.Similarity = *dupeSimilarity
+	if *learn {
+		p.Classifier = classify.New(coin.Transactions, *confidence)
+	}
+	if *review != "" {
+		file, err := os.Create(*review)
+		check.NoError(err, "Failed to create %s", *review)
+		defer file.Close()
+		p.Review = file
+	}
+	imp := &csv.Importer{Rules: rules, Source: src}
 	for _, fileName := range flag.Args() {
 		file, err := os.Open(fileName)
 		check.NoError(err, "Failed to open %s", fileName)
 		defer file.Close()
-
-		batch := readTransactions(file, src, rules)
-		transactions = append(transactions, batch...)
-	}
-
-	// write transactions
-	sort.Stable(transactions)
-
-	for _, t := range transactions {
-		t.Write(os.Stdout, false)
-		fmt.Fprintln(os.Stdout)
-	}
-}
-
-func readTransactions(in io.Reader, src *Source, rules *Rules) (transactions []*coin.Transaction) {
-	r := csv.NewReader(in)
-	for i := 0; i < src.skip; i++ {
-		row, err := r.Read()
-		if err, ok := err.(*csv.ParseError); ok && err.Err == csv.ErrFieldCount {
-			r.FieldsPerRecord = len(row)
-			continue
-		}
-		check.NoError(err, "Failed to read header line %d", i)
-	}
-	for {
-		rec, err := r.Read()
-		if err == io.EOF {
-			break
-		}
-		check.NoError(err, "reading transaction")
-		if nt := transactionFrom(rec, src.fields, rules); nt != nil {
-			transactions = append(transactions, nt)
-		}
-	}
-	return transactions
-}
-
-// transactionFrom builds a transaction from a csv row.
-func transactionFrom(row []string, fields map[string]Fields, allRules *Rules) *coin.Transaction {
-	valueFor := func(name string) string {
-		check.Includes(labels, name, "Invalid field name")
-		return fields[name].Value(row, fields)
-	}
-	acctId := valueFor("account")
-	description := trim(valueFor("description"))
-	rules := allRules.AccountRulesFor(acctId)
-	check.If(rules != nil, "Can't find rules for account id %s", acctId)
-	account := rules.Account
-	toAccount := coin.Unbalanced
-	var notes []string
-	if rule := rules.RuleFor(description); rule != nil {
-		if rule.Account == nil {
-			// drop transaction
-			return nil
-		}
-		toAccount = rule.Account
-		notes = rule.Notes
-	}
-
-	date := valueFor("date")
-	posted, err := time.Parse(coin.DateFormat, date)
-	check.NoError(err, "Parsing date %s", date)
-
-	t := &coin.Transaction{
-		Description: description,
-		Posted:      posted,
-		Notes:       notes,
-	}
-
-	if n := trim(valueFor("note")); len(n) > 0 {
-		t.Notes = []string{n}
-	}
-
-	var amount *coin.Amount
-	if amt := valueFor("amount"); amt != "" {
-		commodity := toAccount.Commodity
-		if currency := valueFor("currency"); currency != "" {
-			var found bool
-			commodity, found = coin.Commodities[currency]
-			if !found {
-				commodity, found = coin.CommoditiesBySymbol[currency]
-			}
-			check.If(found, "unknown currency %s", currency)
-		}
-		amount = coin.MustParseAmount(amt, commodity)
-	}
-	check.If(amount != nil, "amount not found!")
-	// refine toAccount based on the amount commodity
-	toAccount = findAccountForCommodity(amount.Commodity, toAccount)
-
-	symbol := valueFor("symbol")
-	if symbol == "" {
-		fromAccount := findAccountForCommodity(amount.Commodity, account)
-		t.Post(fromAccount, toAccount, amount, nil)
-		return t
-	}
-
-	commodity, found := coin.Commodities[symbol]
-	if !found {
-		commodity, found = coin.CommoditiesBySymbol[symbol]
-	}
-	check.OK(found, "Could not find commodity for symbol %s", symbol)
-
-	var quantity *coin.Amount
-	if amt := valueFor("quantity"); amt != "" {
-		quantity = coin.MustParseAmount(amt, commodity)
-	}
-
-	if quantity == nil {
-		fromAccount := findAccountForCommodity(amount.Commodity, account)
-		t.Post(fromAccount, toAccount, amount, nil)
-		return t
-	}
-
-	// Quantity and amount cannot be both positive or negative,
-	// if they are amount wins, make quantity the opposite.
-	if quantity.Sign()*amount.Sign() > 0 {
-		quantity = quantity.Negated()
-	}
-
-	// refine fromAccount based on the quantity commodity
-	fromAccount := findAccountForCommodity(quantity.Commodity, account)
-	t.PostConversion(fromAccount, quantity, nil, toAccount, amount, nil)
-	return t
-}
-
-func findAccountForCommodity(c *coin.Commodity, root *coin.Account) *coin.Account {
-	account := coin.Unbalanced
-	root.FirstWithChildrenDo(func(a *coin.Account) {
-		if a.Commodity == c && account == coin.Unbalanced {
-			account = a
-		}
-	})
-	return account
-}
-
-var labels = []string{
-	"account",     //target account ID
-	"description", // transaction description
-	"date",        // date of the transaction
-	"amount",      // the cost of the transaction
-	"currency",    // optional currency of the transaction cost
-	"symbol",      // optional symbol of the commodity that was traded
-	"quantity",    // optional quantity of the commodity that was traded
-	"note",        // optional note associated with the transaction
-}
-
-func parseFields(list string) map[string]Fields {
-	idxs := strings.Split(list, ",")
-	check.If(len(idxs) == len(labels),
-		"%d fields must be specified:\n%v\n", len(labels), labels)
-	fields := make(map[string]Fields)
-	for i, s := range idxs {
-		c, err := strconv.Atoi(s)
-		check.NoError(err, "%s is not a valid column index", i)
-		fields[labels[i]] = Fields{&Field{&c, "", "", nil}}
+		check.NoError(p.Read(imp, file), "Cannot parse file %s", fileName)
 	}
-	return fields
+	p.Write(os.Stdout)
 }
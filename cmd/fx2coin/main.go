@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mkobetic/coin"
+	"github.com/mkobetic/coin/check"
+	"github.com/mkobetic/coin/fx"
+)
+
+const usage = `Usage: fx2coin [flags] files...
+
+Converts central bank exchange rate files into coin prices.
+Supported formats are ECB reference rates (XML or CSV) and Bank of Canada Valet observations (JSON).
+Prices are generated for every commodity whose id matches a currency code in the files.
+
+Flags:`
+
+var (
+	currency  = flag.String("c", "", "currency to express the prices in (default: default commodity)")
+	keepDupes = flag.Bool("keep-dupes", false, "keep prices that are already in the ledger")
+)
+
+func init() {
+	flag.Usage = func() {
+		w := flag.CommandLine.Output()
+		fmt.Fprintln(w, usage)
+		flag.PrintDefaults()
+	}
+}
+
+func main() {
+	flag.Parse()
+	coin.LoadPrices()
+	coin.ResolvePrices()
+
+	target := coin.DefaultCommodity()
+	if *currency != "" {
+		target = coin.MustFindCommodity(*currency, "-c flag")
+	}
+
+	var all []*fx.Rates
+	for _, fileName := range flag.Args() {
+		file, err := os.Open(fileName)
+		check.NoError(err, "Failed to open %s", fileName)
+		rates, err := readRates(file, fileName)
+		file.Close()
+		check.NoError(err, "Cannot parse file %s", fileName)
+		all = append(all, rates...)
+	}
+
+	for _, p := range pricesFrom(all, target) {
+		if !*keepDupes && isKnown(p) {
+			continue
+		}
+		p.Write(os.Stdout, false)
+	}
+}
+
+// readRates picks the reader based on the file extension or the file content.
+func readRates(r io.Reader, fileName string) ([]*fx.Rates, error) {
+	br := bufio.NewReader(r)
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".xml":
+		return fx.ReadECBXML(br)
+	case ".csv":
+		return fx.ReadECBCSV(br)
+	case ".json":
+		return fx.ReadValet(br)
+	}
+	head, _ := br.Peek(512)
+	head = bytes.TrimSpace(head)
+	switch {
+	case bytes.HasPrefix(head, []byte("<")):
+		return fx.ReadECBXML(br)
+	case bytes.HasPrefix(head, []byte("{")):
+		return fx.ReadValet(br)
+	}
+	return fx.ReadECBCSV(br)
+}
+
+// pricesFrom generates prices in the target currency for every commodity that has a rate.
+func pricesFrom(all []*fx.Rates, target *coin.Commodity) (prices []*coin.Price) {
+	for _, rates := range all {
+		coin.CommoditiesDo(func(c *coin.Commodity) {
+			if c == target {
+				return
+			}
+			value := rates.Rate(c.Id, target.Id)
+			if value == nil {
+				return
+			}
+			prices = append(prices, &coin.Price{
+				Commodity: c,
+				Currency:  target,
+				Value:     coin.MustParseAmount(value.FloatString(target.Decimals), target), // rounded
+				Time:      rates.Date,
+			})
+		})
+	}
+	return prices
+}
+
+// isKnown checks if there's already a price for the same commodity, currency and date.
+func isKnown(p *coin.Price) bool {
+	for _, p2 := range p.Commodity.Prices[p.Currency] {
+		if p2.Time.Equal(p.Time) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mkobetic/coin"
+	"github.com/mkobetic/coin/assert"
+)
+
+func init() {
+	r := strings.NewReader(`
+commodity CAD
+  format 1.0000 CAD
+
+commodity USD
+  format 1.0000 USD
+
+commodity EUR
+  format 1.0000 EUR
+
+commodity VXF
+  format 1.000 VXF
+
+P 2024/01/02 USD 1.3316 CAD
+`)
+	coin.Load(r, "")
+	coin.ResolvePrices()
+}
+
+const valet = `{
+"observations": [
+	{"d": "2024-01-02", "FXUSDCAD": {"v": "1.3316"}, "FXEURCAD": {"v": "1.4565"}},
+	{"d": "2024-01-03", "FXUSDCAD": {"v": "1.3343"}, "FXEURCAD": {"v": "1.4585"}, "FXJPYCAD": {"v": "0.009224"}}
+]
+}`
+
+func Test_PricesFrom(t *testing.T) {
+	all, err := readRates(strings.NewReader(valet), "observations")
+	assert.NoError(t, err)
+	var got []string
+	for _, p := range pricesFrom(all, coin.Commodities["CAD"]) {
+		line := p.String()
+		if isKnown(p) {
+			line = "KNOWN " + line
+		}
+		got = append(got, line)
+	}
+	assert.EqualStrings(t, got,
+		"P 2024/01/02 EUR 1.4565 CAD\n",
+		"KNOWN P 2024/01/02 USD 1.3316 CAD\n",
+		"P 2024/01/03 EUR 1.4585 CAD\n",
+		"P 2024/01/03 USD 1.3343 CAD\n",
+	)
+
+	got = nil
+	for _, p := range pricesFrom(all, coin.Commodities["USD"]) {
+		got = append(got, p.String())
+	}
+	assert.EqualStrings(t, got,
+		"P 2024/01/02 CAD 0.7510 USD\n",
+		"P 2024/01/02 EUR 1.0938 USD\n",
+		"P 2024/01/03 CAD 0.7495 USD\n",
+		"P 2024/01/03 EUR 1.0931 USD\n",
+	)
+}
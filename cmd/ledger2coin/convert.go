@@ -0,0 +1,125 @@
+package main
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/mkobetic/coin"
+	"github.com/mkobetic/coin/convert"
+)
+
+// convert maps the journal to coin objects.
+// Coin accounts hold a single commodity, postings in other commodities
+// are moved to sub-accounts named after the commodity.
+func (j *journal) convert() *convert.Ledger {
+	l := &convert.Ledger{}
+	commodities := map[string]*coin.Commodity{}
+	var defaultC *commodity
+	for _, c := range j.commodities {
+		cc := commodities[c.id]
+		if cc == nil {
+			cc = &coin.Commodity{Id: c.id}
+			commodities[c.id] = cc
+			l.Commodities = append(l.Commodities, cc)
+		}
+		if cc.Name == "" {
+			cc.Name = c.name
+		}
+		if c.decimals > cc.Decimals {
+			cc.Decimals = c.decimals
+		}
+		cc.NoMarket = cc.NoMarket || c.nomarket
+		if defaultC == nil || c.uses > defaultC.uses || (c.uses == defaultC.uses && c.id < defaultC.id) {
+			defaultC = c
+		}
+	}
+	sort.Slice(l.Commodities, func(i, j int) bool { return l.Commodities[i].Id < l.Commodities[j].Id })
+	if j.defaultC != nil {
+		defaultC = j.defaultC
+	}
+	if defaultC != nil {
+		l.Default = commodities[defaultC.id]
+	}
+
+	accounts := map[string]*coin.Account{}
+	newAccount := func(name, description string, c *coin.Commodity) *coin.Account {
+		a := &coin.Account{
+			Name:        name[strings.LastIndex(name, ":")+1:],
+			FullName:    name,
+			Description: description,
+			CommodityId: c.Id,
+			Commodity:   c,
+		}
+		accounts[name] = a
+		l.Accounts = append(l.Accounts, a)
+		return a
+	}
+	accountFor := func(a *account, c *coin.Commodity, location string) *coin.Account {
+		ca := accounts[a.name]
+		if ca == nil {
+			return newAccount(a.name, a.description, c)
+		}
+		if ca.Commodity == c {
+			return ca
+		}
+		name := a.name + ":" + c.Id
+		if sub := accounts[name]; sub != nil {
+			return sub
+		}
+		j.warn(location, "%s postings in %s are moved to %s", c.Id, a.name, name)
+		return newAccount(name, "", c)
+	}
+
+	sort.SliceStable(j.transactions, func(a, b int) bool {
+		return j.transactions[a].date.Before(j.transactions[b].date)
+	})
+	for _, t := range j.transactions {
+		ct := &coin.Transaction{
+			Posted:      t.date,
+			Code:        t.code,
+			Description: t.description,
+			Notes:       t.notes,
+		}
+		for _, s := range t.postings {
+			c := commodities[s.amount.commodity.id]
+			cs := &coin.Posting{
+				Transaction: ct,
+				Account:     accountFor(s.account, c, t.location),
+				Quantity:    convert.Round(s.amount.value, c),
+				Notes:       s.notes,
+			}
+			if s.balance != nil {
+				if bc := commodities[s.balance.commodity.id]; bc == c {
+					cs.Balance = convert.Round(s.balance.value, c)
+					cs.BalanceAsserted = true
+				} else {
+					j.warn(t.location, "ignoring %s balance assertion on %s posting", bc.Id, c.Id)
+				}
+			}
+			ct.Postings = append(ct.Postings, cs)
+		}
+		l.Transactions = append(l.Transactions, ct)
+	}
+
+	// declared accounts without postings
+	for _, a := range j.accounts {
+		if a.declared && accounts[a.name] == nil && l.Default != nil {
+			newAccount(a.name, a.description, l.Default)
+		}
+	}
+	sort.Slice(l.Accounts, func(i, j int) bool { return l.Accounts[i].FullName < l.Accounts[j].FullName })
+
+	sort.SliceStable(j.prices, func(a, b int) bool {
+		return j.prices[a].date.Before(j.prices[b].date)
+	})
+	for _, p := range j.prices {
+		currency := commodities[p.value.commodity.id]
+		l.Prices = append(l.Prices, &coin.Price{
+			Commodity: commodities[p.commodity.id],
+			Currency:  currency,
+			Value:     convert.Round(p.value.value, currency),
+			Time:      p.date,
+		})
+	}
+	return l
+}
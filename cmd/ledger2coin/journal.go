@@ -0,0 +1,579 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mkobetic/coin/convert"
+)
+
+// The journal is parsed into an intermediate model first,
+// because commodity precision and account commodities are known
+// only after all the transactions were read.
+
+type commodity struct {
+	symbol   string // ledger symbol, e.g. $ or "ABC 123"
+	id       string // coin id
+	name     string
+	decimals int
+	nomarket bool
+	uses     int // number of postings in this commodity
+}
+
+type amount struct {
+	value     *big.Rat
+	commodity *commodity
+}
+
+type account struct {
+	name        string // coin full name
+	description string
+	declared    bool // declared with the account directive
+}
+
+type posting struct {
+	account *account
+	amount  *amount // nil if elided
+	cost    *amount // total cost, nil if none
+	balance *amount // asserted balance, nil if none
+	notes   []string
+}
+
+type transaction struct {
+	date        time.Time
+	code        string
+	description string
+	notes       []string
+	postings    []*posting
+	location    string
+	invalid     bool // set if any of the postings couldn't be parsed
+}
+
+type price struct {
+	date      time.Time
+	commodity *commodity
+	value     *amount
+}
+
+type journal struct {
+	commodities  map[string]*commodity // by ledger symbol
+	accounts     map[string]*account   // by ledger name
+	aliases      map[string]string
+	symbols      map[string]string // ledger symbol => coin id
+	transactions []*transaction
+	prices       []*price
+	defaultC     *commodity
+	year         int
+	log          io.Writer
+}
+
+func newJournal(symbols map[string]string, log io.Writer) *journal {
+	return &journal{
+		commodities: map[string]*commodity{},
+		accounts:    map[string]*account{},
+		aliases:     map[string]string{},
+		symbols:     symbols,
+		log:         log,
+	}
+}
+
+func (j *journal) warn(location string, format string, args ...interface{}) {
+	fmt.Fprintf(j.log, "%s: %s\n", location, fmt.Sprintf(format, args...))
+}
+
+func (j *journal) readFile(fn string) error {
+	file, err := os.Open(fn)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return j.read(file, fn)
+}
+
+const (
+	inNothing = iota
+	inTransaction
+	inCommodity
+	inAccount
+	inSkipped
+)
+
+var (
+	transactionREX = regexp.MustCompile(`^(?P<date>[\d/.\-]+)(=[\d/.\-]+)?(\s+[*!])?(\s+\((?P<code>[^)]*)\))?(\s+(?P<payee>[^;]*?))?\s*(;\s*(?P<note>.*))?$`)
+	priceREX       = regexp.MustCompile(`^P\s+(?P<date>[\d/.\-]+)(\s+\d\d:\d\d(:\d\d)?)?\s+(?P<symbol>"[^"]+"|[^\s\d.,\-+"]+)\s+(?P<amount>.+)$`)
+	postingREX     = regexp.MustCompile(`^(?P<amount>[^@={}\[\]()]*?)\s*(?P<lot>\{\{?[^}]*\}\}?)?\s*(\[[^\]]*\])?\s*(\([^)]*\))?\s*((?P<at>@@?)\s*(?P<price>[^=]*?))?\s*(?P<assert>=[=*]*\s*(?P<balance>.*))?$`)
+	codeREX        = regexp.MustCompile(`^\w+$`)
+	separatorREX   = regexp.MustCompile(`\t|  `)
+	amountREX      = regexp.MustCompile(`^(?P<sign1>-)?\s*(?P<prefix>"[^"]+"|[^\s\d.,\-+"]+)?\s*(?P<sign2>-)?\s*(?P<number>\d[\d,]*(\.\d*)?|\.\d+)\s*(?P<suffix>"[^"]+"|[^\s\d.,\-+"]+)?$`)
+)
+
+// read parses ledger journal from r, fn is used for error locations and to resolve includes.
+// Unsupported constructs are reported to the log and skipped.
+func (j *journal) read(r io.Reader, fn string) error {
+	scanner := bufio.NewScanner(r)
+	var lineNr int
+	var state int
+	var t *transaction
+	var c *commodity
+	var a *account
+	var inComment bool
+	flush := func() {
+		if t != nil {
+			j.finish(t)
+			t = nil
+		}
+	}
+	for scanner.Scan() {
+		lineNr++
+		location := fmt.Sprintf("%s:%d", fn, lineNr)
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if inComment {
+			inComment = !strings.HasPrefix(line, "end")
+			continue
+		}
+		if line == "" {
+			flush()
+			state = inNothing
+			continue
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			line = strings.TrimSpace(line)
+			switch state {
+			case inTransaction:
+				j.readPosting(t, line, location)
+			case inCommodity:
+				j.readCommodityLine(c, line, location)
+			case inAccount:
+				j.readAccountLine(a, line, location)
+			case inSkipped:
+			default:
+				if line[0] != ';' {
+					j.warn(location, "unexpected indented line: %s", line)
+				}
+			}
+			continue
+		}
+		flush()
+		state = inNothing
+		switch {
+		case strings.ContainsRune(";#%|*", rune(line[0])):
+			// comment
+		case line[0] >= '0' && line[0] <= '9':
+			t = j.readTransaction(line, location)
+			if t != nil {
+				state = inTransaction
+			} else {
+				state = inSkipped
+			}
+		case line[0] == '=' || line[0] == '~':
+			j.warn(location, "automated and periodic transactions are not supported")
+			state = inSkipped
+		default:
+			directive, arg, _ := strings.Cut(line, " ")
+			arg, _, _ = strings.Cut(arg, ";")
+			arg = strings.TrimSpace(arg)
+			switch directive {
+			case "P":
+				j.readPrice(line, location)
+			case "D":
+				if amt := j.parseAmount(arg, location, true); amt != nil {
+					j.defaultC = amt.commodity
+				}
+			case "Y", "year":
+				year, err := strconv.Atoi(arg)
+				if err != nil {
+					j.warn(location, "invalid year: %s", arg)
+				}
+				j.year = year
+			case "include":
+				if err := j.include(arg, fn, location); err != nil {
+					return err
+				}
+			case "alias":
+				alias, name, ok := strings.Cut(arg, "=")
+				if !ok {
+					j.warn(location, "invalid alias: %s", arg)
+					continue
+				}
+				j.aliases[strings.TrimSpace(alias)] = strings.TrimSpace(name)
+			case "commodity":
+				// hledger allows declaring the format directly, e.g. commodity $1,000.00
+				if !amountREX.MatchString(arg) {
+					c = j.commodity(arg, location)
+				} else if amt := j.parseAmount(arg, location, true); amt != nil {
+					c = amt.commodity
+				} else {
+					state = inSkipped
+					continue
+				}
+				state = inCommodity
+			case "account":
+				name, _, _ := strings.Cut(arg, "  ")
+				a = j.account(name)
+				a.declared = true
+				state = inAccount
+			case "comment", "test":
+				inComment = true
+			default:
+				j.warn(location, "unsupported directive: %s", directive)
+				state = inSkipped
+			}
+		}
+	}
+	flush()
+	return scanner.Err()
+}
+
+func (j *journal) include(pattern, fn string, location string) error {
+	if !filepath.IsAbs(pattern) {
+		pattern = filepath.Join(filepath.Dir(fn), pattern)
+	}
+	files, err := filepath.Glob(pattern)
+	if err != nil || len(files) == 0 {
+		j.warn(location, "no files to include: %s", pattern)
+		return nil
+	}
+	for _, f := range files {
+		if err := j.readFile(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (j *journal) readCommodityLine(c *commodity, line string, location string) {
+	directive, arg, _ := strings.Cut(line, " ")
+	arg = strings.TrimSpace(arg)
+	switch directive {
+	case "note":
+		c.name = arg
+	case "format":
+		j.parseAmount(arg, location, true)
+	case "nomarket":
+		c.nomarket = true
+	case "default":
+		j.defaultC = c
+	default:
+		if line[0] != ';' {
+			j.warn(location, "ignoring commodity %s: %s", c.symbol, line)
+		}
+	}
+}
+
+func (j *journal) readAccountLine(a *account, line string, location string) {
+	directive, arg, _ := strings.Cut(line, " ")
+	arg = strings.TrimSpace(arg)
+	switch directive {
+	case "note":
+		a.description = arg
+	case "alias":
+		j.accounts[arg] = a
+	default:
+		if line[0] != ';' {
+			j.warn(location, "ignoring account %s: %s", a.name, line)
+		}
+	}
+}
+
+func (j *journal) readPrice(line string, location string) {
+	match := priceREX.FindStringSubmatch(line)
+	if match == nil {
+		j.warn(location, "invalid price: %s", line)
+		return
+	}
+	date, err := j.parseDate(match[priceREX.SubexpIndex("date")])
+	if err != nil {
+		j.warn(location, "%s", err)
+		return
+	}
+	value := j.parseAmount(match[priceREX.SubexpIndex("amount")], location, false)
+	if value == nil {
+		return
+	}
+	j.prices = append(j.prices, &price{
+		date:      date,
+		commodity: j.commodity(match[priceREX.SubexpIndex("symbol")], location),
+		value:     value,
+	})
+}
+
+func (j *journal) readTransaction(line string, location string) *transaction {
+	match := transactionREX.FindStringSubmatch(line)
+	if match == nil {
+		j.warn(location, "invalid transaction: %s", line)
+		return nil
+	}
+	date, err := j.parseDate(match[transactionREX.SubexpIndex("date")])
+	if err != nil {
+		j.warn(location, "%s", err)
+		return nil
+	}
+	t := &transaction{
+		date:        date,
+		description: match[transactionREX.SubexpIndex("payee")],
+		location:    location,
+	}
+	if code := match[transactionREX.SubexpIndex("code")]; code != "" {
+		if codeREX.MatchString(code) {
+			t.code = code
+		} else {
+			t.notes = append(t.notes, "code: "+code)
+		}
+	}
+	if note := match[transactionREX.SubexpIndex("note")]; note != "" {
+		t.notes = append(t.notes, note)
+	}
+	return t
+}
+
+func (j *journal) readPosting(t *transaction, line string, location string) {
+	if line[0] == ';' {
+		note := strings.TrimSpace(line[1:])
+		if len(t.postings) == 0 {
+			t.notes = append(t.notes, note)
+		} else {
+			s := t.postings[len(t.postings)-1]
+			s.notes = append(s.notes, note)
+		}
+		return
+	}
+	raw, flagged := line, false
+	if line[0] == '*' || line[0] == '!' {
+		line, flagged = strings.TrimSpace(line[1:]), true
+	}
+	var note string
+	if i := strings.Index(line, ";"); i >= 0 {
+		note = strings.TrimSpace(line[i+1:])
+		line = strings.TrimSpace(line[:i])
+	}
+	name, rest := line, ""
+	if i := separatorREX.FindStringIndex(line); i != nil {
+		name, rest = line[:i[0]], strings.TrimSpace(line[i[1]:])
+	}
+	if len(name) == 0 {
+		j.warn(location, "invalid posting: %s", raw)
+		return
+	}
+	if name[0] == '(' || name[0] == '[' {
+		j.warn(location, "virtual postings are not supported: %s", line)
+		return
+	}
+	if flagged {
+		j.warn(location, "ignoring posting flag: %s", raw)
+	}
+	s := &posting{account: j.account(name)}
+	if note != "" {
+		s.notes = append(s.notes, note)
+	}
+	if strings.HasPrefix(rest, "(") {
+		j.warn(location, "amount expressions are not supported: %s", rest)
+		t.invalid = true
+		return
+	}
+	match := postingREX.FindStringSubmatch(rest)
+	if match == nil {
+		j.warn(location, "invalid posting: %s", line)
+		t.invalid = true
+		return
+	}
+	if amt := match[postingREX.SubexpIndex("amount")]; amt != "" {
+		s.amount = j.parseAmount(amt, location, true)
+		if s.amount == nil {
+			t.invalid = true
+			return
+		}
+		s.amount.commodity.uses++
+	}
+	if p := match[postingREX.SubexpIndex("price")]; p != "" && s.amount != nil {
+		s.cost = j.parseAmount(p, location, false)
+		if s.cost != nil && match[postingREX.SubexpIndex("at")] == "@" {
+			s.cost.value.Mul(s.cost.value, s.amount.value)
+		}
+	} else if lot := match[postingREX.SubexpIndex("lot")]; lot != "" && s.amount != nil {
+		total := strings.HasPrefix(lot, "{{")
+		s.cost = j.parseAmount(strings.Trim(lot, "{}="), location, false)
+		if s.cost != nil && !total {
+			s.cost.value.Mul(s.cost.value, s.amount.value)
+		}
+	}
+	if s.cost != nil && s.cost.value.Sign() != s.amount.value.Sign() {
+		s.cost.value.Neg(s.cost.value)
+	}
+	if match[postingREX.SubexpIndex("assert")] != "" {
+		if strings.Contains(match[postingREX.SubexpIndex("assert")], "*") {
+			j.warn(location, "ignoring inclusive balance assertion: %s", rest)
+		} else if s.amount == nil {
+			j.warn(location, "balance assignments are not supported: %s", rest)
+			t.invalid = true
+			return
+		} else {
+			s.balance = j.parseAmount(match[postingREX.SubexpIndex("balance")], location, true)
+		}
+	}
+	t.postings = append(t.postings, s)
+}
+
+// finish computes the elided posting amounts and records the prices implied by posting costs.
+func (j *journal) finish(t *transaction) {
+	if t.invalid || len(t.postings) == 0 {
+		j.warn(t.location, "skipping transaction")
+		return
+	}
+	var elided *posting
+	totals := map[*commodity]*big.Rat{}
+	var order []*commodity
+	add := func(a *amount) {
+		if totals[a.commodity] == nil {
+			totals[a.commodity] = new(big.Rat)
+			order = append(order, a.commodity)
+		}
+		totals[a.commodity].Add(totals[a.commodity], a.value)
+	}
+	for _, s := range t.postings {
+		switch {
+		case s.amount == nil && elided != nil:
+			j.warn(t.location, "skipping transaction with multiple postings without amount")
+			return
+		case s.amount == nil:
+			elided = s
+		case s.cost != nil:
+			add(s.cost)
+			unit := new(big.Rat).Quo(s.cost.value, s.amount.value)
+			j.prices = append(j.prices, &price{
+				date:      t.date,
+				commodity: s.amount.commodity,
+				value:     &amount{unit, s.cost.commodity},
+			})
+		default:
+			add(s.amount)
+		}
+	}
+	if elided != nil {
+		var postings []*posting
+		for _, s := range t.postings {
+			if s != elided {
+				postings = append(postings, s)
+				continue
+			}
+			for _, c := range order {
+				if totals[c].Sign() == 0 {
+					continue
+				}
+				postings = append(postings, &posting{
+					account: s.account,
+					amount:  &amount{new(big.Rat).Neg(totals[c]), c},
+					notes:   s.notes,
+				})
+			}
+		}
+		t.postings = postings
+	} else if len(order) == 1 && totals[order[0]].Sign() != 0 {
+		j.warn(t.location, "skipping unbalanced transaction")
+		return
+	}
+	j.transactions = append(j.transactions, t)
+}
+
+// parseDate parses Y/M/D or M/D dates (with year from the year directive),
+// using any of / - . as the separator.
+func (j *journal) parseDate(s string) (time.Time, error) {
+	parts := strings.FieldsFunc(s, func(r rune) bool { return r == '/' || r == '-' || r == '.' })
+	var ymd []int
+	for _, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid date: %s", s)
+		}
+		ymd = append(ymd, n)
+	}
+	switch {
+	case len(ymd) == 2 && j.year > 0:
+		ymd = append([]int{j.year}, ymd...)
+	case len(ymd) != 3:
+		return time.Time{}, fmt.Errorf("invalid date: %s", s)
+	}
+	return time.Date(ymd[0], time.Month(ymd[1]), ymd[2], 12, 0, 0, 0, time.UTC), nil
+}
+
+// parseAmount parses ledger amounts like $-1,000.00, -10 "ABC 123" or 10.5 EUR.
+// Amounts without commodity use the default commodity.
+// If precision is set, the commodity decimals are updated to accommodate the amount.
+func (j *journal) parseAmount(s string, location string, precision bool) *amount {
+	match := amountREX.FindStringSubmatch(strings.TrimSpace(s))
+	if match == nil {
+		j.warn(location, "invalid amount: %s", s)
+		return nil
+	}
+	symbol := match[amountREX.SubexpIndex("prefix")]
+	if suffix := match[amountREX.SubexpIndex("suffix")]; suffix != "" {
+		if symbol != "" {
+			j.warn(location, "invalid amount: %s", s)
+			return nil
+		}
+		symbol = suffix
+	}
+	var c *commodity
+	if symbol != "" {
+		c = j.commodity(symbol, location)
+	} else if j.defaultC != nil {
+		c = j.defaultC
+	} else {
+		j.warn(location, "amount without commodity: %s", s)
+		return nil
+	}
+	number := strings.ReplaceAll(match[amountREX.SubexpIndex("number")], ",", "")
+	value, ok := new(big.Rat).SetString(number)
+	if !ok {
+		j.warn(location, "invalid amount: %s", s)
+		return nil
+	}
+	if (match[amountREX.SubexpIndex("sign1")] == "-") != (match[amountREX.SubexpIndex("sign2")] == "-") {
+		value.Neg(value)
+	}
+	if _, decimals, ok := strings.Cut(number, "."); ok && precision && len(decimals) > c.decimals {
+		c.decimals = len(decimals)
+	}
+	return &amount{value, c}
+}
+
+var invalidIdREX = regexp.MustCompile(`\W+`)
+
+func (j *journal) commodity(symbol string, location string) *commodity {
+	symbol = strings.TrimSpace(symbol)
+	if c := j.commodities[symbol]; c != nil {
+		return c
+	}
+	id := j.symbols[symbol]
+	if id == "" {
+		id = invalidIdREX.ReplaceAllString(strings.Trim(symbol, `"`), "")
+		if id == "" || !convert.IsLetter(id[0]) {
+			id = "C" + id
+		}
+		if id != symbol {
+			j.warn(location, "commodity %s is converted to %s", symbol, id)
+		}
+	}
+	c := &commodity{symbol: symbol, id: id}
+	j.commodities[symbol] = c
+	return c
+}
+
+func (j *journal) account(name string) *account {
+	if alias, ok := j.aliases[name]; ok {
+		name = alias
+	}
+	if a := j.accounts[name]; a != nil {
+		return a
+	}
+	a := &account{name: convert.AccountName(strings.ReplaceAll(name, " ", ""))}
+	j.accounts[name] = a
+	return a
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mkobetic/coin"
+	"github.com/mkobetic/coin/check"
+)
+
+const usage = `Usage: ledger2coin [flags] files...
+
+Converts ledger-cli or hledger journals to a coin database.
+Unsupported constructs are reported with their location and skipped.
+
+Flags:`
+
+var (
+	symbols = flag.String("s", "$=USD,€=EUR,£=GBP,¥=JPY", "comma separated list of symbol=commodity mappings")
+	yearly  = flag.Bool("y", false, "split transactions into separate files by year (requires COINDB directory)")
+)
+
+func init() {
+	flag.Usage = func() {
+		w := flag.CommandLine.Output()
+		fmt.Fprintln(w, usage)
+		flag.PrintDefaults()
+	}
+}
+
+func main() {
+	flag.Parse()
+	if flag.NArg() == 0 {
+		fmt.Println("missing journal file")
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *yearly && coin.DB == "" {
+		fmt.Println("-y requires $COINDB set")
+		flag.Usage()
+		os.Exit(1)
+	}
+	j := newJournal(parseSymbols(*symbols), os.Stderr)
+	for _, fn := range flag.Args() {
+		check.NoError(j.readFile(fn), "reading %s", fn)
+	}
+	j.convert().Save(*yearly)
+}
+
+func parseSymbols(list string) map[string]string {
+	symbols := map[string]string{}
+	for _, s := range strings.Split(list, ",") {
+		if symbol, id, ok := strings.Cut(s, "="); ok {
+			symbols[strings.TrimSpace(symbol)] = strings.TrimSpace(id)
+		}
+	}
+	return symbols
+}
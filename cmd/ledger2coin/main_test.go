@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/mkobetic/coin"
+	"github.com/mkobetic/coin/assert"
+)
+
+const sample = `; sample journal
+commodity $
+    note US Dollar
+    format $1,000.00
+
+account Assets:Checking Account
+    alias checking
+
+2020/01/01 * (101) Opening ; first note
+    checking                  $1,000.00
+    Equity:Opening Balances
+
+2020/01/05 ! Grocery Store
+    Expenses:Food             $45.67 ; posting note
+    * checking                = $954.33
+    * ; flagged note
+
+2020/01/10 Buy fund
+    Assets:Brokerage          2.5 "VANGUARD 500" @ $300.00
+    checking                  $-750.00 = $250.00
+
+2020-01-12 Paris
+    Expenses:Travel           100 EUR @@ $110
+    checking
+
+~ monthly
+    Expenses:Rent  $500
+    checking
+
+2020/01/13 Cash
+    checking                  -50 EUR
+    (Budget:Travel)           50 EUR
+    Expenses:Travel
+`
+
+func Test_Convert(t *testing.T) {
+	var log bytes.Buffer
+	j := newJournal(parseSymbols("$=USD"), &log)
+	assert.NoError(t, j.read(strings.NewReader(sample), "sample"))
+	assert.EqualStrings(t, strings.Split(strings.TrimSpace(log.String()), "\n"),
+		"sample:15: ignoring posting flag: * checking                = $954.33",
+		"sample:15: balance assignments are not supported: = $954.33",
+		"sample:16: invalid posting: * ; flagged note",
+		"sample:13: skipping transaction",
+		"sample:19: commodity \"VANGUARD 500\" is converted to VANGUARD500",
+		"sample:26: automated and periodic transactions are not supported",
+		"sample:32: virtual postings are not supported: (Budget:Travel)           50 EUR",
+	)
+	log.Reset()
+	l := j.convert()
+	assert.Equal(t, log.String(), "sample:30: EUR postings in Assets:CheckingAccount are moved to Assets:CheckingAccount:EUR\n")
+
+	var b bytes.Buffer
+	l.WriteCommodities(&b)
+	l.WriteAccounts(&b)
+	l.WritePrices(&b, l.Prices)
+	l.WriteTransactions(&b, l.Transactions)
+	assert.Equal(t, b.String(), `commodity EUR
+  format 1 EUR
+
+commodity USD
+  note US Dollar
+  format 1.00 USD
+  default
+
+commodity VANGUARD500
+  format 1.0 VANGUARD500
+
+account Assets:Brokerage
+  commodity VANGUARD500
+
+account Assets:CheckingAccount
+  commodity USD
+
+account Assets:CheckingAccount:EUR
+  commodity EUR
+
+account Equity:OpeningBalances
+  commodity USD
+
+account Expenses:Travel
+  commodity EUR
+
+P 2020/01/10 VANGUARD500 300.00 USD
+P 2020/01/12 EUR 1.10 USD
+2020/01/01 (101) Opening ; first note
+  Assets:CheckingAccount   1000.00 USD
+  Equity:OpeningBalances  -1000.00 USD
+
+2020/01/10 Buy fund
+  Assets:Brokerage            2.5 VANGUARD500
+  Assets:CheckingAccount  -750.00 USD = 250.00 USD
+
+2020/01/12 Paris
+  Expenses:Travel             100 EUR
+  Assets:CheckingAccount  -110.00 USD
+
+2020/01/13 Cash
+  Assets:CheckingAccount:EUR  -50 EUR
+  Expenses:Travel              50 EUR
+
+`)
+
+	coin.Load(&b, "converted")
+	coin.ResolveAll()
+	assert.Equal(t, len(coin.Transactions), 4)
+	assert.Equal(t, coin.AccountsByName["Assets:CheckingAccount"].Balance().String(), "140.00")
+}
+
+func Test_ParseAmount(t *testing.T) {
+	var log bytes.Buffer
+	j := newJournal(parseSymbols("$=USD"), &log)
+	j.defaultC = j.commodity("CAD", "")
+	for i, fix := range []struct {
+		in, value, id string
+	}{
+		{"$1,000.50", "1000.50", "USD"},
+		{"$-10", "-10.00", "USD"},
+		{"-$10", "-10.00", "USD"},
+		{"-2.5 EUR", "-2.50", "EUR"},
+		{`10 "ABC 1"`, "10.00", "ABC1"},
+		{"12.34", "12.34", "CAD"},
+		{"1,234 $", "1234.00", "USD"},
+	} {
+		a := j.parseAmount(fix.in, "", false)
+		assert.Equal(t, a.value.FloatString(2), fix.value, "%d. value", i)
+		assert.Equal(t, a.commodity.id, fix.id, "%d. commodity", i)
+	}
+	assert.True(t, j.parseAmount("$10 EUR", "", false) == nil)
+}
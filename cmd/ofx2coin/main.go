@@ -3,16 +3,13 @@ package main
 import (
 	"flag"
 	"fmt"
-	"io"
-	"math/big"
 	"os"
-	"path/filepath"
-	"sort"
-	"time"
 
-	"github.com/aclindsa/ofxgo"
 	"github.com/mkobetic/coin"
 	"github.com/mkobetic/coin/check"
+	"github.com/mkobetic/coin/classify"
+	"github.com/mkobetic/coin/importer"
+	"github.com/mkobetic/coin/importer/ofx"
 )
 
 const usage = `Usage: ofx2coin [flags] files...
@@ -22,10 +19,15 @@ Converts OFX/QFX files into coin transactions based on a set of rules (see READM
 Flags:`
 
 var (
-	dumpOFXIDs = flag.Bool("ids", false, "dump accounts with known ofx ids")
-	dumpRules  = flag.Bool("rules", false, "dump the loaded account rules (useful for formatting)")
-	bmoHack    = flag.Bool("bmo", false, "handle invalid qfx files from Bank of Montreal")
-	keepDupes  = flag.Bool("keep-dupes", false, "keep duplicate transactions")
+	dumpOFXIDs     = flag.Bool("ids", false, "dump accounts with known ofx and import ids")
+	dumpRules      = flag.Bool("rules", false, "dump the loaded account rules (useful for formatting)")
+	bmoHack        = flag.Bool("bmo", false, "handle invalid qfx files from Bank of Montreal")
+	keepDupes      = flag.Bool("keep-dupes", false, "keep duplicate transactions")
+	dupeDays       = flag.Int("dupe-days", 3, "maximum date difference in days of duplicate transactions and transfers")
+	dupeSimilarity = flag.Float64("dupe-similarity", 0.5, "minimum description similarity (0 to 1) of duplicate transactions")
+	review         = flag.String("review", "", "write possible duplicates that were kept to this file")
+	learn          = flag.Bool("learn", false, "predict the accounts of transactions not matching any rule from the ledger")
+	confidence     = flag.Float64("confidence", 0.5, "minimum confidence (0 to 1) of predicted accounts")
 )
 
 func init() {
@@ -49,148 +51,40 @@ func main() {
 					fmt.Printf("%s %s\n", a.OFXAcctId, a.FullName)
 				}
 			}
+			for _, id := range a.ImportIds {
+				fmt.Printf("%s %s\n", id, a.FullName)
+			}
 		})
 		return
 	}
 
-	var rules *coin.RuleIndex
-	fn := filepath.Join(coin.DB, "ofx.rules")
-	if _, err := os.Stat(fn); !os.IsNotExist(err) {
-		file, err := os.Open(fn)
-		check.NoError(err, "Failed to open %s", fn)
-		defer file.Close()
-		rules, err = coin.ReadRules(file)
-		check.NoError(err, "Failed to parse %s", fn)
-	}
+	rules, err := importer.LoadRules("ofx.rules")
+	check.NoError(err, "Failed to load rules")
 
 	if *dumpRules {
 		rules.Write(os.Stdout)
 		return
 	}
 
-	var transactions coin.TransactionsByTime
-
+	p := importer.NewPipeline()
+	p.KeepDupes = *keepDupes
+	p.Days = *dupeDays
+	p.Similarity = *dupeSimilarity
+	if *learn {
+		p.Classifier = classify.New(coin.Transactions, *confidence)
+	}
+	if *review != "" {
+		file, err := os.Create(*review)
+		check.NoError(err, "Failed to create %s", *review)
+		defer file.Close()
+		p.Review = file
+	}
+	imp := &ofx.Importer{Rules: rules, BMO: *bmoHack}
 	for _, fileName := range flag.Args() {
 		file, err := os.Open(fileName)
 		check.NoError(err, "Failed to open %s", fileName)
 		defer file.Close()
-		var r io.Reader = file
-		if *bmoHack {
-			r = newBMOReader(file)
-		}
-		batch, err := readTransactions(r, rules)
-		check.NoError(err, "Cannot parse file %s", fileName)
-		transactions = append(transactions, batch...)
-	}
-	// write transactions
-	sort.Stable(transactions)
-
-	if !*keepDupes {
-		var filtered, day, oldDay coin.TransactionsByTime
-		var prevDay time.Time
-		for _, t := range transactions {
-			if !t.Posted.Equal(prevDay) {
-				oldDay = coin.Transactions.Day(t.Posted)
-				filtered = append(filtered, day...)
-				day = nil
-				prevDay = t.Posted
-			}
-			if t2 := oldDay.FindEqual(t); t2 != nil {
-				// cannot merge into the old transaction
-				// may lose additional info from the import (e.g. balance :/)
-				fmt.Fprintf(os.Stderr,
-					"DROPPING DUPLICATE TRANSACTION:\n%s\n%s\n",
-					t2.Location(),
-					t)
-				continue
-			}
-			if t2 := day.FindEqual(t); t2 != nil {
-				t2.MergeDuplicate(t)
-				fmt.Fprintf(os.Stderr,
-					"DROPPING DUPLICATE TRANSACTION:\n%s\n",
-					t)
-				continue
-			}
-			day = append(day, t)
-		}
-		filtered = append(filtered, day...) // append last day
-		transactions = filtered
-	}
-
-	for _, t := range transactions {
-		t.Write(os.Stdout, false)
-		fmt.Fprintln(os.Stdout)
-	}
-}
-
-func readTransactions(r io.Reader, rules *coin.RuleIndex) (transactions []*coin.Transaction, err error) {
-	responses, err := ofxgo.ParseResponse(r)
-	if err != nil {
-		return nil, err
-	}
-
-	// read bank transactions
-	for _, resp := range responses.Bank {
-		resp := resp.(*ofxgo.StatementResponse)
-		rules := rules.AccountRulesFor(resp.BankAcctFrom.AcctID.String())
-		last := len(resp.BankTranList.Transactions) - 1
-		for i, t := range resp.BankTranList.Transactions {
-			var balance *big.Rat
-			if i == last {
-				balance = &(resp.BalAmt.Rat)
-			}
-			if nt := newTransaction(rules,
-				t.DtPosted.Time,
-				trim(t.Name.String()+t.Memo.String()),
-				t.TrnAmt.Rat,
-				balance,
-			); nt != nil {
-				transactions = append(transactions, nt)
-			}
-		}
-	}
-	// read credit card transactions
-	for _, resp := range responses.CreditCard {
-		resp := resp.(*ofxgo.CCStatementResponse)
-		rules := rules.AccountRulesFor(resp.CCAcctFrom.AcctID.String())
-		for _, t := range resp.BankTranList.Transactions {
-			if nt := newTransaction(rules,
-				t.DtPosted.Time,
-				t.Name.String(),
-				t.TrnAmt.Rat,
-				nil,
-			); nt != nil {
-				transactions = append(transactions, nt)
-			}
-		}
-	}
-
-	return transactions, nil
-}
-
-func newTransaction(ars *coin.AccountRules, date time.Time, payee string, amount big.Rat, balance *big.Rat) *coin.Transaction {
-	from := ars.Account
-	to := coin.Unbalanced
-	var notes []string
-	rule := ars.RuleFor(payee)
-	if rule != nil {
-		if rule.Account == nil {
-			// drop the transaction
-			return nil
-		}
-		to = rule.Account
-		notes = rule.Notes
-	}
-	amt := coin.NewAmountFrac(amount.Num(), amount.Denom(), ars.Account.Commodity)
-	var bal *coin.Amount
-	if balance != nil {
-		bal = coin.NewAmountFrac(balance.Num(), balance.Denom(), ars.Account.Commodity)
-	}
-	t := &coin.Transaction{
-		Posted:      date,
-		Description: payee,
-		Notes:       notes,
+		check.NoError(p.Read(imp, file), "Cannot parse file %s", fileName)
 	}
-	t.Post(from, to, amt, bal)
-	return t
+	p.Write(os.Stdout)
 }
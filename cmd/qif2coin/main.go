@@ -0,0 +1,74 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/mkobetic/coin"
+	"github.com/mkobetic/coin/check"
+	"github.com/mkobetic/coin/classify"
+	"github.com/mkobetic/coin/importer"
+	"github.com/mkobetic/coin/importer/qif"
+)
+
+const usage = `Usage: qif2coin [flags] files...
+
+Converts QIF files into coin transactions based on a set of rules (see README).
+
+Flags:`
+
+var (
+	accountId      = flag.String("a", "", "account id (import-id or full account name) of files without !Account sections")
+	dmy            = flag.Bool("dmy", false, "dates are day first (D/M/Y)")
+	dumpRules      = flag.Bool("rules", false, "dump the loaded account rules (useful for formatting)")
+	keepDupes      = flag.Bool("keep-dupes", false, "keep duplicate transactions")
+	dupeDays       = flag.Int("dupe-days", 3, "maximum date difference in days of duplicate transactions and transfers")
+	dupeSimilarity = flag.Float64("dupe-similarity", 0.5, "minimum description similarity (0 to 1) of duplicate transactions")
+	review         = flag.String("review", "", "write possible duplicates that were kept to this file")
+	learn          = flag.Bool("learn", false, "predict the accounts of transactions not matching any rule from the ledger")
+	confidence     = flag.Float64("confidence", 0.5, "minimum confidence (0 to 1) of predicted accounts")
+)
+
+func init() {
+	flag.Usage = func() {
+		w := flag.CommandLine.Output()
+		fmt.Fprintln(w, usage)
+		flag.PrintDefaults()
+	}
+}
+
+func main() {
+	flag.Parse()
+	coin.LoadAll()
+
+	rules, err := importer.LoadRules("qif.rules", "ofx.rules")
+	check.NoError(err, "Failed to load rules")
+
+	if *dumpRules {
+		rules.Write(os.Stdout)
+		return
+	}
+
+	p := importer.NewPipeline()
+	p.KeepDupes = *keepDupes
+	p.Days = *dupeDays
+	p.Similarity = *dupeSimilarity
+	if *learn {
+		p.Classifier = classify.New(coin.Transactions, *confidence)
+	}
+	if *review != "" {
+		file, err := os.Create(*review)
+		check.NoError(err, "Failed to create %s", *review)
+		defer file.Close()
+		p.Review = file
+	}
+	imp := &qif.Importer{Rules: rules, Account: *accountId, DMY: *dmy, Log: os.Stderr}
+	for _, fileName := range flag.Args() {
+		file, err := os.Open(fileName)
+		check.NoError(err, "Failed to open %s", fileName)
+		defer file.Close()
+		check.NoError(p.Read(imp, file), "Cannot parse file %s", fileName)
+	}
+	p.Write(os.Stdout)
+}
@@ -2,6 +2,7 @@
 package coin
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -118,6 +119,8 @@ func Load(r io.Reader, fn string) {
 			Transactions = append(Transactions, i)
 		case *Test:
 			Tests = append(Tests, i)
+		case *RuleGroup:
+			RuleGroups = append(RuleGroups, i)
 		case *Include:
 			files, err := i.Files()
 			check.NoError(err, "Failed to resolve include %s", i.Path)
@@ -137,6 +140,7 @@ func Load(r io.Reader, fn string) {
 func ResolveAll() {
 	ResolvePrices()
 	ResolveAccounts()
+	ResolveRules()
 	ResolveTransactions(true)
 }
 
@@ -146,15 +150,15 @@ func ResolvePrices() {
 		p.Currency = MustFindCommodity(p.currencyId, p.Location())
 		p.Commodity.AddPrice(p)
 	}
-	// Sort commodity prices.
+	// Sort commodity prices, keep prices from the same day in the order they were loaded.
 	for _, c := range Commodities {
 		for _, p := range c.Prices {
-			sort.Slice(p, func(i, j int) bool {
+			sort.SliceStable(p, func(i, j int) bool {
 				return p[i].Time.After(p[j].Time)
 			})
 		}
 	}
-	sort.Slice(Prices, func(i, j int) bool {
+	sort.SliceStable(Prices, func(i, j int) bool {
 		return Prices[i].Time.Before(Prices[j].Time)
 	})
 }
@@ -278,20 +282,30 @@ func ResolveTransactions(checkPostings bool) {
 	sort.Stable(Transactions)
 }
 
-// MustFindAccount returns an account matching the pattern.
+// MustFindAccount returns an account matching the pattern (see FindAccount).
+// Panics if there isn't one.
+func MustFindAccount(pattern string) *Account {
+	a, err := FindAccount(pattern)
+	if err != nil {
+		panic(err)
+	}
+	return a
+}
+
+// FindAccount returns an account matching the pattern.
 // If multiple accounts match and they all have a common parent matching the pattern, return the parent.
 // This is to avoid having to spell out non-leaf accounts in full.
-// Otherwise panic.
-func MustFindAccount(pattern string) *Account {
+// Otherwise returns an error.
+func FindAccount(pattern string) (*Account, error) {
 	if a := AccountsByName[pattern]; a != nil {
-		return a
+		return a, nil
 	}
 	as := FindAccounts(pattern)
 	if len(as) == 0 {
-		panic(fmt.Errorf("cannot find account %s", pattern))
+		return nil, fmt.Errorf("cannot find account %s", pattern)
 	}
 	if len(as) == 1 {
-		return as[0]
+		return as[0], nil
 	}
 	parent := as[0].FullName
 	all := true
@@ -301,13 +315,13 @@ func MustFindAccount(pattern string) *Account {
 		}
 	}
 	if all {
-		return as[0]
+		return as[0], nil
 	}
 	msg := fmt.Sprintf("Found %d accounts matching %s", len(as), pattern)
 	for _, a := range as {
 		msg += "\n" + a.FullName
 	}
-	panic(msg)
+	return nil, errors.New(msg)
 }
 
 func FindAccountOfxId(acctId string) *Account {
@@ -319,6 +333,16 @@ func FindAccountOfxId(acctId string) *Account {
 	return nil
 }
 
+// FindAccountImportId returns the account with the id in imported statements (see Account.HasImportId).
+func FindAccountImportId(id string) *Account {
+	for _, a := range AccountsByName {
+		if a.HasImportId(id) {
+			return a
+		}
+	}
+	return nil
+}
+
 func ToRegex(pattern string) *regexp.Regexp {
 	multiple := `[\w/_:-]*`
 	single := `[\w/_-]*:[\w/_-]*`
@@ -6,8 +6,10 @@ import (
 	"fmt"
 	"io"
 	"math/big"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 	"unicode"
 
 	"github.com/mkobetic/coin/rex"
@@ -26,6 +28,7 @@ type Commodity struct {
 	Decimals int    // how many decimal places to use
 	NoMarket bool   // Don't download prices
 	Symbol   string // symbol to use for quotes
+	Source   string // quote provider to use for quotes (e.g. ecb, csv:path, http:url)
 
 	// price lists by currency
 	Prices map[*Commodity][]*Price
@@ -110,6 +113,7 @@ commodity USD
 	note American Dollars
 	format 1000.00 USD
 	nomarket
+	source ecb
 	default
 */
 func (c *Commodity) Write(w io.Writer, ledger bool) error {
@@ -125,6 +129,9 @@ func (c *Commodity) Write(w io.Writer, ledger bool) error {
 	if c.NoMarket {
 		lines = append(lines, "  nomarket\n")
 	}
+	if c.Source != "" && !ledger {
+		lines = append(lines, "  source ", c.Source, "\n")
+	}
 	for _, line := range lines {
 		_, err := io.WriteString(w, line)
 		if err != nil {
@@ -141,6 +148,7 @@ var commodityBodyREX = rex.MustCompile(``+
 	`(\s+format\s+(?P<format>%s))|`+
 	`(\s+(?P<nomarket>nomarket)\s*)|`+
 	`(\s+symbol\s+(?P<symbol>[\w\.]+))|`+
+	`(\s+source\s+(?P<source>\S+))|`+
 	`(\s+(?P<default>default)\s*)`,
 	AmountREX)
 
@@ -169,6 +177,8 @@ func (p *Parser) parseCommodity(fn string) (*Commodity, error) {
 			c.NoMarket = true
 		} else if s := match["symbol"]; s != "" {
 			c.Symbol = s
+		} else if s := match["source"]; s != "" {
+			c.Source = s
 		} else if match["default"] != "" {
 			DefaultCommodityId = c.Id
 		} else {
@@ -182,7 +192,16 @@ func (p *Parser) parseCommodity(fn string) (*Commodity, error) {
 // commodity prices. Try to find a conversion path through known intermediate
 // commodities as well.
 func (c *Commodity) Convert(amount *Amount, c2 *Commodity) (*Amount, error) {
-	return c.convert(amount, c2, nil)
+	val, _, err := c.ConvertAt(amount, c2, time.Time{})
+	return val, err
+}
+
+// ConvertAt converts the amount from c2 commodity to amount in c commodity
+// using the most recent prices as of the specified time (zero time means latest prices).
+// Intermediate commodities are tried in the order of their ids to make the path selection
+// deterministic. Returns the prices used along the conversion path.
+func (c *Commodity) ConvertAt(amount *Amount, c2 *Commodity, at time.Time) (*Amount, []*Price, error) {
+	return c.convert(amount, c2, at, nil)
 }
 
 func (c *Commodity) includedIn(list []*Commodity) bool {
@@ -194,33 +213,49 @@ func (c *Commodity) includedIn(list []*Commodity) bool {
 	return false
 }
 
-func (c *Commodity) convert(amount *Amount, c2 *Commodity, previous []*Commodity) (*Amount, error) {
+func (c *Commodity) convert(amount *Amount, c2 *Commodity, at time.Time, previous []*Commodity) (*Amount, []*Price, error) {
 	if c == c2 {
 		// Nothing to convert
-		return amount, nil
+		return amount, nil, nil
 	}
 	// Does c2 have prices in c currency?
-	prices := c2.Prices[c]
-	if prices != nil {
-		p := prices[0]
-		val := amount.Times(p.Value)
-		return val, nil
+	if p := c2.PriceAt(c, at); p != nil {
+		return p.apply(amount), []*Price{p}, nil
 	}
 	// Otherwise try to follow each c2 price currency
-	for c3, prices := range c2.Prices {
+	var currencies []*Commodity
+	for c3 := range c2.Prices {
+		currencies = append(currencies, c3)
+	}
+	sort.Slice(currencies, func(i, j int) bool { return currencies[i].Id < currencies[j].Id })
+	for _, c3 := range currencies {
 		// Check if we tried this currency before to avoid cycles
 		if c3.includedIn(previous) {
 			continue
 		}
-		p := prices[0]
-		val2 := amount.Times(p.Value)
-		val3, err := c.convert(val2, c3, append(previous, c2))
+		p := c2.PriceAt(c3, at)
+		if p == nil {
+			continue
+		}
+		val3, used, err := c.convert(p.apply(amount), c3, at, append(previous, c2))
 		if err == nil {
-			return val3, nil
+			return val3, append([]*Price{p}, used...), nil
 		}
 	}
 	// Didn't find any path that leads to c
-	return nil, fmt.Errorf("cannot convert %s => %s", c2.Id, c.Id)
+	return nil, nil, fmt.Errorf("cannot convert %s => %s", c2.Id, c.Id)
+}
+
+// PriceAt returns the most recent price of c in currency as of the specified time,
+// zero time means the latest price. Returns nil if there is no such price.
+func (c *Commodity) PriceAt(currency *Commodity, at time.Time) *Price {
+	for _, p := range c.Prices[currency] {
+		// prices are sorted newest first
+		if at.IsZero() || !p.Time.After(at) {
+			return p
+		}
+	}
+	return nil
 }
 
 func (c *Commodity) NewAmountFloat(f float64) *Amount {
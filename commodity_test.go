@@ -12,6 +12,7 @@ func Test_ParseCommodity(t *testing.T) {
 commodity NBC814
   note Altamira Precision Canadian Index Fund
   format 1.0000 NBC814
+  source csv:quotes/nbc814.csv
 
 commodity BND
   note Vanguard Total Bond Market ETF
@@ -25,6 +26,7 @@ commodity BND
 	assert.Equal(t, c.Id, "NBC814")
 	assert.Equal(t, c.Name, "Altamira Precision Canadian Index Fund")
 	assert.Equal(t, c.Decimals, 4)
+	assert.Equal(t, c.Source, "csv:quotes/nbc814.csv")
 
 	i, err = p.Next("")
 	assert.NoError(t, err)
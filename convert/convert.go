@@ -0,0 +1,140 @@
+// Package convert holds the coin ledger built by the converters from other plaintext accounting formats
+// (bean2coin, ledger2coin) and writes it into a coin database.
+package convert
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/mkobetic/coin"
+	"github.com/mkobetic/coin/check"
+)
+
+// Ledger is the converted ledger.
+type Ledger struct {
+	Commodities  []*coin.Commodity
+	Default      *coin.Commodity // default commodity
+	Accounts     []*coin.Account
+	Prices       []*coin.Price
+	Transactions []*coin.Transaction
+}
+
+// Save writes the ledger into the coin database files, or to stdout if $COINDB isn't set.
+// If yearly is set the prices and transactions are split into files by year.
+func (l *Ledger) Save(yearly bool) {
+	f, done := next(coin.CommoditiesFilename)
+	l.WriteCommodities(f)
+	done()
+	f, done = next(coin.AccountsFilename)
+	l.WriteAccounts(f)
+	done()
+	if !yearly {
+		f, done = next(coin.PricesFilename)
+		l.WritePrices(f, l.Prices)
+		done()
+		f, done = next(coin.TransactionsFilename)
+		l.WriteTransactions(f, l.Transactions)
+		done()
+		return
+	}
+	for prices := l.Prices; len(prices) > 0; {
+		year := prices[0].Time.Year()
+		i := 0
+		for i < len(prices) && prices[i].Time.Year() == year {
+			i++
+		}
+		f, done = next(strconv.Itoa(year) + coin.PricesExtension)
+		l.WritePrices(f, prices[:i])
+		done()
+		prices = prices[i:]
+	}
+	for transactions := l.Transactions; len(transactions) > 0; {
+		year := transactions[0].Posted.Year()
+		i := 0
+		for i < len(transactions) && transactions[i].Posted.Year() == year {
+			i++
+		}
+		f, done = next(strconv.Itoa(year) + coin.TransactionsExtension)
+		l.WriteTransactions(f, transactions[:i])
+		done()
+		transactions = transactions[i:]
+	}
+}
+
+// next returns the writer of the database file fn and the function closing it.
+func next(fn string) (io.Writer, func()) {
+	if coin.DB == "" {
+		return os.Stdout, func() {}
+	}
+	fn = filepath.Join(coin.DB, fn)
+	f, err := os.Create(fn)
+	check.NoError(err, "creating %s", fn)
+	return f, func() { check.NoError(f.Close(), "closing %s", fn) }
+}
+
+func (l *Ledger) WriteCommodities(f io.Writer) {
+	for _, c := range l.Commodities {
+		check.NoError(c.Write(f, false), "writing commodity %s", c.Id)
+		if c == l.Default {
+			fmt.Fprintln(f, "  default")
+		}
+		fmt.Fprintln(f)
+	}
+}
+
+func (l *Ledger) WriteAccounts(f io.Writer) {
+	for _, a := range l.Accounts {
+		check.NoError(a.Write(f, false), "writing account %s", a.FullName)
+		fmt.Fprintln(f)
+	}
+}
+
+func (l *Ledger) WritePrices(f io.Writer, prices []*coin.Price) {
+	for _, p := range prices {
+		check.NoError(p.Write(f, false), "writing price %s", p)
+	}
+}
+
+func (l *Ledger) WriteTransactions(f io.Writer, transactions []*coin.Transaction) {
+	for _, t := range transactions {
+		check.NoError(t.Write(f, false), "writing transaction %s", t.Description)
+		fmt.Fprintln(f)
+	}
+}
+
+// Round converts the value to an amount of commodity c, rounding half away from zero.
+func Round(v *big.Rat, c *coin.Commodity) *coin.Amount {
+	num := new(big.Int).Mul(v.Num(), new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(c.Decimals)), nil))
+	q, r := new(big.Int).QuoRem(num, v.Denom(), new(big.Int))
+	if r.Abs(r).Lsh(r, 1).Cmp(v.Denom()) >= 0 {
+		q.Add(q, big.NewInt(int64(v.Sign())))
+	}
+	return coin.NewAmount(q, c)
+}
+
+var invalidAccountREX = regexp.MustCompile(`[^\w/\-]+`)
+
+// AccountName maps the account name to a valid coin account name,
+// invalid characters are replaced with _ and names not starting with a letter are prefixed with X.
+func AccountName(name string) string {
+	parts := strings.Split(name, ":")
+	for i, p := range parts {
+		p = invalidAccountREX.ReplaceAllString(p, "_")
+		if p == "" || !IsLetter(p[0]) {
+			p = "X" + p
+		}
+		parts[i] = p
+	}
+	return strings.Join(parts, ":")
+}
+
+// IsLetter returns true if b is an ASCII letter.
+func IsLetter(b byte) bool {
+	return (b >= 'A' && b <= 'Z') || (b >= 'a' && b <= 'z')
+}
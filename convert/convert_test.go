@@ -0,0 +1,40 @@
+package convert
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/mkobetic/coin"
+	"github.com/mkobetic/coin/assert"
+)
+
+func Test_Round(t *testing.T) {
+	c := &coin.Commodity{Id: "CAD", Decimals: 2}
+	for _, fix := range []struct {
+		in, out string
+	}{
+		{"1/3", "0.33"},
+		{"2/3", "0.67"},
+		{"1/8", "0.13"},
+		{"-1/8", "-0.13"},
+		{"-1/3", "-0.33"},
+		{"10", "10.00"},
+	} {
+		v, _ := new(big.Rat).SetString(fix.in)
+		assert.Equal(t, fmt.Sprintf("%a", Round(v, c)), fix.out, fix.in)
+	}
+}
+
+func Test_AccountName(t *testing.T) {
+	for _, fix := range []struct {
+		in, out string
+	}{
+		{"Assets:Checking", "Assets:Checking"},
+		{"Assets:Bank (old)", "Assets:Bank_old_"},
+		{"Expenses:401k", "Expenses:X401k"},
+		{"Income::Gifts", "Income:X:Gifts"},
+	} {
+		assert.Equal(t, AccountName(fix.in), fix.out, fix.in)
+	}
+}
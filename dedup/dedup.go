@@ -0,0 +1,318 @@
+// Package dedup finds imported transactions duplicating transactions already in the ledger,
+// or each other (e.g. from overlapping statement downloads).
+package dedup
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/mkobetic/coin"
+)
+
+// Matcher decides whether an imported transaction is a duplicate.
+//
+// If Tags are set (e.g. ofxid), transactions tagged with the same value for the same account are duplicates
+// regardless of their dates and descriptions, transactions tagged with different values are never duplicates.
+// Otherwise the candidates are transactions within Days of the imported transaction
+// sharing a posting with the same account (other than Unbalanced) and the same amount.
+// A candidate is a duplicate if it has the same date and postings as the imported transaction,
+// or if the similarity of the descriptions is at least Similarity.
+// Candidates with less similar descriptions are ambiguous, the imported transaction is kept
+// and the case is listed in the review output.
+type Matcher struct {
+	Days       int      // maximum posted date difference in days
+	Similarity float64  // minimum description similarity (0 to 1)
+	Tags       []string // tags with a unique transaction id for the account, e.g. ofxid
+
+	// Report receives the explanation of each drop or keep decision
+	Report io.Writer
+	// Review receives the ambiguous cases, if set
+	Review io.Writer
+
+	ledger coin.TransactionsByTime
+	tagged map[taggedId]*coin.Transaction
+	used   map[*coin.Transaction]bool
+}
+
+// NewMatcher returns a matcher of transactions imported into the ledger.
+func NewMatcher(ledger coin.TransactionsByTime, days int, similarity float64) *Matcher {
+	return &Matcher{
+		Days:       days,
+		Similarity: similarity,
+		ledger:     ledger,
+		used:       map[*coin.Transaction]bool{},
+	}
+}
+
+type taggedId struct {
+	tag     string
+	account *coin.Account
+	id      string
+}
+
+// candidate is a possible duplicate of an imported transaction.
+type candidate struct {
+	*coin.Transaction
+	imported   bool // kept imported transaction rather than a ledger transaction
+	equal      bool // same date and postings
+	days       int
+	similarity float64
+}
+
+func (c *candidate) location() string {
+	if c.imported {
+		return "imported"
+	}
+	return c.Location()
+}
+
+func (c *candidate) reason() string {
+	if c.equal {
+		return "same date, accounts and amounts"
+	}
+	days := "same date"
+	switch {
+	case c.days == 1:
+		days = "1 day apart"
+	case c.days > 1:
+		days = fmt.Sprintf("%d days apart", c.days)
+	}
+	return fmt.Sprintf("%s, same account and amount, description similarity %.2f", days, c.similarity)
+}
+
+// better returns true if c is a better match than c2.
+func (c *candidate) better(c2 *candidate) bool {
+	if c2 == nil || c.equal != c2.equal {
+		return c2 == nil || c.equal
+	}
+	if c.days != c2.days {
+		return c.days < c2.days
+	}
+	return c.similarity > c2.similarity
+}
+
+// Filter returns the imported transactions that are not duplicates.
+// Duplicates within the imported transactions are merged into the first one (e.g. to keep the balance).
+// The imported transactions must be sorted by time.
+func (m *Matcher) Filter(imported coin.TransactionsByTime) (kept coin.TransactionsByTime) {
+	for _, t := range imported {
+		tagged := m.taggedId(t)
+		if location, found := m.findTagged(tagged, kept); found {
+			m.report("DROPPING DUPLICATE TRANSACTION (same %s %s):\n%s\n%s\n",
+				tagged.tag, tagged.id, location, t)
+			continue
+		}
+		var best *candidate
+		var ambiguous, different []*candidate
+		for _, c := range m.candidates(t, kept) {
+			switch {
+			case tagged != nil && idOf(c.Transaction, tagged.tag, tagged.account) != "":
+				if c.equal {
+					different = append(different, c)
+				}
+			case c.equal || c.similarity >= m.Similarity:
+				if c.better(best) {
+					best = c
+				}
+			default:
+				ambiguous = append(ambiguous, c)
+			}
+		}
+		if best != nil {
+			m.report("DROPPING DUPLICATE TRANSACTION (%s):\n%s\n%s\n", best.reason(), best.location(), t)
+			if best.imported {
+				best.MergeDuplicate(t)
+			} else {
+				m.used[best.Transaction] = true
+			}
+			continue
+		}
+		kept = append(kept, t)
+		for _, c := range different {
+			m.report("KEEPING TRANSACTION (different %s than %s):\n%s\n", tagged.tag, c.location(), t)
+		}
+		if len(ambiguous) > 0 {
+			m.keepAmbiguous(t, ambiguous)
+		}
+	}
+	return kept
+}
+
+func (m *Matcher) keepAmbiguous(t *coin.Transaction, ambiguous []*candidate) {
+	for _, c := range ambiguous {
+		m.report("KEEPING POSSIBLE DUPLICATE TRANSACTION (%s of %s):\n%s\n", c.reason(), c.location(), t)
+	}
+	if m.Review == nil {
+		return
+	}
+	fmt.Fprintf(m.Review, "; POSSIBLE DUPLICATE, KEPT\n")
+	t.Write(m.Review, false)
+	for _, c := range ambiguous {
+		fmt.Fprintf(m.Review, "; %s: %s\n", c.location(), c.reason())
+		c.Write(m.Review, false)
+	}
+	fmt.Fprintln(m.Review)
+}
+
+func (m *Matcher) report(format string, args ...interface{}) {
+	if m.Report != nil {
+		fmt.Fprintf(m.Report, format, args...)
+	}
+}
+
+// candidates returns the ledger and already kept transactions within the date window
+// sharing a posting with t.
+func (m *Matcher) candidates(t *coin.Transaction, kept coin.TransactionsByTime) (candidates []*candidate) {
+	from := t.Posted.AddDate(0, 0, -m.Days)
+	to := t.Posted.AddDate(0, 0, m.Days)
+	add := func(transactions coin.TransactionsByTime, imported bool) {
+		for _, t2 := range window(transactions, from, to) {
+			if m.used[t2] || !sharePosting(t, t2) {
+				continue
+			}
+			candidates = append(candidates, &candidate{
+				Transaction: t2,
+				imported:    imported,
+				equal:       t.IsEqual(t2),
+				days:        daysApart(t.Posted, t2.Posted),
+				similarity:  Similarity(t.Description, t2.Description),
+			})
+		}
+	}
+	add(m.ledger, false)
+	add(kept, true)
+	return candidates
+}
+
+// findTagged returns the location of the ledger or kept transaction with the same tagged id.
+func (m *Matcher) findTagged(tagged *taggedId, kept coin.TransactionsByTime) (location string, found bool) {
+	if tagged == nil {
+		return "", false
+	}
+	if m.tagged == nil {
+		m.tagged = map[taggedId]*coin.Transaction{}
+		for _, t2 := range m.ledger {
+			for _, p2 := range t2.Postings {
+				for _, tag := range m.Tags {
+					if id := p2.Tags.Value(tag); id != "" {
+						m.tagged[taggedId{tag, p2.Account, id}] = t2
+					}
+				}
+			}
+		}
+	}
+	if t2 := m.tagged[*tagged]; t2 != nil {
+		return t2.Location(), true
+	}
+	for _, t2 := range kept {
+		if idOf(t2, tagged.tag, tagged.account) == tagged.id {
+			return "imported", true
+		}
+	}
+	return "", false
+}
+
+// taggedId returns the id of the first posting of t tagged with one of the matcher tags.
+func (m *Matcher) taggedId(t *coin.Transaction) *taggedId {
+	for _, p := range t.Postings {
+		for _, tag := range m.Tags {
+			if id := p.Tags.Value(tag); id != "" {
+				return &taggedId{tag, p.Account, id}
+			}
+		}
+	}
+	return nil
+}
+
+// idOf returns the value of the tag of the posting of t to the account.
+func idOf(t *coin.Transaction, tag string, account *coin.Account) string {
+	for _, p := range t.Postings {
+		if p.Account == account {
+			if id := p.Tags.Value(tag); id != "" {
+				return id
+			}
+		}
+	}
+	return ""
+}
+
+// window returns transactions posted between from and to (inclusive), transactions must be sorted by time.
+func window(transactions coin.TransactionsByTime, from, to time.Time) coin.TransactionsByTime {
+	start := sort.Search(len(transactions), func(i int) bool {
+		return !transactions[i].Posted.Before(from)
+	})
+	end := sort.Search(len(transactions), func(i int) bool {
+		return transactions[i].Posted.After(to)
+	})
+	if start >= end {
+		return nil
+	}
+	return transactions[start:end]
+}
+
+// sharePosting returns true if t and t2 have a posting with the same account (other than Unbalanced) and amount.
+func sharePosting(t, t2 *coin.Transaction) bool {
+	for _, p := range t.Postings {
+		if p.Account == coin.Unbalanced {
+			continue
+		}
+		for _, p2 := range t2.Postings {
+			if p.IsEqual(p2) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func daysApart(t, t2 time.Time) int {
+	d := t.Sub(t2)
+	if d < 0 {
+		d = -d
+	}
+	return int((d + 12*time.Hour) / (24 * time.Hour))
+}
+
+// Similarity of descriptions a and b from 0 (nothing in common) to 1 (same),
+// computed as the Sørensen–Dice coefficient of the letter and digit pairs of the words, ignoring case.
+func Similarity(a, b string) float64 {
+	pa, pb := pairs(a), pairs(b)
+	if len(pa) == 0 && len(pb) == 0 {
+		return 1
+	}
+	if len(pa) == 0 || len(pb) == 0 {
+		return 0
+	}
+	counts := map[string]int{}
+	for _, p := range pa {
+		counts[p]++
+	}
+	common := 0
+	for _, p := range pb {
+		if counts[p] > 0 {
+			counts[p]--
+			common++
+		}
+	}
+	return float64(2*common) / float64(len(pa)+len(pb))
+}
+
+func pairs(s string) (pairs []string) {
+	words := strings.FieldsFunc(strings.ToUpper(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, w := range words {
+		runes := []rune(w)
+		if len(runes) == 1 {
+			pairs = append(pairs, w)
+		}
+		for i := 0; i+1 < len(runes); i++ {
+			pairs = append(pairs, string(runes[i:i+2]))
+		}
+	}
+	return pairs
+}
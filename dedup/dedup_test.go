@@ -0,0 +1,123 @@
+package dedup
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mkobetic/coin"
+	"github.com/mkobetic/coin/assert"
+)
+
+func init() {
+	coin.Load(strings.NewReader(`
+commodity CAD
+  format 1.00 CAD
+
+account Assets:Bank:Checking
+account Expenses:Phone
+account Expenses:Groceries
+
+2019/01/14 ROGERS TOP UP
+  Expenses:Phone          25.00 CAD
+  Assets:Bank:Checking   -25.00 CAD ; #ofxid: 1001
+
+2019/01/15 FRESHCO #123
+  Expenses:Groceries      42.10 CAD
+  Assets:Bank:Checking   -42.10 CAD
+
+2019/01/20 Farm Boy
+  Expenses:Groceries      17.00 CAD
+  Assets:Bank:Checking   -17.00 CAD
+
+2019/01/22 COSTCO WHOLESALE
+  Expenses:Groceries      17.00 CAD
+  Assets:Bank:Checking   -17.00 CAD
+`), "ledger.coin")
+	coin.ResolveAll()
+}
+
+func tx(date, description, to, amount string, notes ...string) *coin.Transaction {
+	posted, err := time.Parse(coin.DateFormat, date)
+	if err != nil {
+		panic(err)
+	}
+	t := &coin.Transaction{Posted: posted.Add(12 * time.Hour), Description: description}
+	from := coin.AccountsByName["Assets:Bank:Checking"]
+	amt := coin.MustParseAmount(amount, from.Commodity)
+	t.Post(from, coin.MustFindAccount(to), amt, nil)
+	for _, p := range t.Postings {
+		if p.Account == from {
+			p.Notes = notes
+			p.Tags = coin.ParseTags(notes...)
+		}
+	}
+	return t
+}
+
+func Test_Similarity(t *testing.T) {
+	assert.Equal(t, Similarity("FRESHCO #123", "Freshco 123"), 1.0)
+	assert.Equal(t, Similarity("", ""), 1.0)
+	assert.Equal(t, Similarity("ROGERS", ""), 0.0)
+	assert.Equal(t, Similarity("ROGERS", "FRESHCO"), 0.0)
+	assert.True(t, Similarity("FRESHCO #123 TORONTO", "FRESHCO") > 0.5)
+}
+
+func Test_Filter(t *testing.T) {
+	var report, review strings.Builder
+	m := NewMatcher(coin.Transactions, 2, 0.5)
+	m.Tags = []string{"ofxid"}
+	m.Report = &report
+	m.Review = &review
+	kept := m.Filter(coin.TransactionsByTime{
+		tx("2019/01/13", "ROGERS TOP UP", "Unbalanced", "-25.00", "#ofxid: 1001"),
+		tx("2019/01/14", "ROGERS TOP UP", "Unbalanced", "-25.00", "#ofxid: 1002"),
+		tx("2019/01/16", "FRESHCO #123 TORONTO", "Unbalanced", "-42.10"),
+		tx("2019/01/20", "FARM BOY", "Expenses:Groceries", "-17.00"),
+		tx("2019/01/21", "LOBLAWS", "Unbalanced", "-17.00"),
+		tx("2019/01/21", "LOBLAWS", "Unbalanced", "-17.00"),
+	})
+	assert.Equal(t, len(kept), 2)
+	assert.Equal(t, kept[0].Postings[1].Tags.Value("ofxid"), "1002")
+	assert.Equal(t, kept[1].Description, "LOBLAWS")
+	assert.Equal(t, report.String(), `DROPPING DUPLICATE TRANSACTION (same ofxid 1001):
+ledger.coin:9
+2019/01/13 ROGERS TOP UP
+  Unbalanced             25.00 CAD
+  Assets:Bank:Checking  -25.00 CAD ; #ofxid: 1001
+
+DROPPING DUPLICATE TRANSACTION (1 day apart, same account and amount, description similarity 0.73):
+ledger.coin:13
+2019/01/16 FRESHCO #123 TORONTO
+  Unbalanced             42.10 CAD
+  Assets:Bank:Checking  -42.10 CAD
+
+DROPPING DUPLICATE TRANSACTION (same date, accounts and amounts):
+ledger.coin:17
+2019/01/20 FARM BOY
+  Expenses:Groceries     17.00 CAD
+  Assets:Bank:Checking  -17.00 CAD
+
+KEEPING POSSIBLE DUPLICATE TRANSACTION (1 day apart, same account and amount, description similarity 0.00 of ledger.coin:21):
+2019/01/21 LOBLAWS
+  Unbalanced             17.00 CAD
+  Assets:Bank:Checking  -17.00 CAD
+
+DROPPING DUPLICATE TRANSACTION (same date, accounts and amounts):
+imported
+2019/01/21 LOBLAWS
+  Unbalanced             17.00 CAD
+  Assets:Bank:Checking  -17.00 CAD
+
+`)
+	assert.Equal(t, review.String(), `; POSSIBLE DUPLICATE, KEPT
+2019/01/21 LOBLAWS
+  Unbalanced             17.00 CAD
+  Assets:Bank:Checking  -17.00 CAD
+; ledger.coin:21: 1 day apart, same account and amount, description similarity 0.00
+2019/01/22 COSTCO WHOLESALE
+  Expenses:Groceries     17.00 CAD
+  Assets:Bank:Checking  -17.00 CAD
+
+`)
+}
@@ -0,0 +1,219 @@
+// Package fx reads foreign exchange reference rates published by central banks
+// (European Central Bank, Bank of Canada).
+package fx
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Rates are the exchange rates published for a single day.
+// Each rate is the number of units of the currency per one unit of the Base currency.
+type Rates struct {
+	Date  time.Time
+	Base  string
+	Rates map[string]*big.Rat
+}
+
+func newRates(date time.Time, base string) *Rates {
+	return &Rates{
+		Date:  date.Add(12 * time.Hour), // coin dates are at noon
+		Base:  base,
+		Rates: map[string]*big.Rat{base: big.NewRat(1, 1)},
+	}
+}
+
+// Rate returns the value of one unit of currency from in currency to,
+// cross-computed through the base currency if necessary.
+// Returns nil if either currency is not known.
+func (r *Rates) Rate(from, to string) *big.Rat {
+	rFrom, rTo := r.Rates[from], r.Rates[to]
+	if rFrom == nil || rTo == nil || rFrom.Sign() == 0 {
+		return nil
+	}
+	return new(big.Rat).Quo(rTo, rFrom)
+}
+
+// Currencies returns sorted list of currencies with known rates, including the base.
+func (r *Rates) Currencies() (currencies []string) {
+	for c := range r.Rates {
+		currencies = append(currencies, c)
+	}
+	sort.Strings(currencies)
+	return currencies
+}
+
+/*
+<gesmes:Envelope>
+	<Cube>
+		<Cube time="2024-01-05">
+			<Cube currency="USD" rate="1.0921"/>
+			...
+		</Cube>
+		...
+	</Cube>
+</gesmes:Envelope>
+*/
+
+type ecbEnvelope struct {
+	Days []struct {
+		Time  string `xml:"time,attr"`
+		Rates []struct {
+			Currency string `xml:"currency,attr"`
+			Rate     string `xml:"rate,attr"`
+		} `xml:"Cube"`
+	} `xml:"Cube>Cube"`
+}
+
+// ReadECBXML reads ECB daily or historical euro foreign exchange reference rates in XML format
+// (e.g. https://www.ecb.europa.eu/stats/eurofxref/eurofxref-hist.xml).
+func ReadECBXML(r io.Reader) ([]*Rates, error) {
+	var env ecbEnvelope
+	if err := xml.NewDecoder(r).Decode(&env); err != nil {
+		return nil, err
+	}
+	var all []*Rates
+	for _, day := range env.Days {
+		date, err := time.Parse("2006-01-02", day.Time)
+		if err != nil {
+			return nil, err
+		}
+		rates := newRates(date, "EUR")
+		for _, r := range day.Rates {
+			if err := rates.set(r.Currency, r.Rate); err != nil {
+				return nil, fmt.Errorf("%s: %s", day.Time, err)
+			}
+		}
+		all = append(all, rates)
+	}
+	return sorted(all), nil
+}
+
+// ReadECBCSV reads ECB daily or historical euro foreign exchange reference rates in CSV format
+// (e.g. https://www.ecb.europa.eu/stats/eurofxref/eurofxref-hist.zip).
+// The first column is the date, the rest of the columns are rates for the currencies listed in the header.
+func ReadECBCSV(r io.Reader) ([]*Rates, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return nil, err
+	}
+	var all []*Rates
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		date, err := parseECBDate(row[0])
+		if err != nil {
+			return nil, err
+		}
+		rates := newRates(date, "EUR")
+		for i, v := range row[1:] {
+			if i+1 >= len(header) {
+				break
+			}
+			currency := strings.TrimSpace(header[i+1])
+			if v = strings.TrimSpace(v); currency == "" || v == "" || v == "N/A" {
+				continue
+			}
+			if err := rates.set(currency, v); err != nil {
+				return nil, fmt.Errorf("%s: %s", row[0], err)
+			}
+		}
+		all = append(all, rates)
+	}
+	return sorted(all), nil
+}
+
+func parseECBDate(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if d, err := time.Parse("2006-01-02", s); err == nil {
+		return d, nil
+	}
+	// the daily CSV file uses long format
+	return time.Parse("02 January 2006", s)
+}
+
+/*
+{
+	"seriesDetail": { "FXUSDCAD": { "label": "USD/CAD", ... }, ... },
+	"observations": [
+		{ "d": "2024-01-02", "FXUSDCAD": { "v": "1.3316" }, ... },
+		...
+	]
+}
+*/
+
+// ReadValet reads Bank of Canada Valet API exchange rate observations in JSON format
+// (e.g. https://www.bankofcanada.ca/valet/observations/group/FX_RATES_DAILY/json).
+// Series FX<currency>CAD are the number of CAD per one unit of the currency.
+func ReadValet(r io.Reader) ([]*Rates, error) {
+	var doc struct {
+		Observations []map[string]json.RawMessage `json:"observations"`
+	}
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, err
+	}
+	var all []*Rates
+	for _, o := range doc.Observations {
+		var d string
+		if err := json.Unmarshal(o["d"], &d); err != nil {
+			return nil, fmt.Errorf("invalid observation date: %s", err)
+		}
+		date, err := time.Parse("2006-01-02", d)
+		if err != nil {
+			return nil, err
+		}
+		rates := newRates(date, "CAD")
+		for series, value := range o {
+			if len(series) != 8 || !strings.HasPrefix(series, "FX") || !strings.HasSuffix(series, "CAD") {
+				continue
+			}
+			var v struct {
+				V string `json:"v"`
+			}
+			if err := json.Unmarshal(value, &v); err != nil {
+				return nil, fmt.Errorf("%s %s: %s", d, series, err)
+			}
+			if v.V == "" {
+				continue
+			}
+			cad, ok := new(big.Rat).SetString(v.V)
+			if !ok || cad.Sign() == 0 {
+				return nil, fmt.Errorf("%s %s: invalid rate %s", d, series, v.V)
+			}
+			rates.Rates[series[2:5]] = cad.Inv(cad)
+		}
+		all = append(all, rates)
+	}
+	return sorted(all), nil
+}
+
+func (r *Rates) set(currency, value string) error {
+	rate, ok := new(big.Rat).SetString(value)
+	if !ok {
+		return fmt.Errorf("invalid %s rate %s", currency, value)
+	}
+	r.Rates[currency] = rate
+	return nil
+}
+
+func sorted(all []*Rates) []*Rates {
+	sort.SliceStable(all, func(i, j int) bool {
+		return all[i].Date.Before(all[j].Date)
+	})
+	return all
+}
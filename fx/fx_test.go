@@ -0,0 +1,83 @@
+package fx
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mkobetic/coin/assert"
+)
+
+const ecbXML = `<?xml version="1.0" encoding="UTF-8"?>
+<gesmes:Envelope xmlns:gesmes="http://www.gesmes.org/xml/2002-08-01" xmlns="http://www.ecb.int/vocabulary/2002-08-01/eurofxref">
+	<gesmes:subject>Reference rates</gesmes:subject>
+	<Cube>
+		<Cube time="2024-01-05">
+			<Cube currency="USD" rate="1.0921"/>
+			<Cube currency="CAD" rate="1.4608"/>
+		</Cube>
+		<Cube time="2024-01-04">
+			<Cube currency="USD" rate="1.0953"/>
+			<Cube currency="CAD" rate="1.4624"/>
+		</Cube>
+	</Cube>
+</gesmes:Envelope>`
+
+const ecbHistCSV = `Date,USD,JPY,CYP,CAD,
+2024-01-05,1.0921,158.08,N/A,1.4608,
+2024-01-04,1.0953,159.16,N/A,1.4624,
+`
+
+const ecbDailyCSV = `Date, USD, JPY, CAD,
+05 January 2024, 1.0921, 158.08, 1.4608,
+`
+
+const valetJSON = `{
+"terms": {"url": "https://www.bankofcanada.ca/terms/"},
+"seriesDetail": {
+	"FXUSDCAD": {"label": "USD/CAD", "description": "US dollar to Canadian dollar daily exchange rate"},
+	"FXEURCAD": {"label": "EUR/CAD", "description": "European euro to Canadian dollar daily exchange rate"}
+},
+"observations": [
+	{"d": "2024-01-03", "FXUSDCAD": {"v": "1.3343"}, "FXEURCAD": {"v": "1.4585"}},
+	{"d": "2024-01-02", "FXUSDCAD": {"v": "1.3316"}, "FXEURCAD": {"v": "1.4565"}}
+]
+}`
+
+func Test_ReadECBXML(t *testing.T) {
+	all, err := ReadECBXML(strings.NewReader(ecbXML))
+	assert.NoError(t, err)
+	assert.Equal(t, len(all), 2)
+	assert.Equal(t, all[0].Date.Format("2006/01/02"), "2024/01/04")
+	assert.Equal(t, all[1].Base, "EUR")
+	assert.EqualStrings(t, all[1].Currencies(), "CAD", "EUR", "USD")
+	assert.Equal(t, all[1].Rate("USD", "CAD").FloatString(4), "1.3376")
+	assert.Equal(t, all[1].Rate("EUR", "CAD").FloatString(4), "1.4608")
+	assert.Equal(t, all[1].Rate("CAD", "EUR").FloatString(4), "0.6846")
+	assert.True(t, all[1].Rate("JPY", "CAD") == nil)
+}
+
+func Test_ReadECBCSV(t *testing.T) {
+	all, err := ReadECBCSV(strings.NewReader(ecbHistCSV))
+	assert.NoError(t, err)
+	assert.Equal(t, len(all), 2)
+	assert.Equal(t, all[1].Date.Format("2006/01/02"), "2024/01/05")
+	assert.EqualStrings(t, all[1].Currencies(), "CAD", "EUR", "JPY", "USD")
+	assert.Equal(t, all[1].Rate("USD", "CAD").FloatString(4), "1.3376")
+
+	all, err = ReadECBCSV(strings.NewReader(ecbDailyCSV))
+	assert.NoError(t, err)
+	assert.Equal(t, len(all), 1)
+	assert.Equal(t, all[0].Date.Format("2006/01/02"), "2024/01/05")
+	assert.Equal(t, all[0].Rate("USD", "JPY").FloatString(2), "144.75")
+}
+
+func Test_ReadValet(t *testing.T) {
+	all, err := ReadValet(strings.NewReader(valetJSON))
+	assert.NoError(t, err)
+	assert.Equal(t, len(all), 2)
+	assert.Equal(t, all[0].Date.Format("2006/01/02"), "2024/01/02")
+	assert.Equal(t, all[0].Base, "CAD")
+	assert.EqualStrings(t, all[0].Currencies(), "CAD", "EUR", "USD")
+	assert.Equal(t, all[0].Rate("USD", "CAD").FloatString(4), "1.3316")
+	assert.Equal(t, all[0].Rate("EUR", "USD").FloatString(4), "1.0938")
+}
@@ -3,6 +3,7 @@ package gnucash
 import (
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/mkobetic/coin"
@@ -63,6 +64,24 @@ type Account struct {
 	Slots          []*KvpSlot `xml:"slots>slot"`
 }
 
+func (a *Account) write(x *xmlWriter) {
+	x.start("gnc:account", "version", "2.0.0")
+	x.element("act:name", a.Name)
+	x.element("act:id", a.Guid, "type", "guid")
+	x.element("act:type", a.Type)
+	if a.CommodityId != "" {
+		x.commodity("act:commodity", a.CommoditySpace, a.CommodityId)
+		x.element("act:commodity-scu", strconv.Itoa(a.CommodityScu))
+	}
+	x.optional("act:code", a.Code)
+	x.optional("act:description", a.Description)
+	x.slots("act:slots", a.Slots)
+	if a.ParentGuid != "" {
+		x.element("act:parent", a.ParentGuid, "type", "guid")
+	}
+	x.end("gnc:account")
+}
+
 func AccountFrom(a *Account) *coin.Account {
 	var name string
 	if a.Name == "Root Account" {
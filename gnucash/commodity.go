@@ -2,6 +2,7 @@ package gnucash
 
 import (
 	"encoding/xml"
+	"strconv"
 
 	"github.com/mkobetic/coin"
 )
@@ -38,6 +39,17 @@ type Commodity struct {
 	Slots       []*KvpSlot `xml:"slots>slot"`
 }
 
+func (c *Commodity) write(x *xmlWriter) {
+	x.start("gnc:commodity", "version", "2.0.0")
+	x.element("cmdty:space", c.Space)
+	x.element("cmdty:id", c.Id)
+	x.optional("cmdty:name", c.Name)
+	x.optional("cmdty:xcode", c.Code)
+	x.element("cmdty:fraction", strconv.FormatInt(c.Fraction, 10))
+	x.slots("cmdty:slots", c.Slots)
+	x.end("gnc:commodity")
+}
+
 func CommodityFrom(c *Commodity) *coin.Commodity {
 	cc := &coin.Commodity{
 		Id:     c.Id,
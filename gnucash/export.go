@@ -0,0 +1,330 @@
+package gnucash
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/mkobetic/coin"
+)
+
+const (
+	// CurrencySpace is the space of the currency commodities.
+	CurrencySpace = "ISO4217"
+	// SecuritySpace is the space of the other exported commodities.
+	SecuritySpace = "FUND"
+	// OpeningBalances is the equity account of the opening balance transactions.
+	OpeningBalances = "Opening Balances"
+)
+
+// exporter builds a book from the coin ledger.
+type exporter struct {
+	book       *Book
+	currencies map[*coin.Commodity]bool
+	accounts   map[accountKey]*Account // exported accounts by coin account and commodity
+	opening    *Account
+}
+
+// accountKey identifies the exported account of the postings of a coin account in a commodity.
+type accountKey struct {
+	account   *coin.Account
+	commodity *coin.Commodity
+}
+
+// NewBook builds a book from the loaded coin ledger with the transactions posted from begin until end (excluded),
+// zero begin or end means unbounded, the prices are exported until end.
+// If begin is set, the balances of the accounts (except Income and Expenses) as of begin
+// are posted as opening balance transactions against the Equity:Opening Balances account.
+// The ids of the book objects are derived from their names and order,
+// exporting the same ledger again produces the same ids.
+//
+// GnuCash needs to know which commodities are currencies, a commodity is a currency
+// if it is the default commodity or the currency of a price. Each transaction is valued in the currency
+// of its first posting in a currency (or the default commodity),
+// the values of the postings in other commodities are converted using the prices.
+func NewBook(begin, end time.Time) (*Book, error) {
+	x := &exporter{
+		book:       &Book{Guid: guid("book", begin, end)},
+		currencies: map[*coin.Commodity]bool{coin.DefaultCommodity(): true},
+		accounts:   map[accountKey]*Account{},
+	}
+	for _, p := range coin.Prices {
+		x.currencies[p.Currency] = true
+	}
+	coin.CommoditiesDo(func(c *coin.Commodity) {
+		x.book.Commodities = append(x.book.Commodities, x.commodity(c))
+	})
+	for i, p := range coin.Prices {
+		if end.IsZero() || p.Time.Before(end) {
+			x.book.Prices = append(x.book.Prices, x.price(i, p))
+		}
+	}
+	coin.Root.WithChildrenDo(func(a *coin.Account) {
+		x.account(a, a.Commodity)
+	})
+	if !begin.IsZero() {
+		if err := x.openingBalances(begin); err != nil {
+			return nil, err
+		}
+	}
+	for i, t := range coin.Transactions {
+		if t.Posted.Before(begin) || !end.IsZero() && !t.Posted.Before(end) {
+			continue
+		}
+		gt, err := x.transaction(i, t)
+		if err != nil {
+			return nil, err
+		}
+		x.book.Transactions = append(x.book.Transactions, gt)
+	}
+	return x.book, nil
+}
+
+// guid returns an id derived from the key values.
+func guid(key ...interface{}) string {
+	sum := md5.Sum([]byte(fmt.Sprintln(key...)))
+	return hex.EncodeToString(sum[:])
+}
+
+// fraction returns the amount as GncNumeric.
+func fraction(a *coin.Amount) string {
+	return fmt.Sprintf("%s/%s", a.Int, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(a.Decimals)), nil))
+}
+
+func (x *exporter) space(c *coin.Commodity) string {
+	if x.currencies[c] {
+		return CurrencySpace
+	}
+	return SecuritySpace
+}
+
+func (x *exporter) commodity(c *coin.Commodity) *Commodity {
+	gc := &Commodity{Space: x.space(c), Id: c.Id, Name: c.Name, Code: c.Code, Fraction: 1}
+	for i := 0; i < c.Decimals; i++ {
+		gc.Fraction *= 10
+	}
+	return gc
+}
+
+func (x *exporter) price(i int, p *coin.Price) *Price {
+	return &Price{
+		Guid:           guid("price", i, p.Commodity.Id, p.Currency.Id, p.Time),
+		CommoditySpace: x.space(p.Commodity),
+		CommodityId:    p.Commodity.Id,
+		CurrencySpace:  x.space(p.Currency),
+		CurrencyId:     p.Currency.Id,
+		Date:           p.Time.Format(TimeStamp),
+		Source:         "user:price",
+		ValueFraction:  fraction(p.Value),
+	}
+}
+
+// account returns the exported account of the postings of the coin account in the commodity,
+// creating it if necessary. Postings in other than the account commodity (e.g. Unbalanced)
+// get a sibling account suffixed with the commodity id.
+func (x *exporter) account(a *coin.Account, c *coin.Commodity) *Account {
+	if c == nil {
+		c = coin.DefaultCommodity()
+	}
+	key := accountKey{a, c}
+	if ga := x.accounts[key]; ga != nil {
+		return ga
+	}
+	ga := &Account{
+		Name:        a.Name,
+		Type:        x.accountType(a),
+		Code:        a.Code,
+		Description: a.Description,
+	}
+	if a == coin.Root {
+		ga.Name = "Root Account"
+	} else {
+		if a == coin.Unbalanced {
+			ga.Name = "Imbalance-" + c.Id
+		} else if a.Commodity != nil && c != a.Commodity {
+			ga.Name = a.Name + "-" + c.Id
+		}
+		ga.ParentGuid = x.account(a.Parent, a.Parent.Commodity).Guid
+		ga.CommoditySpace, ga.CommodityId, ga.CommodityScu = x.space(c), c.Id, int(x.commodity(c).Fraction)
+	}
+	ga.Guid = guid("account", a.FullName, ga.Name)
+	if a.IsClosed() {
+		ga.Slots = append(ga.Slots, &KvpSlot{Key: "hidden", Value: KvpValue{Type: "string", Value: "true"}})
+	}
+	x.accounts[key] = ga
+	x.book.Accounts = append(x.book.Accounts, ga)
+	return ga
+}
+
+// accountType returns the GnuCash type of the account, the obsolete Type (from gc2coin) if set,
+// otherwise it is inferred from the name of the top level account,
+// Assets accounts with other than currency commodity are STOCK.
+func (x *exporter) accountType(a *coin.Account) string {
+	if a.Type != "" {
+		return a.Type
+	}
+	if a == coin.Root {
+		return "ROOT"
+	}
+	if a == coin.Unbalanced {
+		return "BANK" // like GnuCash Imbalance accounts
+	}
+	top := a
+	for top.Parent != nil && top.Parent != coin.Root {
+		top = top.Parent
+	}
+	switch name := strings.ToLower(top.Name); {
+	case strings.HasPrefix(name, "asset"):
+		if a.Commodity != nil && !x.currencies[a.Commodity] {
+			return "STOCK"
+		}
+		return "ASSET"
+	case strings.HasPrefix(name, "liabilit"):
+		return "LIABILITY"
+	case strings.HasPrefix(name, "income"), strings.HasPrefix(name, "revenue"):
+		return "INCOME"
+	case strings.HasPrefix(name, "expense"):
+		return "EXPENSE"
+	case strings.HasPrefix(name, "equity"):
+		return "EQUITY"
+	}
+	return "ASSET"
+}
+
+// openingAccount returns the Equity:Opening Balances account, creating it and Equity if necessary.
+func (x *exporter) openingAccount() *Account {
+	if x.opening != nil {
+		return x.opening
+	}
+	root := x.account(coin.Root, coin.Root.Commodity)
+	c := coin.DefaultCommodity()
+	newAccount := func(name string, parent *Account) *Account {
+		ga := &Account{
+			Guid:           guid("account", parent.Name, name),
+			Name:           name,
+			Type:           "EQUITY",
+			CommoditySpace: CurrencySpace,
+			CommodityId:    c.Id,
+			CommodityScu:   int(x.commodity(c).Fraction),
+			ParentGuid:     parent.Guid,
+		}
+		x.book.Accounts = append(x.book.Accounts, ga)
+		return ga
+	}
+	var equity *Account
+	for _, a := range coin.Root.Children {
+		if x.accountType(a) == "EQUITY" {
+			equity = x.account(a, a.Commodity)
+			break
+		}
+	}
+	if equity == nil {
+		equity = newAccount("Equity", root)
+	}
+	x.opening = newAccount(OpeningBalances, equity)
+	return x.opening
+}
+
+// openingBalances adds a transaction posting the balance of each account as of begin
+// against the opening balances account, the Income and Expenses accounts start from zero.
+func (x *exporter) openingBalances(begin time.Time) error {
+	var keys []accountKey
+	balances := map[accountKey]*coin.Amount{}
+	coin.Root.WithChildrenDo(func(a *coin.Account) {
+		if typ := x.accountType(a); typ == "INCOME" || typ == "EXPENSE" {
+			return
+		}
+		for _, p := range a.Postings {
+			if !p.Transaction.Posted.Before(begin) {
+				break
+			}
+			key := accountKey{a, p.Quantity.Commodity}
+			b := balances[key]
+			if b == nil {
+				b = coin.NewZeroAmount(key.commodity)
+				balances[key] = b
+				keys = append(keys, key)
+			}
+			b.AddIn(p.Quantity) // same commodity, can't fail
+		}
+	})
+	c := coin.DefaultCommodity()
+	for i, key := range keys {
+		balance := balances[key]
+		if balance.IsZero() {
+			continue
+		}
+		currency := key.commodity
+		if !x.currencies[currency] {
+			currency = c
+		}
+		value, _, err := currency.ConvertAt(balance, balance.Commodity, begin)
+		if err != nil {
+			return fmt.Errorf("opening balance of %s: %w", key.account.FullName, err)
+		}
+		quantity, _, err := c.ConvertAt(value, currency, begin)
+		if err != nil {
+			return fmt.Errorf("opening balance of %s: %w", key.account.FullName, err)
+		}
+		id := guid("opening", i, key.account.FullName, key.commodity.Id)
+		x.book.Transactions = append(x.book.Transactions, &Transaction{
+			Guid:          id,
+			CurrencySpace: CurrencySpace,
+			CurrencyId:    currency.Id,
+			PostedStamp:   begin.Format(TimeStamp),
+			EnteredStamp:  begin.Format(TimeStamp),
+			Description:   "Opening Balance",
+			Splits: []*Split{
+				x.split(id, 0, x.account(key.account, key.commodity), value, balance, nil),
+				x.split(id, 1, x.openingAccount(), value.Negated(), quantity.Negated(), nil),
+			},
+		})
+	}
+	return nil
+}
+
+// transaction returns the exported transaction, the transaction notes are appended to the description
+// and the posting notes are the split memos (like gc2coin reads them).
+func (x *exporter) transaction(i int, t *coin.Transaction) (*Transaction, error) {
+	currency := coin.DefaultCommodity()
+	for _, p := range t.Postings {
+		if x.currencies[p.Quantity.Commodity] {
+			currency = p.Quantity.Commodity
+			break
+		}
+	}
+	id := guid("transaction", i, t.Location(), t.Posted, t.Description)
+	gt := &Transaction{
+		Guid:          id,
+		CurrencySpace: CurrencySpace,
+		CurrencyId:    currency.Id,
+		Num:           t.Code,
+		PostedStamp:   t.Posted.Format(TimeStamp),
+		EnteredStamp:  t.Posted.Format(TimeStamp),
+		Description:   strings.Join(append([]string{t.Description}, t.Notes...), " - "),
+	}
+	// the splits must balance in value
+	values, err := t.Values(currency)
+	if err != nil {
+		return nil, err
+	}
+	for j, p := range t.Postings {
+		account := x.account(p.Account, p.Quantity.Commodity)
+		gt.Splits = append(gt.Splits, x.split(id, j, account, values[j], p.Quantity, p.Notes))
+	}
+	return gt, nil
+}
+
+func (x *exporter) split(transaction string, i int, account *Account, value, quantity *coin.Amount, notes []string) *Split {
+	return &Split{
+		Guid:             guid("split", transaction, i),
+		AccountGuid:      account.Guid,
+		Memo:             strings.Join(notes, "; "),
+		ReconciledState:  "n",
+		ValueFraction:    fraction(value),
+		QuantityFraction: fraction(quantity),
+	}
+}
@@ -5,8 +5,10 @@ import (
 	"compress/gzip"
 	"encoding/xml"
 	"fmt"
+	"io"
 	"math/big"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -23,6 +25,7 @@ type Gnucash struct {
 }
 
 type Book struct {
+	Guid         string         `xml:"id"`
 	Accounts     []*Account     `xml:"account"`
 	Commodities  []*Commodity   `xml:"commodity"`
 	Prices       []*Price       `xml:"pricedb>price"`
@@ -59,6 +62,142 @@ func Load(fn string) *Book {
 	return &(db.Book)
 }
 
+// Save writes the book into a gzipped GnuCash XML file.
+func (book *Book) Save(fn string) error {
+	file, err := os.Create(fn)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	w := gzip.NewWriter(file)
+	if err = book.Write(w); err != nil {
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+var namespaces = []string{"gnc", "act", "book", "cd", "cmdty", "price", "slot", "split", "trn", "ts"}
+
+// Write writes the book as GnuCash XML.
+func (book *Book) Write(w io.Writer) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	x := &xmlWriter{Encoder: xml.NewEncoder(w)}
+	x.Indent("", "  ")
+	var attrs []string
+	for _, ns := range namespaces {
+		attrs = append(attrs, "xmlns:"+ns, "http://www.gnucash.org/XML/"+ns)
+	}
+	x.start("gnc-v2", attrs...)
+	x.count("book", 1)
+	x.start("gnc:book", "version", "2.0.0")
+	x.element("book:id", book.Guid, "type", "guid")
+	x.count("commodity", len(book.Commodities))
+	x.count("account", len(book.Accounts))
+	x.count("transaction", len(book.Transactions))
+	x.count("price", len(book.Prices))
+	for _, c := range book.Commodities {
+		c.write(x)
+	}
+	if len(book.Prices) > 0 {
+		x.start("gnc:pricedb", "version", "1")
+		for _, p := range book.Prices {
+			p.write(x)
+		}
+		x.end("gnc:pricedb")
+	}
+	for _, a := range book.Accounts {
+		a.write(x)
+	}
+	for _, t := range book.Transactions {
+		t.write(x)
+	}
+	x.end("gnc:book")
+	x.end("gnc-v2")
+	if x.err == nil {
+		x.err = x.Flush()
+	}
+	return x.err
+}
+
+// xmlWriter writes the namespace prefixed elements of GnuCash XML (see README),
+// the first error is kept and the following writes are ignored.
+type xmlWriter struct {
+	*xml.Encoder
+	err error
+}
+
+// start writes a start tag, the attrs are name and value pairs.
+func (x *xmlWriter) start(name string, attrs ...string) {
+	if x.err != nil {
+		return
+	}
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	for i := 0; i+1 < len(attrs); i += 2 {
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: attrs[i]}, Value: attrs[i+1]})
+	}
+	x.err = x.EncodeToken(start)
+}
+
+func (x *xmlWriter) end(name string) {
+	if x.err != nil {
+		return
+	}
+	x.err = x.EncodeToken(xml.EndElement{Name: xml.Name{Local: name}})
+}
+
+// element writes an element with the text, the attrs are name and value pairs.
+func (x *xmlWriter) element(name, text string, attrs ...string) {
+	x.start(name, attrs...)
+	if x.err == nil && text != "" {
+		x.err = x.EncodeToken(xml.CharData(text))
+	}
+	x.end(name)
+}
+
+// optional writes an element with the text unless the text is empty.
+func (x *xmlWriter) optional(name, text string) {
+	if text != "" {
+		x.element(name, text)
+	}
+}
+
+func (x *xmlWriter) count(typ string, n int) {
+	x.element("gnc:count-data", strconv.Itoa(n), "cd:type", typ)
+}
+
+func (x *xmlWriter) commodity(name, space, id string) {
+	x.start(name)
+	x.element("cmdty:space", space)
+	x.element("cmdty:id", id)
+	x.end(name)
+}
+
+func (x *xmlWriter) timestamp(name, stamp string) {
+	x.start(name)
+	x.element("ts:date", stamp)
+	x.end(name)
+}
+
+// slots writes the slots with string values.
+func (x *xmlWriter) slots(name string, slots []*KvpSlot) {
+	if len(slots) == 0 {
+		return
+	}
+	x.start(name)
+	for _, s := range slots {
+		x.start("slot")
+		x.element("slot:key", s.Key)
+		x.element("slot:value", s.Value.Value, "type", s.Value.Type)
+		x.end("slot")
+	}
+	x.end(name)
+}
+
 // TimeStamp is GnuCash time format
 const TimeStamp = "2006-01-02 15:04:05 -0700"
 
@@ -1,9 +1,12 @@
 package gnucash
 
 import (
+	"bytes"
 	"encoding/xml"
 	"fmt"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/mkobetic/coin"
 	"github.com/mkobetic/coin/assert"
@@ -27,13 +30,19 @@ func Test_Amount(t *testing.T) {
 	}
 }
 
-func Test_Unmarshaling(t *testing.T) {
-	db := Gnucash{Book: Book{}}
-	err := xml.Unmarshal(Sample, &db)
-	if err != nil {
-		t.Error(err)
-	}
+// loadSample resolves the Sample book replacing the previously loaded accounts, transactions and prices.
+func loadSample(t *testing.T) {
+	coin.DropTransactions()
+	coin.Prices = nil
+	AccountsByGuid = map[string]*coin.Account{}
+	AccountParentGuids = map[*coin.Account]string{}
+	var db Gnucash
+	assert.NoError(t, xml.Unmarshal(Sample, &db))
 	db.Book.Resolve()
+}
+
+func Test_Unmarshaling(t *testing.T) {
+	loadSample(t)
 
 	exp := []string{
 		"0.00 CAD        Root [0]",
@@ -434,3 +443,66 @@ var Sample = []byte(`
 <!-- mode: xml        -->
 <!-- End:             -->
 `)
+
+func Test_Export(t *testing.T) {
+	loadSample(t)
+	splits := func(tr *Transaction) string {
+		var ss []string
+		for _, s := range tr.Splits {
+			ss = append(ss, s.ValueFraction+" "+s.QuantityFraction)
+		}
+		return strings.Join(ss, ",")
+	}
+	book, err := NewBook(time.Time{}, time.Time{})
+	assert.NoError(t, err)
+	var b bytes.Buffer
+	assert.NoError(t, book.Write(&b))
+	out := b.String()
+	assert.True(t, strings.Contains(out, `<gnc:count-data cd:type="transaction">4</gnc:count-data>`))
+	assert.True(t, strings.Contains(out, `<act:name>Root Account</act:name>`))
+
+	var db Gnucash
+	assert.NoError(t, xml.Unmarshal(b.Bytes(), &db))
+	assert.Equal(t, db.Book.Guid, book.Guid)
+	assert.Equal(t, len(db.Book.Commodities), 3)
+	assert.Equal(t, len(db.Book.Prices), 3)
+	assert.Equal(t, len(db.Book.Accounts), 6)
+	assert.Equal(t, db.Book.Accounts[0].Type, "ROOT")
+	assert.Equal(t, db.Book.Accounts[1].ParentGuid, db.Book.Accounts[0].Guid)
+	for i, exp := range []string{
+		"CAD Contribution 976509/100 976509/100,-976509/100 -976509/100",
+		"CAD Investment 195300/100 82612/1000,-195300/100 -195300/100",
+		"CAD Sienna 4500/100 4500/100,-4500/100 -4500/100",
+		"CAD Sienna 3751/100 3751/100,-3751/100 -3751/100",
+	} {
+		tr := db.Book.Transactions[i]
+		assert.Equal(t, fmt.Sprintf("%s %s %s", tr.CurrencyId, tr.Description, splits(tr)), exp)
+	}
+
+	book, err = NewBook(mustParseTimeStamp("2016-01-01 00:00:00 -0500"), time.Time{})
+	assert.NoError(t, err)
+	assert.Equal(t, len(book.Transactions), 3)
+	assert.Equal(t, book.Accounts[len(book.Accounts)-1].Name, OpeningBalances)
+	for i, exp := range []string{
+		"Assets -976509/100 -976509/100,976509/100 976509/100",
+		"Bank 772958/100 772958/100,-772958/100 -772958/100",
+		"Investments 219004/100 82612/1000,-219004/100 -219004/100",
+	} {
+		tr := book.Transactions[i]
+		var name string
+		for _, a := range book.Accounts {
+			if a.Guid == tr.Splits[0].AccountGuid {
+				name = a.Name
+			}
+		}
+		assert.Equal(t, name+" "+splits(tr), exp)
+	}
+
+	x := &exporter{currencies: map[*coin.Commodity]bool{coin.Commodities["CAD"]: true}}
+	expenses := &coin.Account{Name: "Expenses", Parent: coin.Root}
+	assert.Equal(t, x.accountType(&coin.Account{Name: "Food", Parent: expenses}), "EXPENSE")
+	assets := &coin.Account{Name: "Assets", Parent: coin.Root}
+	assert.Equal(t, x.accountType(&coin.Account{Name: "Bank", Parent: assets, Commodity: coin.Commodities["CAD"]}), "ASSET")
+	assert.Equal(t, x.accountType(&coin.Account{Name: "ZLB", Parent: assets, Commodity: coin.Commodities["ZLB"]}), "STOCK")
+	assert.Equal(t, x.accountType(&coin.Account{Name: "Visa", Parent: &coin.Account{Name: "Liabilities", Parent: coin.Root}}), "LIABILITY")
+}
@@ -36,6 +36,18 @@ type Price struct {
 	Type           string `xml:"type,omitempty"`
 }
 
+func (p *Price) write(x *xmlWriter) {
+	x.start("price")
+	x.element("price:id", p.Guid, "type", "guid")
+	x.commodity("price:commodity", p.CommoditySpace, p.CommodityId)
+	x.commodity("price:currency", p.CurrencySpace, p.CurrencyId)
+	x.timestamp("price:time", p.Date)
+	x.optional("price:source", p.Source)
+	x.optional("price:type", p.Type)
+	x.element("price:value", p.ValueFraction)
+	x.end("price")
+}
+
 func resolvePrices(prices []*Price) {
 	for _, gp := range prices {
 		p := &coin.Price{}
@@ -38,6 +38,21 @@ type Split struct {
 	QuantityFraction string `xml:"quantity"`
 }
 
+func (s *Split) write(x *xmlWriter) {
+	x.start("trn:split")
+	x.element("split:id", s.Guid, "type", "guid")
+	x.optional("split:memo", s.Memo)
+	x.optional("split:action", s.Action)
+	x.element("split:reconciled-state", s.ReconciledState)
+	if s.ReconciledStamp != "" {
+		x.timestamp("split:reconcile-date", s.ReconciledStamp)
+	}
+	x.element("split:value", s.ValueFraction)
+	x.element("split:quantity", s.QuantityFraction)
+	x.element("split:account", s.AccountGuid, "type", "guid")
+	x.end("trn:split")
+}
+
 func resolveSplits(splits []*Split, t *coin.Transaction) {
 	for _, gs := range splits {
 		s := &coin.Posting{Transaction: t}
@@ -61,6 +61,22 @@ type Transaction struct {
 	Splits        []*Split `xml:"splits>split"`
 }
 
+func (t *Transaction) write(x *xmlWriter) {
+	x.start("gnc:transaction", "version", "2.0.0")
+	x.element("trn:id", t.Guid, "type", "guid")
+	x.commodity("trn:currency", t.CurrencySpace, t.CurrencyId)
+	x.optional("trn:num", t.Num)
+	x.timestamp("trn:date-posted", t.PostedStamp)
+	x.timestamp("trn:date-entered", t.EnteredStamp)
+	x.optional("trn:description", t.Description)
+	x.start("trn:splits")
+	for _, s := range t.Splits {
+		s.write(x)
+	}
+	x.end("trn:splits")
+	x.end("gnc:transaction")
+}
+
 func resolveTransactions(transactions []*Transaction) {
 	for _, gt := range transactions {
 		ds := strings.SplitN(gt.Description, " - ", 2)
@@ -0,0 +1,228 @@
+// Package camt imports ISO 20022 camt.053 (bank to customer statement) XML files.
+package camt
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/mkobetic/coin"
+	"github.com/mkobetic/coin/importer"
+	"github.com/mkobetic/coin/redact"
+)
+
+// Importer reads camt.053 files, the accounts are matched by the statement account IBAN (or other id),
+// see coin.RuleIndex.AccountRulesFor.
+type Importer struct {
+	Rules *coin.RuleIndex
+}
+
+func (*Importer) Name() string { return "camt" }
+
+func (*Importer) Accepts(fileName string, head []byte) bool {
+	return bytes.Contains(head, []byte("camt.053")) || bytes.Contains(head, []byte("<BkToCstmrStmt>"))
+}
+
+// Read converts the booked statement entries into entries,
+// the closing booked balance of the statement is attached to its last entry.
+// The entries are identified by the bank reference (AcctSvcrRef).
+func (imp *Importer) Read(r io.Reader) (*importer.Statement, error) {
+	statements, err := readStatements(r)
+	if err != nil {
+		return nil, err
+	}
+	red, err := redact.New(imp.Rules.Redact...)
+	if err != nil {
+		return nil, err
+	}
+	s := &importer.Statement{Redactor: red}
+	for _, st := range statements {
+		rules := imp.Rules.AccountRulesFor(st.accountId())
+		currency := coin.Commodities[st.Currency]
+		var last *importer.Entry
+		for _, e := range st.Entries {
+			if !e.isBooked() {
+				continue
+			}
+			date, err := e.date()
+			if err != nil {
+				return nil, fmt.Errorf("statement %s: %w", st.Id, err)
+			}
+			amount, err := e.value()
+			if err != nil {
+				return nil, fmt.Errorf("statement %s: %w", st.Id, err)
+			}
+			entry := &importer.Entry{
+				Rules:       rules,
+				Id:          strings.TrimSpace(e.Reference),
+				Posted:      date,
+				Description: e.description(),
+				Amount:      amount,
+				Currency:    currency,
+			}
+			if last == nil || !date.Before(last.Posted) {
+				last = entry
+			}
+			s.Entries = append(s.Entries, entry)
+		}
+		if b := st.closingBalance(); b != nil && last != nil {
+			if last.Balance, err = b.value(); err != nil {
+				return nil, fmt.Errorf("statement %s: %w", st.Id, err)
+			}
+		}
+	}
+	return s, nil
+}
+
+// ISO 20022 camt.053 (bank to customer statement) structures.
+// Only the elements used for the import are mapped,
+// the tags are namespace agnostic to handle all the message versions.
+
+type document struct {
+	Statements []*statement `xml:"BkToCstmrStmt>Stmt"`
+}
+
+type statement struct {
+	Id       string     `xml:"Id"`
+	IBAN     string     `xml:"Acct>Id>IBAN"`
+	Other    string     `xml:"Acct>Id>Othr>Id"`
+	Currency string     `xml:"Acct>Ccy"`
+	Balances []*balance `xml:"Bal"`
+	Entries  []*entry   `xml:"Ntry"`
+}
+
+type amount struct {
+	Value    string `xml:",chardata"`
+	Currency string `xml:"Ccy,attr"`
+}
+
+type date struct {
+	Date     string `xml:"Dt"`
+	DateTime string `xml:"DtTm"`
+}
+
+type balance struct {
+	Code      string `xml:"Tp>CdOrPrtry>Cd"`
+	Amount    amount `xml:"Amt"`
+	Indicator string `xml:"CdtDbtInd"`
+	Date      date   `xml:"Dt"`
+}
+
+type entry struct {
+	Amount      amount   `xml:"Amt"`
+	Indicator   string   `xml:"CdtDbtInd"`
+	Status      status   `xml:"Sts"`
+	BookingDate date     `xml:"BookgDt"`
+	ValueDate   date     `xml:"ValDt"`
+	Reference   string   `xml:"AcctSvcrRef"`
+	Info        string   `xml:"AddtlNtryInf"`
+	Details     []detail `xml:"NtryDtls>TxDtls"`
+}
+
+// status is either <Sts>BOOK</Sts> or <Sts><Cd>BOOK</Cd></Sts> in newer versions
+type status struct {
+	Value string `xml:",chardata"`
+	Code  string `xml:"Cd"`
+}
+
+type detail struct {
+	Creditor    string   `xml:"RltdPties>Cdtr>Nm"`
+	CreditorPty string   `xml:"RltdPties>Cdtr>Pty>Nm"`
+	Debtor      string   `xml:"RltdPties>Dbtr>Nm"`
+	DebtorPty   string   `xml:"RltdPties>Dbtr>Pty>Nm"`
+	Remittance  []string `xml:"RmtInf>Ustrd"`
+	Info        string   `xml:"AddtlTxInf"`
+}
+
+func readStatements(r io.Reader) ([]*statement, error) {
+	var doc document
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, err
+	}
+	return doc.Statements, nil
+}
+
+// accountId returns the IBAN of the statement account or its other id.
+func (s *statement) accountId() string {
+	if s.IBAN != "" {
+		return strings.ReplaceAll(s.IBAN, " ", "")
+	}
+	return s.Other
+}
+
+// closingBalance returns the closing booked balance of the statement, if any.
+func (s *statement) closingBalance() *balance {
+	for _, b := range s.Balances {
+		if b.Code == "CLBD" {
+			return b
+		}
+	}
+	return nil
+}
+
+func (e *entry) isBooked() bool {
+	return strings.TrimSpace(e.Status.Value) == "BOOK" || e.Status.Code == "BOOK"
+}
+
+func (e *entry) date() (time.Time, error) {
+	if e.BookingDate.Date != "" || e.BookingDate.DateTime != "" {
+		return e.BookingDate.time()
+	}
+	return e.ValueDate.time()
+}
+
+// description composes the transaction description from the counterparty name
+// and the unstructured remittance information, falling back to the additional entry info.
+func (e *entry) description() string {
+	var parts []string
+	for _, d := range e.Details {
+		party := d.Creditor + d.CreditorPty
+		if e.Indicator == "CRDT" {
+			party = d.Debtor + d.DebtorPty
+		}
+		parts = append(parts, party)
+		parts = append(parts, d.Remittance...)
+	}
+	if s := importer.Trim(strings.Join(parts, " ")); s != "" {
+		return s
+	}
+	if len(e.Details) > 0 && e.Details[0].Info != "" {
+		return importer.Trim(e.Details[0].Info)
+	}
+	return importer.Trim(e.Info)
+}
+
+func (e *entry) value() (*big.Rat, error) {
+	return signed(e.Amount.Value, e.Indicator)
+}
+
+func (b *balance) value() (*big.Rat, error) {
+	return signed(b.Amount.Value, b.Indicator)
+}
+
+func signed(value, indicator string) (*big.Rat, error) {
+	v, ok := new(big.Rat).SetString(strings.TrimSpace(value))
+	if !ok {
+		return nil, fmt.Errorf("invalid amount: %s", value)
+	}
+	if indicator == "DBIT" {
+		v.Neg(v)
+	}
+	return v, nil
+}
+
+func (d date) time() (time.Time, error) {
+	s := d.Date
+	if s == "" && len(d.DateTime) >= 10 {
+		s = d.DateTime[:10]
+	}
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return t, err
+	}
+	return t.Add(12 * time.Hour), nil
+}
@@ -0,0 +1,132 @@
+package camt
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/mkobetic/coin"
+	"github.com/mkobetic/coin/assert"
+	"github.com/mkobetic/coin/importer"
+)
+
+func init() {
+	r := strings.NewReader(`
+commodity EUR
+  format 1.00 EUR
+  default
+
+commodity USD
+  format 1.00 USD
+
+account Assets:Bank:Giro
+  commodity EUR
+  import-id DE89370400440532013000
+account Assets:Bank:Giro:USD
+  commodity USD
+account Expenses:Groceries
+  commodity EUR
+account Income:Salary
+  commodity EUR
+`)
+	coin.Load(r, "")
+	coin.ResolveAccounts()
+}
+
+const rulesSample = `DE89370400440532013000 Assets:Bank:Giro
+  Income:Salary       ACME GmbH
+  Expenses:Groceries  REWE
+`
+
+const sample = `<?xml version="1.0" encoding="UTF-8"?>
+<Document xmlns="urn:iso:std:iso:20022:tech:xsd:camt.053.001.02">
+  <BkToCstmrStmt>
+    <GrpHdr><MsgId>1</MsgId></GrpHdr>
+    <Stmt>
+      <Id>STMT-1</Id>
+      <Acct><Id><IBAN>DE89370400440532013000</IBAN></Id><Ccy>EUR</Ccy></Acct>
+      <Bal>
+        <Tp><CdOrPrtry><Cd>OPBD</Cd></CdOrPrtry></Tp>
+        <Amt Ccy="EUR">100.00</Amt><CdtDbtInd>CRDT</CdtDbtInd>
+        <Dt><Dt>2020-01-01</Dt></Dt>
+      </Bal>
+      <Bal>
+        <Tp><CdOrPrtry><Cd>CLBD</Cd></CdOrPrtry></Tp>
+        <Amt Ccy="EUR">2054.33</Amt><CdtDbtInd>CRDT</CdtDbtInd>
+        <Dt><Dt>2020-01-31</Dt></Dt>
+      </Bal>
+      <Ntry>
+        <Amt Ccy="EUR">2000.00</Amt><CdtDbtInd>CRDT</CdtDbtInd>
+        <Sts>BOOK</Sts>
+        <BookgDt><Dt>2020-01-28</Dt></BookgDt>
+        <AcctSvcrRef>2020012800042</AcctSvcrRef>
+        <NtryDtls><TxDtls>
+          <RltdPties><Dbtr><Nm>ACME GmbH</Nm></Dbtr><Cdtr><Nm>John Doe</Nm></Cdtr></RltdPties>
+          <RmtInf><Ustrd>Salary January</Ustrd></RmtInf>
+        </TxDtls></NtryDtls>
+      </Ntry>
+      <Ntry>
+        <Amt Ccy="EUR">45.67</Amt><CdtDbtInd>DBIT</CdtDbtInd>
+        <Sts>BOOK</Sts>
+        <BookgDt><DtTm>2020-01-05T10:00:00</DtTm></BookgDt>
+        <NtryDtls><TxDtls>
+          <RltdPties><Cdtr><Nm>REWE   Markt</Nm></Cdtr></RltdPties>
+        </TxDtls></NtryDtls>
+      </Ntry>
+      <Ntry>
+        <Amt Ccy="EUR">10.00</Amt><CdtDbtInd>DBIT</CdtDbtInd>
+        <Sts>PDNG</Sts>
+        <BookgDt><Dt>2020-01-31</Dt></BookgDt>
+        <AddtlNtryInf>Pending</AddtlNtryInf>
+      </Ntry>
+    </Stmt>
+    <Stmt>
+      <Id>STMT-2</Id>
+      <Acct><Id><IBAN>DE89 3704 0044 0532 0130 00</IBAN></Id><Ccy>USD</Ccy></Acct>
+      <Ntry>
+        <Amt Ccy="USD">5.00</Amt><CdtDbtInd>DBIT</CdtDbtInd>
+        <Sts><Cd>BOOK</Cd></Sts>
+        <ValDt><Dt>2020-01-10</Dt></ValDt>
+        <AddtlNtryInf>Account fee</AddtlNtryInf>
+      </Ntry>
+    </Stmt>
+  </BkToCstmrStmt>
+</Document>
+`
+
+// readTransactions reads the statement and returns its transactions sorted by date.
+func readTransactions(t *testing.T, imp *Importer, sample string) coin.TransactionsByTime {
+	s, err := imp.Read(strings.NewReader(sample))
+	assert.NoError(t, err)
+	txs := coin.TransactionsByTime(s.Classify(importer.IdTag(imp)))
+	sort.Stable(txs)
+	return txs
+}
+
+func Test_Read(t *testing.T) {
+	rules, err := coin.ReadRules(strings.NewReader(rulesSample))
+	assert.NoError(t, err)
+	imp := &Importer{Rules: rules}
+	assert.True(t, imp.Accepts("statement.xml", []byte(sample)))
+	assert.True(t, !imp.Accepts("statement.xml", []byte("<Document></Document>")))
+	txs := readTransactions(t, imp, sample)
+	var b bytes.Buffer
+	for _, t := range txs {
+		t.Write(&b, false)
+		b.WriteString("\n")
+	}
+	assert.Equal(t, b.String(), `2020/01/05 REWE Markt
+  Expenses:Groceries   45.67 EUR
+  Assets:Bank:Giro    -45.67 EUR
+
+2020/01/10 Account fee
+  Unbalanced             5.00 USD
+  Assets:Bank:Giro:USD  -5.00 USD
+
+2020/01/28 ACME GmbH Salary January
+  Assets:Bank:Giro   2000.00 EUR = 2054.33 EUR ; #camtid: 2020012800042
+  Income:Salary     -2000.00 EUR
+
+`)
+}
@@ -0,0 +1,225 @@
+// Package csv imports CSV files, the records are mapped to the transaction values by the source rules (see csv2coin README).
+package csv
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mkobetic/coin"
+	"github.com/mkobetic/coin/check"
+	"github.com/mkobetic/coin/importer"
+	"github.com/mkobetic/coin/redact"
+)
+
+// Importer reads CSV files using the Source mapping,
+// the accounts are matched by the csv_acctid directive.
+type Importer struct {
+	Rules  *Rules
+	Source *Source
+}
+
+func (*Importer) Name() string { return "csv" }
+
+func (*Importer) Accepts(fileName string, head []byte) bool {
+	return importer.HasExtension(fileName, ".csv")
+}
+
+func (imp *Importer) Read(in io.Reader) (*importer.Statement, error) {
+	r := csv.NewReader(in)
+	for i := 0; i < imp.Source.skip; i++ {
+		row, err := r.Read()
+		if err, ok := err.(*csv.ParseError); ok && err.Err == csv.ErrFieldCount {
+			r.FieldsPerRecord = len(row)
+			continue
+		}
+		check.NoError(err, "Failed to read header line %d", i)
+	}
+	if len(imp.Source.filters) > 0 {
+		r.FieldsPerRecord = -1 // filtered out rows (e.g. summaries) can have any number of fields
+	}
+	red, err := redact.New(imp.Rules.redact()...)
+	if err != nil {
+		return nil, err
+	}
+	s := &importer.Statement{SubAccounts: true, Redactor: red}
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !imp.Source.Selects(rec) {
+			continue
+		}
+		e, err := entryFrom(rec, imp.Source, imp.Rules)
+		if err != nil {
+			idx := 0
+			if ve, ok := err.(*valueError); ok && ve.idx > 0 {
+				idx = ve.idx
+			}
+			line, _ := r.FieldPos(idx)
+			return nil, fmt.Errorf("line %d, %w", line, err)
+		}
+		s.Entries = append(s.Entries, e)
+	}
+	return s, nil
+}
+
+// valueError is an invalid value of a CSV record.
+type valueError struct {
+	name string // name of the value
+	idx  int    // index of the record field of the value, -1 if derived
+	err  error
+}
+
+func (e *valueError) Error() string {
+	if e.idx < 0 {
+		return fmt.Sprintf("%s: %s", e.name, e.err)
+	}
+	return fmt.Sprintf("field %d (%s): %s", e.idx, e.name, e.err)
+}
+
+func (e *valueError) Unwrap() error { return e.err }
+
+// entryFrom builds an entry from a csv row.
+// The dates and numbers are parsed with the source formats, invalid values are reported as a *valueError.
+func entryFrom(row []string, src *Source, rules *Rules) (*importer.Entry, error) {
+	valueFor := func(name string) string {
+		check.Includes(labels, name, "Invalid field name")
+		return src.Value(name, row)
+	}
+	invalid := func(name string, err error) error {
+		return &valueError{name: name, idx: src.Index(name, row), err: err}
+	}
+	acctId := valueFor("account")
+	ars := rules.AccountRulesFor(acctId)
+	check.If(ars != nil, "Can't find rules for account id %s", acctId)
+
+	date := valueFor("date")
+	posted, err := time.Parse(src.DateLayout(), date)
+	if err != nil {
+		return nil, invalid("date", fmt.Errorf("invalid date %q, expected format %s", date, src.DateLayout()))
+	}
+
+	e := &importer.Entry{
+		Rules:       ars,
+		Posted:      posted,
+		Description: importer.Trim(valueFor("description")),
+		Group:       valueFor("group"),
+	}
+	if n := importer.Trim(valueFor("note")); len(n) > 0 {
+		e.Notes = []string{n}
+	}
+
+	number := func(name string) (*big.Rat, error) {
+		v := valueFor(name)
+		if v == "" {
+			return nil, nil
+		}
+		n, err := src.NumberFormat().Parse(v)
+		if err != nil {
+			return nil, invalid(name, err)
+		}
+		return n, nil
+	}
+	if e.Amount, err = number("amount"); err != nil {
+		return nil, err
+	}
+	if e.Amount == nil {
+		// separate debit and credit columns, the signs of the values are ignored
+		debit, err := number("debit")
+		if err != nil {
+			return nil, err
+		}
+		credit, err := number("credit")
+		if err != nil {
+			return nil, err
+		}
+		if debit == nil && credit == nil {
+			return nil, invalid("amount", fmt.Errorf("amount not found"))
+		}
+		e.Amount = new(big.Rat)
+		if credit != nil {
+			e.Amount.Add(e.Amount, credit.Abs(credit))
+		}
+		if debit != nil {
+			e.Amount.Sub(e.Amount, debit.Abs(debit))
+		}
+	}
+	if e.Balance, err = number("balance"); err != nil {
+		return nil, err
+	}
+	if e.Fee, err = number("fee"); err != nil {
+		return nil, err
+	}
+	if e.Fee != nil {
+		e.Fee.Abs(e.Fee)
+		if name := valueFor("fee_account"); name != "" {
+			if e.FeeAccount, err = coin.FindAccount(name); err != nil {
+				return nil, invalid("fee_account", err)
+			}
+		}
+	}
+	if currency := valueFor("currency"); currency != "" {
+		if e.Currency = findCommodity(currency); e.Currency == nil {
+			return nil, invalid("currency", fmt.Errorf("unknown currency %s", currency))
+		}
+	}
+
+	symbol := valueFor("symbol")
+	if symbol == "" {
+		return e, nil
+	}
+	e.Commodity = findCommodity(symbol)
+	check.OK(e.Commodity != nil, "Could not find commodity for symbol %s", symbol)
+	if e.Quantity, err = number("quantity"); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func findCommodity(id string) *coin.Commodity {
+	if c := coin.Commodities[id]; c != nil {
+		return c
+	}
+	return coin.CommoditiesBySymbol[id]
+}
+
+var labels = []string{
+	"account",     //target account ID
+	"description", // transaction description
+	"date",        // date of the transaction
+	"amount",      // the cost of the transaction
+	"currency",    // optional currency of the transaction cost
+	"symbol",      // optional symbol of the commodity that was traded
+	"quantity",    // optional quantity of the commodity that was traded
+	"note",        // optional note associated with the transaction
+	"debit",       // optional amount taken out of the account, used if amount is empty
+	"credit",      // optional amount put into the account, used if amount is empty
+	"fee",         // optional fee charged to the account in addition to the amount
+	"fee_account", // optional account of the fee (default Unbalanced)
+	"balance",     // optional balance of the account after the transaction
+	"group",       // optional key combining consecutive rows into one transaction
+}
+
+// FieldsSource returns a source mapping the values directly from the fields with the listed indexes,
+// in the order of the values in the labels list. Trailing optional values can be omitted.
+func FieldsSource(list string) *Source {
+	idxs := strings.Split(list, ",")
+	check.If(len(idxs) >= 4 && len(idxs) <= len(labels),
+		"4 to %d fields must be specified:\n%v\n", len(labels), labels)
+	fields := make(map[string]Fields)
+	for i, s := range idxs {
+		c, err := strconv.Atoi(s)
+		check.NoError(err, "%s is not a valid column index", i)
+		fields[labels[i]] = Fields{&Field{&c, "", "", nil}}
+	}
+	return &Source{fields: fields}
+}
@@ -0,0 +1,315 @@
+package csv
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/mkobetic/coin"
+	"github.com/mkobetic/coin/assert"
+	"github.com/mkobetic/coin/importer"
+)
+
+var rules *Rules
+
+func init() {
+	coin.DefaultCommodityId = "USD"
+
+	r := strings.NewReader(`
+commodity USD
+commodity CAD
+commodity VXF
+  format 1.000 VXF
+  symbol VXF
+commodity VAB
+  format 1 VAB
+commodity VCN
+  format 1 VCN
+commodity BND
+  format 1 BND
+commodity XBAL
+  format 1 XBAL
+account Assets:Investments:XXX
+account Assets:Investments:XXX:VXF
+  commodity VXF
+account Assets:Investments:XXX:USD
+  commodity USD
+account Assets:Investments:BBB
+account Assets:Investments:BBB:CAD
+  commodity CAD
+account Assets:Investments:BBB:VAB
+  commodity VAB
+account Assets:Investments:BBB:BND
+  commodity BND
+account Assets:Investments:BBB:VCN
+  commodity VCN
+account Assets:Investments:BBB:XBAL
+  commodity XBAL
+account Expenses:Fees
+account Income:Dividends
+account Income:Interest
+  commodity CAD
+`)
+	coin.Load(r, "")
+	coin.ResolveAccounts()
+
+	rules = ReadRules(strings.NewReader(`src 1
+  account 0
+  description 4
+  date 1
+  amount 7
+  amount 3 "-$1" VALUE = \s+([\d\.\-]+)
+  symbol 2
+  quantity 5
+  note 3
+bbb 3
+  account "BBB"
+  activity 1
+  date 0 "$1/$2/$3" (\d\d\d\d)-(\d\d)-(\d\d)
+  amount 7
+  currency 8
+  symbol_ref 3
+  quantity_ref 4
+  description "${activity} ${quantity_ref} ${symbol_ref} in ${currency}"
+  symbol "${symbol_ref}" activity Buy|Sell
+  quantity "${quantity_ref}" activity Buy|Sell
+---
+XXX Assets:Investments:XXX
+  Expenses:Fees Fee|HST
+  Income:Dividends DRIP
+  Assets:Investments:XXX Sold|Bought
+BBB Assets:Investments:BBB
+  Income:Interest Interest
+  Income:Dividends Dividend
+  Assets:Investments:BBB Sell|Buy
+
+`))
+}
+
+const sample1 = `
+Account number,Trade date,Symbol,Description,Operation,Quantity,Price,Net amount
+XXX,2019/09/10,VXF,"blah blah VALUE =      1630.59",DRIP,123.999,,
+XXX,2019/10/10,VXF,whatever,Sold,5148.219,13.150,67689.08
+XXX,2019/10/30,,MGMT FEE,Fee,0.00,,-12.40
+`
+
+// readTransactions reads the file and classifies its entries like the import pipeline.
+func readTransactions(r io.Reader, src *Source, rules *Rules) []*coin.Transaction {
+	imp := &Importer{Rules: rules, Source: src}
+	s, err := imp.Read(r)
+	if err != nil {
+		panic(err)
+	}
+	return s.Classify(importer.IdTag(imp))
+}
+
+func Test_Sample1(t *testing.T) {
+	r := strings.NewReader(sample1)
+	txs := readTransactions(r, rules.sources["src"], rules)
+	for i, exp := range []string{
+		// symbol=VXF, quantity=123.999, amount=1630.59
+		`2019/09/10 DRIP ; blah blah VALUE = 1630.59
+  Assets:Investments:XXX:VXF   123.999 VXF
+  Income:Dividends            -1630.59 USD
+`,
+		`2019/10/10 Sold ; whatever
+  Assets:Investments:XXX:USD   67689.08 USD
+  Assets:Investments:XXX:VXF  -5148.219 VXF
+`,
+		`2019/10/30 Fee ; MGMT FEE
+  Expenses:Fees                12.40 USD
+  Assets:Investments:XXX:USD  -12.40 USD
+`,
+	} {
+		got := txs[i].String()
+		assert.Equal(t, got, exp)
+	}
+}
+
+const sample2 = `
+Transaction Type=All,Product Type=All,Symbol=,From=2021-12-01,To=2022-01-01
+Date,Activity Description,Description,Symbol,Quantity,Price,Currency,Total Amount,Currency
+----------------,---------------,--------------------,-----------,------,--------,-----,--------,------------
+2021-12-16,Buy,ISHARES CORE BAL ETF PORT INDE,XBAL,151.,27.60,CDN,-4181.00,CAD
+2021-12-08,Interest,VANGUARD CDN AGGREGATE BD INDE,VAB,230.,,,12.57,CAD
+2021-12-09,Sell,VANGUARD TOTAL BD MKT ETF,BND,-50,,,4269.56,USD
+2021-12-09,Sell,VANGUARD FTSE CDA ALL CAP INDE,VCN,-55,42.91,CDN,2360.05,CAD
+2021-12-06,Dividend,VANGUARD TOTAL BD MKT ETF,BND,500,,,67.46,USD
+`
+
+func Test_Sample2(t *testing.T) {
+	r := strings.NewReader(sample2)
+	txs := readTransactions(r, rules.sources["bbb"], rules)
+	for i, exp := range []string{
+		`2021/12/16 Buy 151. XBAL in CAD
+  Assets:Investments:BBB:XBAL       151 XBAL
+  Assets:Investments:BBB:CAD   -4181.00 CAD
+`,
+		`2021/12/08 Interest 230. VAB in CAD
+  Assets:Investments:BBB:CAD   12.57 CAD
+  Income:Interest             -12.57 CAD
+`,
+		`2021/12/09 Sell -50 BND in USD
+  Assets:Investments:BBB      4269.56 USD
+  Assets:Investments:BBB:BND      -50 BND
+`,
+		`2021/12/09 Sell -55 VCN in CAD
+  Assets:Investments:BBB:CAD  2360.05 CAD
+  Assets:Investments:BBB:VCN      -55 VCN
+`,
+		`2021/12/06 Dividend 500 BND in USD
+  Assets:Investments:BBB   67.46 USD
+  Income:Dividends        -67.46 USD
+`,
+	} {
+		got := txs[i].String()
+		assert.Equal(t, got, exp)
+	}
+}
+
+func Test_Formats(t *testing.T) {
+	rules := ReadRules(strings.NewReader(`eu 1
+  date_format 02.01.2006
+  number_format 1.234,56
+  account "XXX"
+  date 0
+  description 1
+  amount 2
+---
+XXX Assets:Investments:XXX
+  Expenses:Fees Fee
+`))
+	src := rules.Source("eu")
+	txs := readTransactions(strings.NewReader(`Datum,Text,Betrag
+13.01.2019,Fee,"-1.234,56 €"
+`), src, rules)
+	assert.Equal(t, len(txs), 1)
+	assert.Equal(t, txs[0].String(), `2019/01/13 Fee
+  Expenses:Fees                1234.56 USD
+  Assets:Investments:XXX:USD  -1234.56 USD
+`)
+
+	imp := &Importer{Rules: rules, Source: src}
+	_, err := imp.Read(strings.NewReader(`Datum,Text,Betrag
+13.01.2019,Fee,"1,00"
+32.01.2019,Fee,"1,00"
+`))
+	assert.Equal(t, err.Error(), `line 3, field 0 (date): invalid date "32.01.2019", expected format 02.01.2006`)
+	_, err = imp.Read(strings.NewReader(`Datum,Text,Betrag
+13.01.2019,Fee,"1,000.00"
+`))
+	assert.Equal(t, err.Error(), `line 2, field 2 (amount): invalid number "1,000.00" in format 1.234,56`)
+
+	var b strings.Builder
+	src.Write(&b)
+	assert.True(t, strings.HasPrefix(b.String(), "eu\n  date_format 02.01.2006\n  number_format 1.234,56\n"))
+}
+
+func Test_DebitCredit(t *testing.T) {
+	rules := ReadRules(strings.NewReader(`bank 1
+  account "XXX"
+  date 0
+  description 1
+  debit 2
+  credit 3
+  fee 4
+  fee_account "Expenses:Fees"
+  balance 5
+---
+XXX Assets:Investments:XXX
+  Income:Dividends Dividend
+`))
+	txs := readTransactions(strings.NewReader(`Date,Description,Debit,Credit,Fee,Balance
+2019/01/10,Wire,100.00,,,900.00
+2019/01/11,Dividend,,50.00,2.50,947.50
+2019/01/12,Interest,,1.00,,948.50
+`), rules.Source("bank"), rules)
+	assert.Equal(t, len(txs), 3)
+	for i, exp := range []string{
+		`2019/01/10 Wire
+  Unbalanced                   100.00 USD
+  Assets:Investments:XXX:USD  -100.00 USD = 900.00 USD
+`,
+		`2019/01/11 Dividend
+  Assets:Investments:XXX:USD   47.50 USD = 947.50 USD
+  Income:Dividends            -50.00 USD
+  Expenses:Fees                 2.50 USD
+`,
+		`2019/01/12 Interest
+  Assets:Investments:XXX:USD   1.00 USD = 948.50 USD
+  Unbalanced                  -1.00 USD
+`,
+	} {
+		assert.Equal(t, txs[i].String(), exp)
+	}
+}
+
+func Test_InvalidValues(t *testing.T) {
+	rules := ReadRules(strings.NewReader(`bank 1
+  account "XXX"
+  date 0
+  description 1
+  amount 2
+  currency 3
+  fee 4
+  fee_account "Nope"
+---
+XXX Assets:Investments:XXX
+`))
+	imp := &Importer{Rules: rules, Source: rules.Source("bank")}
+	_, err := imp.Read(strings.NewReader(`Date,Description,Amount,Currency,Fee
+2019/01/10,Wire,100.00,XYZ,
+`))
+	assert.Equal(t, err.Error(), `line 2, field 3 (currency): unknown currency XYZ`)
+	_, err = imp.Read(strings.NewReader(`Date,Description,Amount,Currency,Fee
+2019/01/10,Wire,100.00,USD,1.00
+`))
+	assert.Equal(t, err.Error(), `line 2, fee_account: cannot find account Nope`)
+}
+
+func Test_Groups(t *testing.T) {
+	rules := ReadRules(strings.NewReader(`broker 1
+  exclude 0 ^Total
+  include 1 Buy|Commission|FX
+  account "XXX"
+  date 0
+  ref 2
+  group "${ref}"
+  description 1
+  amount 3
+  currency 4
+  symbol 5
+  quantity 6
+---
+XXX Assets:Investments:XXX
+  Expenses:Fees Commission
+  Assets:Investments:XXX Buy
+`))
+	src := rules.Source("broker")
+	txs := readTransactions(strings.NewReader(`Date,Type,Ref,Amount,Currency,Symbol,Quantity
+2019/10/10,Buy,T1,-1000.00,USD,VXF,100
+2019/10/10,Commission,T1,-9.99,USD,,
+2019/10/10,Dividend,T2,5.00,USD,,
+2019/10/11,Commission,T3,-1.00,USD,,
+2019/10/11,Commission,T3,-2.00,USD,,
+Total,,,-1014.99
+`), src, rules)
+	assert.Equal(t, len(txs), 2)
+	for i, exp := range []string{
+		`2019/10/10 Buy
+  Assets:Investments:XXX:VXF   100.000 VXF
+  Assets:Investments:XXX:USD  -1009.99 USD
+  Expenses:Fees                   9.99 USD
+`,
+		`2019/10/11 Commission
+  Expenses:Fees                3.00 USD
+  Assets:Investments:XXX:USD  -3.00 USD
+`,
+	} {
+		assert.Equal(t, txs[i].String(), exp)
+	}
+
+	var b strings.Builder
+	src.Write(&b)
+	assert.True(t, strings.HasPrefix(b.String(), "broker\n  exclude 0 ^Total\n  include 1 Buy|Commission|FX\n"))
+}
@@ -0,0 +1,88 @@
+package csv
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// NumberFormat describes how the numbers of a source are written.
+type NumberFormat struct {
+	Thousands string // thousands separator, optional
+	Decimal   string // decimal separator
+	sample    string // the format as written
+}
+
+// DefaultNumberFormat is the number format of sources that don't declare one.
+var DefaultNumberFormat = &NumberFormat{Thousands: ",", Decimal: ".", sample: "1,234.56"}
+
+var numberFormatRE = regexp.MustCompile(`^1(\D?)234(\D)56$`)
+
+// ParseNumberFormat parses the number 1234.56 written in the number format,
+// e.g. 1,234.56, 1.234,56, 1 234,56, 1'234.56 or 1234,56.
+func ParseNumberFormat(sample string) (*NumberFormat, error) {
+	match := numberFormatRE.FindStringSubmatch(sample)
+	if match == nil || match[1] == match[2] {
+		return nil, fmt.Errorf("invalid number format %s, should be 1234.56 written in the format, e.g. 1.234,56", sample)
+	}
+	return &NumberFormat{Thousands: match[1], Decimal: match[2], sample: sample}, nil
+}
+
+func (nf *NumberFormat) String() string {
+	return nf.sample
+}
+
+var plainNumberRE = regexp.MustCompile(`^\d+(\.\d+)?$|^\.\d+$`)
+
+// Parse parses a number in the format, ignoring the thousands separators
+// and currency symbols or codes around the number, e.g. $1,234.56 or 1.234,56 EUR.
+// A number in parentheses or with a minus sign before or after it is negative, e.g. ($12.00), -$12.00 or 12.00-.
+func (nf *NumberFormat) Parse(s string) (*big.Rat, error) {
+	v := strings.TrimSpace(s)
+	negative := false
+	if strings.HasPrefix(v, "(") && strings.HasSuffix(v, ")") {
+		negative, v = true, v[1:len(v)-1]
+	}
+	isNumber := func(r rune) bool { return unicode.IsDigit(r) || string(r) == nf.Decimal }
+	start, end := strings.IndexFunc(v, isNumber), strings.LastIndexFunc(v, isNumber)
+	if start < 0 {
+		return nil, fmt.Errorf("invalid number %q", s)
+	}
+	_, size := utf8.DecodeRuneInString(v[end:])
+	end += size
+	for _, r := range v[:start] + v[end:] {
+		if r == '-' {
+			negative = true
+		} else if !(r == '+' || unicode.IsSpace(r) || unicode.IsLetter(r) || unicode.Is(unicode.Sc, r)) {
+			return nil, fmt.Errorf("invalid number %q", s)
+		}
+	}
+	digits := v[start:end]
+	if nf.Thousands == " " {
+		// no-break spaces are common in exported spreadsheets
+		digits = strings.NewReplacer("\u00a0", " ", "\u202f", " ").Replace(digits)
+	}
+	units, fraction, _ := strings.Cut(digits, nf.Decimal)
+	if nf.Thousands != "" && strings.Contains(units, nf.Thousands) {
+		// the thousands separators must separate groups of 3 digits
+		groups := strings.Split(units, nf.Thousands)
+		for i, g := range groups {
+			if len(g) > 3 || i > 0 && len(g) < 3 || g == "" {
+				return nil, fmt.Errorf("invalid number %q in format %s", s, nf)
+			}
+		}
+		units = strings.Join(groups, "")
+	}
+	digits = strings.TrimSuffix(units+"."+fraction, ".")
+	if !plainNumberRE.MatchString(digits) {
+		return nil, fmt.Errorf("invalid number %q in format %s", s, nf)
+	}
+	n, _ := new(big.Rat).SetString(digits)
+	if negative {
+		n.Neg(n)
+	}
+	return n, nil
+}
@@ -0,0 +1,64 @@
+package csv
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/mkobetic/coin/assert"
+)
+
+func Test_NumberFormat(t *testing.T) {
+	de, err := ParseNumberFormat("1.234,56")
+	assert.NoError(t, err)
+	fr, err := ParseNumberFormat("1 234,56")
+	assert.NoError(t, err)
+	for i, fix := range []struct {
+		format *NumberFormat
+		in     string
+		out    string // empty for invalid numbers
+	}{
+		{DefaultNumberFormat, "1234.56", "1234.56"},
+		{DefaultNumberFormat, "-1,234.56", "-1234.56"},
+		{DefaultNumberFormat, "$1,234.56", "1234.56"},
+		{DefaultNumberFormat, "-$12.00", "-12.00"},
+		{DefaultNumberFormat, "($12.00)", "-12.00"},
+		{DefaultNumberFormat, "12.00-", "-12.00"},
+		{DefaultNumberFormat, "CAD 12.5", "12.50"},
+		{DefaultNumberFormat, "151.", "151.00"},
+		{DefaultNumberFormat, ".5", "0.50"},
+		{DefaultNumberFormat, "1.234,56", ""},
+		{DefaultNumberFormat, "12a34", ""},
+		{DefaultNumberFormat, "2019/01/02", ""},
+		{DefaultNumberFormat, "n/a", ""},
+		{de, "1.234,56 €", "1234.56"},
+		{de, "-0,99", "-0.99"},
+		{de, "1,234.56", ""},
+		{fr, "1 234,56", "1234.56"},
+		{fr, "-1 234 567,8", "-1234567.80"},
+	} {
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			n, err := fix.format.Parse(fix.in)
+			if fix.out == "" {
+				assert.True(t, err != nil, fix.in)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, n.FloatString(2), fix.out)
+		})
+	}
+}
+
+func Test_ParseNumberFormat(t *testing.T) {
+	nf, err := ParseNumberFormat("1'234.56")
+	assert.NoError(t, err)
+	assert.Equal(t, nf.Thousands, "'")
+	assert.Equal(t, nf.Decimal, ".")
+	nf, err = ParseNumberFormat("1234,56")
+	assert.NoError(t, err)
+	assert.Equal(t, nf.Thousands, "")
+	assert.Equal(t, nf.Decimal, ",")
+	_, err = ParseNumberFormat("1.234.56")
+	assert.True(t, err != nil)
+	_, err = ParseNumberFormat("#,##0.00")
+	assert.True(t, err != nil)
+}
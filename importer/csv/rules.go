@@ -1,4 +1,4 @@
-package main
+package csv
 
 import (
 	"bufio"
@@ -26,6 +26,9 @@ func (f *Field) Value(row []string, fields map[string]Fields) string {
 	if f.idx == nil {
 		return f.derivedField(row, fields)
 	}
+	if *f.idx >= len(row) {
+		return "" // short (e.g. summary) row
+	}
 	s := row[*f.idx]
 	if f.re == nil {
 		return s
@@ -87,16 +90,51 @@ func (fs Fields) Value(row []string, fields map[string]Fields) string {
 	return ""
 }
 
+// Filter selects the rows of a source with a record field or a value matching a regular expression.
+type Filter struct {
+	exclude bool     // exclude the matching rows, otherwise include only the matching rows
+	idx     *int     // field index, if matching a record field
+	name    string   // value name, if matching a value
+	re      *rex.Exp // the rows matching the rex are included/excluded
+}
+
+func (f *Filter) Matches(row []string, fields map[string]Fields) bool {
+	v := fields[f.name].Value(row, fields)
+	if f.idx != nil {
+		if *f.idx >= len(row) {
+			return false
+		}
+		v = row[*f.idx]
+	}
+	return f.re.Match([]byte(v)) != nil
+}
+
+func (f *Filter) String() string {
+	verb, field := "include", f.name
+	if f.exclude {
+		verb = "exclude"
+	}
+	if f.idx != nil {
+		field = strconv.Itoa(*f.idx)
+	}
+	return fmt.Sprintf("%s %s %s", verb, field, f.re)
+}
+
 type Source struct {
-	name   string
-	skip   int // number of header lines to skip
-	fields map[string]Fields
+	name    string
+	skip    int    // number of header lines to skip
+	date    string // layout of the date value (see time.Parse), defaults to coin.DateFormat
+	numbers *NumberFormat
+	filters []*Filter
+	fields  map[string]Fields
 }
 
 var sourceREX = rex.MustCompile(`^(?P<source>\w+)(\s+(?P<skip>\d+))?\s*$`)
 var derivedFieldRex = rex.MustCompile(`"(?P<code>.*)"(\s+(?P<condField>\w+)\s+(?P<condRex>.+))?`)
 var directFieldRex = rex.MustCompile(`(?P<rowIdx>\d+)(\s+"(?P<out>.+)"\s+(?P<rex>.+))?`)
 var fieldREX = rex.MustCompile(`^\s+(?P<field>\w+)\s+(%s|%s)$`, directFieldRex, derivedFieldRex)
+var formatREX = rex.MustCompile(`^\s+(?P<format>date_format|number_format)\s+(?P<value>\S(.*\S)?)\s*$`)
+var filterREX = rex.MustCompile(`^\s+(?P<filter>include|exclude)\s+((?P<idx>\d+)|(?P<name>\w+))\s+(?P<rex>\S(.*\S)?)\s*$`)
 
 func ScanSource(line []byte, s *bufio.Scanner) *Source {
 	match := sourceREX.Match(line)
@@ -109,6 +147,33 @@ func ScanSource(line []byte, s *bufio.Scanner) *Source {
 	check.If(s.Scan(), "reading next source line: %s\n", s.Err())
 	line = s.Bytes()
 	for {
+		if match = formatREX.Match(line); match != nil {
+			if match["format"] == "date_format" {
+				src.date = match["value"]
+			} else {
+				src.numbers, err = ParseNumberFormat(match["value"])
+				check.NoError(err, "source %s", src.name)
+			}
+			if !s.Scan() {
+				break
+			}
+			line = s.Bytes()
+			continue
+		}
+		if match = filterREX.Match(line); match != nil {
+			filter := &Filter{exclude: match["filter"] == "exclude", name: match["name"], re: rex.MustCompile(match["rex"])}
+			if match["idx"] != "" {
+				idx, err := strconv.Atoi(match["idx"])
+				check.NoError(err, "invalid filter field index: %s\n", match["idx"])
+				filter.idx = &idx
+			}
+			src.filters = append(src.filters, filter)
+			if !s.Scan() {
+				break
+			}
+			line = s.Bytes()
+			continue
+		}
 		match = fieldREX.Match(line)
 		if match == nil {
 			break
@@ -140,6 +205,15 @@ func ScanSource(line []byte, s *bufio.Scanner) *Source {
 
 func (s *Source) Write(w io.Writer) {
 	fmt.Fprintln(w, s.name)
+	if s.date != "" {
+		fmt.Fprintf(w, "  date_format %s\n", s.date)
+	}
+	if s.numbers != nil {
+		fmt.Fprintf(w, "  number_format %s\n", s.numbers)
+	}
+	for _, f := range s.filters {
+		fmt.Fprintf(w, "  %s\n", f)
+	}
 	for _, n := range labels {
 		fs := s.fields[n]
 		for _, f := range fs {
@@ -160,11 +234,73 @@ func (s *Source) Value(field string, row []string) string {
 	return s.fields[field].Value(row, s.fields)
 }
 
+// Selects returns true if the row passes the filters of the source:
+// it matches one of the include filters (if any) and none of the exclude filters.
+func (s *Source) Selects(row []string) bool {
+	included, includes := false, false
+	for _, f := range s.filters {
+		matches := f.Matches(row, s.fields)
+		if f.exclude && matches {
+			return false
+		}
+		if !f.exclude {
+			includes = true
+			included = included || matches
+		}
+	}
+	return included || !includes
+}
+
+// Index returns the index of the record field the value comes from, -1 if the value is derived or empty.
+func (s *Source) Index(field string, row []string) int {
+	for _, f := range s.fields[field] {
+		if f.Value(row, s.fields) != "" {
+			if f.idx == nil {
+				return -1
+			}
+			return *f.idx
+		}
+	}
+	return -1
+}
+
+// DateLayout returns the layout of the date values.
+func (s *Source) DateLayout() string {
+	if s.date == "" {
+		return coin.DateFormat
+	}
+	return s.date
+}
+
+// NumberFormat returns the format of the amount and quantity values.
+func (s *Source) NumberFormat() *NumberFormat {
+	if s.numbers == nil {
+		return DefaultNumberFormat
+	}
+	return s.numbers
+}
+
 type Rules struct {
 	sources map[string]*Source
 	*coin.RuleIndex
 }
 
+// Source returns the named source or nil.
+func (rules *Rules) Source(name string) *Source {
+	if rules == nil {
+		return nil
+	}
+	return rules.sources[name]
+}
+
+// redact returns the lines of the redact section of the account rules, if any.
+func (rules *Rules) redact() []string {
+	if rules == nil || rules.RuleIndex == nil {
+		return nil
+	}
+	return rules.Redact
+}
+
 func (rules *Rules) Write(w io.Writer) {
 	for _, src := range rules.sources {
 		src.Write(w)
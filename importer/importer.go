@@ -0,0 +1,172 @@
+// Package importer implements the import pipeline shared by the statement importers:
+// read the statement entries, classify them using the account rules,
+// merge transfers between the imported accounts, remove duplicates and write the transactions.
+// A new statement format only needs an Importer reading its entries.
+package importer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mkobetic/coin"
+	"github.com/mkobetic/coin/redact"
+)
+
+// Importer reads statement files of a specific format.
+type Importer interface {
+	// Name of the format, e.g. ofx.
+	// Entry ids are tagged with the name suffixed with id, e.g. #ofxid: 20190113162900
+	Name() string
+	// Accepts returns true if the file is in the importer format,
+	// judging by the file name and the first bytes of the file (head).
+	Accepts(fileName string, head []byte) bool
+	// Read reads the statement file.
+	Read(r io.Reader) (*Statement, error)
+}
+
+// Statement is the content of a statement file read by an Importer.
+type Statement struct {
+	Entries      []*Entry            // entries to classify using the account rules
+	Transactions []*coin.Transaction // transactions converted by the importer (e.g. security trades)
+	Prices       []*coin.Price
+	// Redactor masks sensitive information in the descriptions and notes,
+	// it is configured by the redact section of the rules file, optional
+	Redactor *redact.Redactor
+	// SubAccounts selects the first sub-account with the matching commodity (children first)
+	// for the postings, otherwise sub-accounts are used only if the account commodity doesn't match.
+	SubAccounts bool
+}
+
+// Entry is a statement line converted into a transaction.
+// The counter account of the transaction is picked by the rules of the statement account
+// (a rule can also split the amount across several accounts),
+// if there is no matching rule it is the Unbalanced account. Entries matching a rule without an account are dropped.
+// Without Quantity, the Amount is posted to the statement account.
+// With Quantity, the transaction is a conversion, the Quantity is posted to the statement account
+// and the Amount to the counter account, the sign of the Quantity is set opposite to the Amount if necessary.
+type Entry struct {
+	Rules       *coin.AccountRules // rules of the statement account
+	Id          string             // unique id of the entry for the account (e.g. OFX FITID), optional
+	Posted      time.Time
+	Description string
+	Notes       []string
+	Amount      *big.Rat
+	Currency    *coin.Commodity // commodity of the Amount, defaults to the commodity of the account it is posted to
+	Balance     *big.Rat        // balance of the statement account after the entry, optional
+	Fee         *big.Rat        // fee charged to the statement account in addition to the Amount, optional
+	FeeAccount  *coin.Account   // account of the Fee, defaults to Unbalanced
+	Quantity    *big.Rat        // optional
+	Commodity   *coin.Commodity // commodity of the Quantity
+	// Group combines the transactions of consecutive entries with the same Group into one transaction, optional
+	Group string
+}
+
+// IdTag returns the tag of the entry ids of the importer.
+func IdTag(imp Importer) string {
+	return imp.Name() + "id"
+}
+
+// SetId tags the posting of the account with the entry id.
+func SetId(t *coin.Transaction, account *coin.Account, tag, id string) {
+	if t == nil || id == "" {
+		return
+	}
+	for _, p := range t.Postings {
+		if p.Account == account {
+			p.Notes = append(p.Notes, "#"+tag+": "+id)
+			p.Tags = coin.ParseTags(p.Notes...)
+			return
+		}
+	}
+}
+
+// Select returns the first importer accepting the file.
+func Select(importers []Importer, fileName string, head []byte) Importer {
+	for _, imp := range importers {
+		if imp.Accepts(fileName, head) {
+			return imp
+		}
+	}
+	return nil
+}
+
+// HeadSize is the number of bytes of the file passed to Importer.Accepts.
+const HeadSize = 1024
+
+// Open opens the file and selects the importer for it.
+// The returned reader reads the whole file.
+func Open(importers []Importer, fileName string) (Importer, io.Reader, io.Closer, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	r := bufio.NewReaderSize(file, HeadSize)
+	head, err := r.Peek(HeadSize)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		file.Close()
+		return nil, nil, nil, err
+	}
+	imp := Select(importers, fileName, head)
+	if imp == nil {
+		file.Close()
+		return nil, nil, nil, fmt.Errorf("unknown file type %s", fileName)
+	}
+	return imp, r, file, nil
+}
+
+// HasExtension returns true if the file name has one of the extensions (case insensitive).
+func HasExtension(fileName string, extensions ...string) bool {
+	ext := strings.ToLower(filepath.Ext(fileName))
+	for _, e := range extensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadRules loads the account rules from the first existing rules file in the $COINDB directory,
+// e.g. LoadRules("qif.rules", "ofx.rules"), merged after the rules of the loaded coin files (see coin.ImportRules).
+// Returns only the coin file rules if none of the files exist.
+func LoadRules(names ...string) (*coin.RuleIndex, error) {
+	for _, name := range names {
+		fn := filepath.Join(coin.DB, name)
+		if _, err := os.Stat(fn); os.IsNotExist(err) {
+			continue
+		}
+		file, err := os.Open(fn)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		rules, err := coin.ReadRules(file)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", fn, err)
+		}
+		return coin.ImportRules.Merge(rules), nil
+	}
+	return coin.ImportRules.Merge(nil), nil
+}
+
+// FindAccountForCommodity returns the first account in the root account tree (children first)
+// with the commodity, or Unbalanced if there isn't one.
+func FindAccountForCommodity(c *coin.Commodity, root *coin.Account) *coin.Account {
+	account := coin.Unbalanced
+	root.FirstWithChildrenDo(func(a *coin.Account) {
+		if a.Commodity == c && account == coin.Unbalanced {
+			account = a
+		}
+	})
+	return account
+}
+
+// Trim removes the leading/trailing whitespace and collapses any inner whitespace into single spaces.
+func Trim(in string) string {
+	return strings.Join(strings.Fields(in), " ")
+}
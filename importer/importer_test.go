@@ -0,0 +1,55 @@
+package importer
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mkobetic/coin/assert"
+)
+
+func Test_Trim(t *testing.T) {
+	for _, tc := range []struct {
+		in, out string
+	}{
+		{"   a  bb 	ddd   ", "a bb ddd"},
+		{"xx[ 7   ]      !yyy", "xx[ 7 ] !yyy"},
+	} {
+		assert.Equal(t, Trim(tc.in), tc.out)
+	}
+}
+
+type testImporter struct {
+	name string
+	ext  string
+	head string
+}
+
+func (imp *testImporter) Name() string { return imp.name }
+func (imp *testImporter) Accepts(fileName string, head []byte) bool {
+	return HasExtension(fileName, imp.ext) || strings.HasPrefix(string(head), imp.head)
+}
+func (imp *testImporter) Read(r io.Reader) (*Statement, error) { return &Statement{}, nil }
+
+func Test_Select(t *testing.T) {
+	importers := []Importer{
+		&testImporter{name: "ofx", ext: ".qfx", head: "OFXHEADER"},
+		&testImporter{name: "csv", ext: ".csv", head: "\x00"},
+	}
+	assert.Equal(t, Select(importers, "Statement.QFX", nil).Name(), "ofx")
+	assert.Equal(t, Select(importers, "statement.txt", []byte("OFXHEADER:100")).Name(), "ofx")
+	assert.Equal(t, Select(importers, "statement.csv", []byte("Date,Amount")).Name(), "csv")
+	assert.True(t, Select(importers, "statement.txt", []byte("Date,Amount")) == nil)
+
+	fn := filepath.Join(t.TempDir(), "statement.txt")
+	assert.NoError(t, os.WriteFile(fn, []byte("OFXHEADER:100\nDATA:OFXSGML\n"), 0644))
+	imp, r, file, err := Open(importers, fn)
+	assert.NoError(t, err)
+	defer file.Close()
+	assert.Equal(t, imp.Name(), "ofx")
+	content, err := io.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, string(content), "OFXHEADER:100\nDATA:OFXSGML\n")
+}
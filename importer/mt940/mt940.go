@@ -0,0 +1,282 @@
+// Package mt940 imports SWIFT MT940 (customer statement message) files.
+package mt940
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mkobetic/coin"
+	"github.com/mkobetic/coin/importer"
+	"github.com/mkobetic/coin/redact"
+)
+
+// Importer reads MT940 files, the accounts are matched by the statement account identification (:25:),
+// see coin.RuleIndex.AccountRulesFor.
+type Importer struct {
+	Rules *coin.RuleIndex
+}
+
+func (*Importer) Name() string { return "mt940" }
+
+func (*Importer) Accepts(fileName string, head []byte) bool {
+	return importer.HasExtension(fileName, ".sta", ".mt940", ".940") ||
+		bytes.Contains(head, []byte(":20:")) && bytes.Contains(head, []byte(":25:"))
+}
+
+// Read converts the statement lines into entries,
+// the closing balance of the statement is attached to its last entry.
+// The entries are identified by the bank reference of the statement line, if any.
+func (imp *Importer) Read(r io.Reader) (*importer.Statement, error) {
+	statements, err := readStatements(r)
+	if err != nil {
+		return nil, err
+	}
+	red, err := redact.New(imp.Rules.Redact...)
+	if err != nil {
+		return nil, err
+	}
+	s := &importer.Statement{Redactor: red}
+	for _, st := range statements {
+		rules := imp.Rules.AccountRulesFor(st.account)
+		currency := coin.Commodities[st.currency]
+		var last *importer.Entry
+		for _, e := range st.entries {
+			entry := &importer.Entry{
+				Rules:       rules,
+				Id:          e.bankReference,
+				Posted:      e.date,
+				Description: e.description(),
+				Amount:      e.amount,
+				Currency:    currency,
+			}
+			if last == nil || !e.date.Before(last.Posted) {
+				last = entry
+			}
+			s.Entries = append(s.Entries, entry)
+		}
+		if st.closing != nil && last != nil {
+			last.Balance = st.closing.amount
+		}
+	}
+	return s, nil
+}
+
+// SWIFT MT940 (customer statement message) is a sequence of :tag:value fields,
+// values can span multiple lines. Only the fields used for the import are parsed:
+//
+//	:20: statement reference, starts a new statement
+//	:25: account identification (IBAN or bank code/account number)
+//	:60F: opening balance
+//	:61: statement line (entry)
+//	:86: information to account owner for the preceding statement line
+//	:62F: closing balance
+
+type statement struct {
+	reference string
+	account   string
+	currency  string
+	entries   []*entry
+	closing   *balance
+}
+
+type entry struct {
+	date          time.Time
+	amount        *big.Rat
+	reference     string
+	bankReference string
+	details       string
+}
+
+type balance struct {
+	date     time.Time
+	currency string
+	amount   *big.Rat
+}
+
+var (
+	fieldREX   = regexp.MustCompile(`^:(\d\d[A-Z]?):(.*)$`)
+	balanceREX = regexp.MustCompile(`^([CD])(\d{6})([A-Z]{3})(\d+,\d*)$`)
+	lineREX    = regexp.MustCompile(`(?s)^(\d{6})(\d{4})?(R?[CD])[A-Z]?(\d+,\d*)[NFS][A-Z0-9]{3}([^/\n]*)(?://([^\n]*))?(?:\n(.*))?$`)
+)
+
+// readStatements parses MT940 statements, SWIFT block headers are skipped.
+func readStatements(r io.Reader) (statements []*statement, err error) {
+	type field struct{ tag, value string }
+	var fields []*field
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if i := strings.Index(line, "{4:"); i >= 0 {
+			line = line[i+3:]
+		}
+		switch {
+		case line == "" || line == "-" || line == "-}" || strings.HasPrefix(line, "{"):
+			continue
+		case fieldREX.MatchString(line):
+			match := fieldREX.FindStringSubmatch(line)
+			fields = append(fields, &field{match[1], match[2]})
+		case len(fields) > 0:
+			fields[len(fields)-1].value += "\n" + line
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var s *statement
+	for _, f := range fields {
+		if s == nil && f.tag != "20" {
+			return nil, fmt.Errorf("missing statement reference (:20:) before :%s:", f.tag)
+		}
+		switch f.tag {
+		case "20":
+			s = &statement{reference: strings.TrimSpace(f.value)}
+			statements = append(statements, s)
+		case "25":
+			s.account = strings.ReplaceAll(strings.TrimSpace(f.value), " ", "")
+		case "60F", "60M":
+			b, err := parseBalance(f.value)
+			if err != nil {
+				return nil, fmt.Errorf("statement %s: %w", s.reference, err)
+			}
+			s.currency = b.currency
+		case "61":
+			e, err := parseEntry(f.value)
+			if err != nil {
+				return nil, fmt.Errorf("statement %s: %w", s.reference, err)
+			}
+			s.entries = append(s.entries, e)
+		case "86":
+			if len(s.entries) > 0 {
+				s.entries[len(s.entries)-1].details = f.value
+			}
+		case "62F", "62M":
+			b, err := parseBalance(f.value)
+			if err != nil {
+				return nil, fmt.Errorf("statement %s: %w", s.reference, err)
+			}
+			s.closing = b
+			s.currency = b.currency
+		}
+	}
+	return statements, nil
+}
+
+func parseBalance(s string) (*balance, error) {
+	match := balanceREX.FindStringSubmatch(strings.TrimSpace(s))
+	if match == nil {
+		return nil, fmt.Errorf("invalid balance: %s", s)
+	}
+	date, err := time.Parse("060102", match[2])
+	if err != nil {
+		return nil, err
+	}
+	amount, err := parseAmount(match[4])
+	if err != nil {
+		return nil, err
+	}
+	if match[1] == "D" {
+		amount.Neg(amount)
+	}
+	return &balance{date: date.Add(12 * time.Hour), currency: match[3], amount: amount}, nil
+}
+
+// parseEntry parses the :61: statement line,
+// the entry date is used if present, otherwise the value date.
+func parseEntry(s string) (*entry, error) {
+	match := lineREX.FindStringSubmatch(strings.TrimSpace(s))
+	if match == nil {
+		return nil, fmt.Errorf("invalid statement line: %s", s)
+	}
+	date, err := time.Parse("060102", match[1])
+	if err != nil {
+		return nil, err
+	}
+	if md := match[2]; md != "" {
+		m, _ := strconv.Atoi(md[:2])
+		d, _ := strconv.Atoi(md[2:])
+		y := date.Year()
+		switch {
+		case m == 12 && date.Month() == time.January:
+			y--
+		case m == 1 && date.Month() == time.December:
+			y++
+		}
+		date = time.Date(y, time.Month(m), d, 0, 0, 0, 0, time.UTC)
+	}
+	amount, err := parseAmount(match[4])
+	if err != nil {
+		return nil, err
+	}
+	if mark := match[3]; mark == "D" || mark == "RC" {
+		amount.Neg(amount)
+	}
+	e := &entry{date: date.Add(12 * time.Hour), amount: amount}
+	if ref := strings.TrimSpace(match[5]); ref != "NONREF" {
+		e.reference = ref
+	}
+	if ref := strings.TrimSpace(match[6]); ref != "NONREF" {
+		e.bankReference = ref
+	}
+	if supplementary := strings.TrimSpace(match[7]); supplementary != "" {
+		e.reference = importer.Trim(e.reference + " " + supplementary)
+	}
+	return e, nil
+}
+
+// parseAmount parses MT940 amounts, which use comma as the decimal separator.
+func parseAmount(s string) (*big.Rat, error) {
+	v, ok := new(big.Rat).SetString(strings.TrimSuffix(strings.Replace(s, ",", ".", 1), "."))
+	if !ok {
+		return nil, fmt.Errorf("invalid amount: %s", s)
+	}
+	return v, nil
+}
+
+var subfieldREX = regexp.MustCompile(`\?(\d\d)`)
+
+// description composes the transaction description from the :86: details.
+// Structured details (?20 subfields used by German banks) yield the counterparty name
+// followed by the remittance information, or the posting text if those are missing.
+// Unstructured details are used as is, the entry reference is the last resort.
+func (e *entry) description() string {
+	details := e.details
+	if !subfieldREX.MatchString(details) {
+		if d := importer.Trim(details); d != "" {
+			return d
+		}
+		return e.reference
+	}
+	details = strings.ReplaceAll(details, "\n", "")
+	var name, remittance, text []string
+	idxs := subfieldREX.FindAllStringSubmatchIndex(details, -1)
+	for i, idx := range idxs {
+		end := len(details)
+		if i+1 < len(idxs) {
+			end = idxs[i+1][0]
+		}
+		value := details[idx[1]:end]
+		switch code, _ := strconv.Atoi(details[idx[2]:idx[3]]); {
+		case code == 0:
+			text = append(text, value)
+		case code >= 20 && code <= 29, code >= 60 && code <= 63:
+			remittance = append(remittance, value)
+		case code == 32, code == 33:
+			name = append(name, value)
+		}
+	}
+	if d := importer.Trim(strings.Join(name, "") + " " + strings.Join(remittance, " ")); d != "" {
+		return d
+	}
+	if d := importer.Trim(strings.Join(text, " ")); d != "" {
+		return d
+	}
+	return e.reference
+}
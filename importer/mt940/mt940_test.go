@@ -0,0 +1,101 @@
+package mt940
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/mkobetic/coin"
+	"github.com/mkobetic/coin/assert"
+	"github.com/mkobetic/coin/importer"
+)
+
+func init() {
+	r := strings.NewReader(`
+commodity EUR
+  format 1.00 EUR
+  default
+
+account Assets:Bank:Giro
+  commodity EUR
+  import-id DE89370400440532013000
+account Expenses:Groceries
+  commodity EUR
+account Income:Salary
+  commodity EUR
+`)
+	coin.Load(r, "")
+	coin.ResolveAccounts()
+}
+
+const rulesSample = `groceries
+  Expenses:Groceries  REWE
+`
+
+const sample = `{1:F01COBADEFFAXXX0000000000}{2:O9400000200101COBADEFFAXXX00000000002001010000N}{4:
+:20:STARTUMSE
+:25:DE89370400440532013000
+:28C:00001/001
+:60F:C200101EUR100,00
+:61:2001280128C2000,00NTRFNONREF//8327000090031789
+:86:166?00GUTSCHRIFT?20Salary January?32ACME GmbH
+:61:2001060105D45,67NDDTREF-123
+:86:105?00LASTSCHRIFT?20Einkauf 1234
+?21Filiale 5?32REWE Markt
+:61:2001100110D5,NCHGNONREF
+:86:Account fee
+:62F:C200128EUR2049,33
+-}
+`
+
+// readTransactions reads the statement and returns its transactions sorted by date.
+func readTransactions(t *testing.T, imp *Importer, sample string) coin.TransactionsByTime {
+	s, err := imp.Read(strings.NewReader(sample))
+	assert.NoError(t, err)
+	txs := coin.TransactionsByTime(s.Classify(importer.IdTag(imp)))
+	sort.Stable(txs)
+	return txs
+}
+
+func Test_Read(t *testing.T) {
+	imp := &Importer{Rules: &coin.RuleIndex{}}
+	assert.True(t, imp.Accepts("statement.txt", []byte(sample)))
+	txs := readTransactions(t, imp, sample)
+	assert.Equal(t, len(txs), 3)
+	assert.Equal(t, txs[0].Postings[0].Account.FullName, "Unbalanced")
+
+	rules, err := coin.ReadRules(strings.NewReader(rulesSample + `DE89370400440532013000 Assets:Bank:Giro
+  @groceries
+  Income:Salary  ACME
+`))
+	assert.NoError(t, err)
+	txs = readTransactions(t, &Importer{Rules: rules}, sample)
+	var b bytes.Buffer
+	for _, t := range txs {
+		t.Write(&b, false)
+		b.WriteString("\n")
+	}
+	assert.Equal(t, b.String(), `2020/01/05 REWE Markt Einkauf 1234 Filiale 5
+  Expenses:Groceries   45.67 EUR
+  Assets:Bank:Giro    -45.67 EUR
+
+2020/01/10 Account fee
+  Unbalanced         5.00 EUR
+  Assets:Bank:Giro  -5.00 EUR
+
+2020/01/28 ACME GmbH Salary January
+  Assets:Bank:Giro   2000.00 EUR = 2049.33 EUR ; #mt940id: 8327000090031789
+  Income:Salary     -2000.00 EUR
+
+`)
+}
+
+func Test_ParseEntry(t *testing.T) {
+	e, err := parseEntry("1912310101RD12,5NTRFREF//BANKREF\nSUPPL")
+	assert.NoError(t, err)
+	assert.Equal(t, e.date.Format(coin.DateFormat), "2020/01/01")
+	assert.Equal(t, e.amount.FloatString(2), "12.50")
+	assert.Equal(t, e.reference, "REF SUPPL")
+	assert.Equal(t, e.bankReference, "BANKREF")
+}
@@ -1,4 +1,4 @@
-package main
+package ofx
 
 /*
 	Bank of Montreal (BMO) produces an invalid ofx file when downloading
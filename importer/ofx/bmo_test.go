@@ -1,4 +1,4 @@
-package main
+package ofx
 
 import (
 	"io/ioutil"
@@ -0,0 +1,270 @@
+package ofx
+
+import (
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/aclindsa/ofxgo"
+	"github.com/mkobetic/coin"
+	"github.com/mkobetic/coin/importer"
+)
+
+// securities maps security ids (e.g. CUSIP) to the security info from the SECLIST
+type securities map[string]ofxgo.SecInfo
+
+func readSecurities(responses *ofxgo.Response) securities {
+	secs := securities{}
+	for _, msg := range responses.SecList {
+		list, ok := msg.(*ofxgo.SecurityList)
+		if !ok {
+			continue
+		}
+		for _, s := range list.Securities {
+			var info ofxgo.SecInfo
+			switch s := s.(type) {
+			case ofxgo.DebtInfo:
+				info = s.SecInfo
+			case ofxgo.MFInfo:
+				info = s.SecInfo
+			case ofxgo.OptInfo:
+				info = s.SecInfo
+			case ofxgo.OtherInfo:
+				info = s.SecInfo
+			case ofxgo.StockInfo:
+				info = s.SecInfo
+			}
+			secs[info.SecID.UniqueID.String()] = info
+		}
+	}
+	return secs
+}
+
+// ticker returns the ticker of the security or its id if the ticker is not known.
+func (secs securities) ticker(id ofxgo.SecurityID) string {
+	info := secs[id.UniqueID.String()]
+	if ticker := info.Ticker.String(); ticker != "" {
+		return ticker
+	}
+	return id.UniqueID.String()
+}
+
+// commodity finds the commodity for the security by its ticker (commodity id or symbol) or by its name.
+func (secs securities) commodity(id ofxgo.SecurityID) (*coin.Commodity, error) {
+	info := secs[id.UniqueID.String()]
+	ticker := secs.ticker(id)
+	if c := coin.Commodities[ticker]; c != nil {
+		return c, nil
+	}
+	if c := coin.CommoditiesBySymbol[ticker]; c != nil {
+		return c, nil
+	}
+	var found *coin.Commodity
+	if name := info.SecName.String(); name != "" {
+		coin.CommoditiesDo(func(c *coin.Commodity) {
+			if found == nil && c.Name == name {
+				found = c
+			}
+		})
+	}
+	if found == nil {
+		return nil, fmt.Errorf("unknown security %s %s (%s)", ticker, info.SecName, id.UniqueID)
+	}
+	return found, nil
+}
+
+// readInvestments converts investment statement transactions into coin transactions
+// and position unit prices into prices.
+// Security purchases and sales are posted to the sub-account of the statement account
+// holding the security commodity, cash is posted to the statement account.
+func readInvestments(s *importer.Statement, resp *ofxgo.InvStatementResponse, secs securities, rules *coin.RuleIndex) error {
+	ars := rules.AccountRulesFor(resp.InvAcctFrom.AcctID.String())
+	cash := ars.Account
+	if c := coin.Commodities[resp.CurDef.String()]; c != nil && c != cash.Commodity {
+		cash = importer.FindAccountForCommodity(c, cash)
+	}
+	if list := resp.InvTranList; list != nil {
+		cashRules := &coin.AccountRules{Account: cash, Rules: ars.Rules}
+		for _, bt := range list.BankTransactions {
+			for _, t := range bt.Transactions {
+				s.Entries = append(s.Entries, newEntry(cashRules,
+					t,
+					importer.Trim(t.Name.String()+t.Memo.String()),
+					nil,
+				))
+			}
+		}
+		for _, it := range list.InvTransactions {
+			nt, err := newInvTransaction(ars, cash, secs, it)
+			if err != nil {
+				return err
+			}
+			if nt != nil {
+				s.Transactions = append(s.Transactions, nt)
+			}
+		}
+	}
+	for _, p := range resp.InvPosList {
+		var pos ofxgo.InvPosition
+		switch p := p.(type) {
+		case ofxgo.DebtPosition:
+			pos = p.InvPos
+		case ofxgo.MFPosition:
+			pos = p.InvPos
+		case ofxgo.OptPosition:
+			pos = p.InvPos
+		case ofxgo.OtherPosition:
+			pos = p.InvPos
+		case ofxgo.StockPosition:
+			pos = p.InvPos
+		}
+		c, err := secs.commodity(pos.SecID)
+		if err != nil {
+			return err
+		}
+		currency := cash.Commodity
+		if pos.Currency != nil {
+			if cur := coin.Commodities[pos.Currency.CurSym.String()]; cur != nil {
+				currency = cur
+			}
+		}
+		y, m, d := pos.DtPriceAsOf.Date()
+		s.Prices = append(s.Prices, &coin.Price{
+			Commodity: c,
+			Currency:  currency,
+			Value:     amountOf(pos.UnitPrice, currency, false),
+			Time:      time.Date(y, m, d, 12, 0, 0, 0, time.UTC),
+		})
+	}
+	return nil
+}
+
+func newInvTransaction(ars *coin.AccountRules, cash *coin.Account, secs securities, it ofxgo.InvTransaction) (*coin.Transaction, error) {
+	var buy *ofxgo.InvBuy
+	var sell *ofxgo.InvSell
+	switch it := it.(type) {
+	case ofxgo.BuyDebt:
+		buy = &it.InvBuy
+	case ofxgo.BuyMF:
+		buy = &it.InvBuy
+	case ofxgo.BuyOpt:
+		buy = &it.InvBuy
+	case ofxgo.BuyOther:
+		buy = &it.InvBuy
+	case ofxgo.BuyStock:
+		buy = &it.InvBuy
+	case ofxgo.SellDebt:
+		sell = &it.InvSell
+	case ofxgo.SellMF:
+		sell = &it.InvSell
+	case ofxgo.SellOpt:
+		sell = &it.InvSell
+	case ofxgo.SellOther:
+		sell = &it.InvSell
+	case ofxgo.SellStock:
+		sell = &it.InvSell
+	case ofxgo.Income:
+		return newIncome(ars, cash, it.InvTran, it.IncomeType.String()+" "+secs.ticker(it.SecID), it.Total, false), nil
+	case ofxgo.RetOfCap:
+		return newIncome(ars, cash, it.InvTran, "RETOFCAP "+secs.ticker(it.SecID), it.Total, false), nil
+	case ofxgo.InvExpense:
+		return newIncome(ars, cash, it.InvTran, "EXPENSE "+secs.ticker(it.SecID), it.Total, true), nil
+	case ofxgo.MarginInterest:
+		return newIncome(ars, cash, it.InvTran, "MARGININTEREST", it.Total, true), nil
+	case ofxgo.Reinvest:
+		c, err := secs.commodity(it.SecID)
+		if err != nil {
+			return nil, err
+		}
+		t, to := newInvTransactionFor(ars, it.InvTran, "REINVEST "+it.IncomeType.String()+" "+secs.ticker(it.SecID))
+		if t == nil {
+			return nil, nil
+		}
+		holding := importer.FindAccountForCommodity(c, ars.Account)
+		t.PostConversion(holding, amountOf(it.Units, c, false), nil, to, amountOf(it.Total, cash.Commodity, true), nil)
+		importer.SetId(t, holding, idTag, it.InvTran.FiTID.String())
+		return t, nil
+	case ofxgo.Transfer:
+		c, err := secs.commodity(it.SecID)
+		if err != nil {
+			return nil, err
+		}
+		t, to := newInvTransactionFor(ars, it.InvTran, "TRANSFER "+it.TferAction.String()+" "+secs.ticker(it.SecID))
+		if t == nil {
+			return nil, nil
+		}
+		holding := importer.FindAccountForCommodity(c, ars.Account)
+		t.Post(holding, to, amountOf(it.Units, c, it.TferAction == ofxgo.TferActionOut), nil)
+		importer.SetId(t, holding, idTag, it.InvTran.FiTID.String())
+		return t, nil
+	default:
+		fmt.Fprintf(os.Stderr, "SKIPPING UNSUPPORTED %s TRANSACTION\n", it.TransactionType())
+		return nil, nil
+	}
+
+	var tran ofxgo.InvTran
+	var secId ofxgo.SecurityID
+	var units, total ofxgo.Amount
+	var kind string
+	if buy != nil {
+		tran, secId, units, total, kind = buy.InvTran, buy.SecID, buy.Units, buy.Total, "BUY"
+	} else {
+		tran, secId, units, total, kind = sell.InvTran, sell.SecID, sell.Units, sell.Total, "SELL"
+	}
+	c, err := secs.commodity(secId)
+	if err != nil {
+		return nil, err
+	}
+	t := &coin.Transaction{
+		Posted:      tran.DtTrade.Time,
+		Description: importer.Trim(kind + " " + secs.ticker(secId) + " " + tran.Memo.String()),
+	}
+	holding := importer.FindAccountForCommodity(c, ars.Account)
+	t.PostConversion(holding, amountOf(units, c, sell != nil), nil, cash, amountOf(total, cash.Commodity, buy != nil), nil)
+	importer.SetId(t, holding, idTag, tran.FiTID.String())
+	return t, nil
+}
+
+// newIncome creates a cash transaction (dividend, interest, expense, etc), the counter account is picked by the rules.
+func newIncome(ars *coin.AccountRules, cash *coin.Account, tran ofxgo.InvTran, description string, total ofxgo.Amount, expense bool) *coin.Transaction {
+	t, to := newInvTransactionFor(ars, tran, description)
+	if t == nil {
+		return nil
+	}
+	t.Post(cash, to, amountOf(total, cash.Commodity, expense), nil)
+	importer.SetId(t, cash, idTag, tran.FiTID.String())
+	return t
+}
+
+// newInvTransactionFor creates the transaction and picks the counter account using the rules.
+// Returns nil if the transaction should be dropped.
+func newInvTransactionFor(ars *coin.AccountRules, tran ofxgo.InvTran, description string) (*coin.Transaction, *coin.Account) {
+	payee := importer.Trim(description + " " + tran.Memo.String())
+	to := coin.Unbalanced
+	var notes []string
+	if rule := ars.RuleForInput(&coin.RuleInput{Payee: payee, Posted: tran.DtTrade.Time}); rule != nil {
+		if rule.Account == nil {
+			// drop the transaction
+			return nil, nil
+		}
+		to = rule.Account
+		notes = rule.Notes
+	}
+	return &coin.Transaction{
+		Posted:      tran.DtTrade.Time,
+		Description: payee,
+		Notes:       notes,
+	}, to
+}
+
+// amountOf converts the absolute value of a to commodity c, negated if negative is set.
+// OFX servers are not consistent with the signs of units and totals,
+// so the sign is determined by the type of the transaction.
+func amountOf(a ofxgo.Amount, c *coin.Commodity, negative bool) *coin.Amount {
+	v := new(big.Rat).Abs(&a.Rat)
+	if negative {
+		v.Neg(v)
+	}
+	return coin.NewAmountFrac(v.Num(), v.Denom(), c)
+}
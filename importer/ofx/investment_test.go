@@ -0,0 +1,215 @@
+package ofx
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mkobetic/coin"
+	"github.com/mkobetic/coin/assert"
+	"github.com/mkobetic/coin/importer"
+)
+
+var invRules = `
+777888999 Assets:Broker
+  Income:Dividends  DIV
+`
+
+var invSample = `
+OFXHEADER:100
+DATA:OFXSGML
+VERSION:102
+SECURITY:NONE
+ENCODING:USASCII
+CHARSET:1252
+COMPRESSION:NONE
+OLDFILEUID:NONE
+NEWFILEUID:NONE
+
+<OFX>
+<SIGNONMSGSRSV1>
+<SONRS>
+<STATUS>
+<CODE>0
+<SEVERITY>INFO
+</STATUS>
+<DTSERVER>20200131120000
+<LANGUAGE>ENG
+</SONRS>
+</SIGNONMSGSRSV1>
+<INVSTMTMSGSRSV1>
+<INVSTMTTRNRS>
+<TRNUID>1
+<STATUS>
+<CODE>0
+<SEVERITY>INFO
+</STATUS>
+<INVSTMTRS>
+<DTASOF>20200131120000
+<CURDEF>USD
+<INVACCTFROM>
+<BROKERID>broker.example.com
+<ACCTID>777888999
+</INVACCTFROM>
+<INVTRANLIST>
+<DTSTART>20200101
+<DTEND>20200131
+<BUYSTOCK>
+<INVBUY>
+<INVTRAN>
+<FITID>1001
+<DTTRADE>20200110120000
+<MEMO>BOUGHT
+</INVTRAN>
+<SECID>
+<UNIQUEID>922908769
+<UNIQUEIDTYPE>CUSIP
+</SECID>
+<UNITS>10
+<UNITPRICE>150.00
+<COMMISSION>9.99
+<TOTAL>-1509.99
+<SUBACCTSEC>CASH
+<SUBACCTFUND>CASH
+</INVBUY>
+<BUYTYPE>BUY
+</BUYSTOCK>
+<SELLSTOCK>
+<INVSELL>
+<INVTRAN>
+<FITID>1002
+<DTTRADE>20200120120000
+</INVTRAN>
+<SECID>
+<UNIQUEID>922908769
+<UNIQUEIDTYPE>CUSIP
+</SECID>
+<UNITS>-2.5
+<UNITPRICE>160.00
+<TOTAL>400.00
+<SUBACCTSEC>CASH
+<SUBACCTFUND>CASH
+</INVSELL>
+<SELLTYPE>SELL
+</SELLSTOCK>
+<INCOME>
+<INVTRAN>
+<FITID>1003
+<DTTRADE>20200125120000
+</INVTRAN>
+<SECID>
+<UNIQUEID>922908769
+<UNIQUEIDTYPE>CUSIP
+</SECID>
+<INCOMETYPE>DIV
+<TOTAL>12.34
+<SUBACCTSEC>CASH
+<SUBACCTFUND>CASH
+</INCOME>
+<REINVEST>
+<INVTRAN>
+<FITID>1004
+<DTTRADE>20200126120000
+</INVTRAN>
+<SECID>
+<UNIQUEID>922908769
+<UNIQUEIDTYPE>CUSIP
+</SECID>
+<INCOMETYPE>DIV
+<TOTAL>-15.50
+<SUBACCTSEC>CASH
+<UNITS>0.1
+<UNITPRICE>155.00
+</REINVEST>
+<INVBANKTRAN>
+<STMTTRN>
+<TRNTYPE>CREDIT
+<DTPOSTED>20200105120000
+<TRNAMT>2000.00
+<FITID>1005
+<NAME>DEPOSIT
+</STMTTRN>
+<SUBACCTFUND>CASH
+</INVBANKTRAN>
+</INVTRANLIST>
+<INVPOSLIST>
+<POSSTOCK>
+<INVPOS>
+<SECID>
+<UNIQUEID>922908769
+<UNIQUEIDTYPE>CUSIP
+</SECID>
+<HELDINACCT>CASH
+<POSTYPE>LONG
+<UNITS>7.6
+<UNITPRICE>162.50
+<MKTVAL>1235.00
+<DTPRICEASOF>20200131160000
+</INVPOS>
+</POSSTOCK>
+</INVPOSLIST>
+</INVSTMTRS>
+</INVSTMTTRNRS>
+</INVSTMTMSGSRSV1>
+<SECLISTMSGSRSV1>
+<SECLIST>
+<STOCKINFO>
+<SECINFO>
+<SECID>
+<UNIQUEID>922908769
+<UNIQUEIDTYPE>CUSIP
+</SECID>
+<SECNAME>Vanguard Total Stock Market ETF
+<TICKER>VTI
+</SECINFO>
+</STOCKINFO>
+</SECLIST>
+</SECLISTMSGSRSV1>
+</OFX>
+`
+
+func Test_ReadInvestments(t *testing.T) {
+	rules, err := coin.ReadRules(strings.NewReader(invRules))
+	assert.NoError(t, err)
+	txs, prices, err := readTransactions(strings.NewReader(strings.TrimSpace(invSample)), rules)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, len(txs), 5)
+	for i, tx := range []string{
+		`2020/01/05 DEPOSIT
+  Assets:Broker   2000.00 USD ; #ofxid: 1005
+  Unbalanced     -2000.00 USD
+`,
+		`2020/01/10 BUY VTI BOUGHT
+  Assets:Broker:VTI    10.000 VTI ; #ofxid: 1001
+  Assets:Broker      -1509.99 USD
+`,
+		`2020/01/20 SELL VTI
+  Assets:Broker      400.00 USD
+  Assets:Broker:VTI  -2.500 VTI ; #ofxid: 1002
+`,
+		`2020/01/25 DIV VTI
+  Assets:Broker      12.34 USD ; #ofxid: 1003
+  Income:Dividends  -12.34 USD
+`,
+		`2020/01/26 REINVEST DIV VTI
+  Assets:Broker:VTI   0.100 VTI ; #ofxid: 1004
+  Income:Dividends   -15.50 USD
+`,
+	} {
+		assert.Equal(t, txs[i].String(), tx)
+	}
+	assert.Equal(t, len(prices), 1)
+	assert.Equal(t, prices[0].Commodity.Id, "VTI")
+	assert.Equal(t, prices[0].Value.String(), "162.50")
+	assert.Equal(t, prices[0].Time.Format(coin.DateFormat), "2020/01/31")
+
+	// re-importing the statement doesn't repeat the position prices
+	p := importer.NewPipeline()
+	p.Report = nil
+	imp := &Importer{Rules: rules}
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, p.Read(imp, strings.NewReader(strings.TrimSpace(invSample))))
+	}
+	assert.Equal(t, len(p.Prices()), 1)
+}
@@ -0,0 +1,96 @@
+// Package ofx imports OFX/QFX bank, credit card and investment statements.
+package ofx
+
+import (
+	"bytes"
+	"io"
+	"math/big"
+
+	"github.com/aclindsa/ofxgo"
+	"github.com/mkobetic/coin"
+	"github.com/mkobetic/coin/importer"
+	"github.com/mkobetic/coin/redact"
+)
+
+// Importer reads OFX/QFX files, the accounts are matched by the ofx_acctid directive.
+type Importer struct {
+	Rules *coin.RuleIndex
+	BMO   bool // handle invalid qfx files from Bank of Montreal
+}
+
+// The postings of the imported accounts are tagged with the OFX transaction id (FITID),
+// e.g. `#ofxid: 20190113162900`. The FITID is unique for the account,
+// so it identifies previously imported transactions reliably, unlike comparing the postings,
+// which drops genuinely identical transactions (e.g. 2 identical phone top ups on the same day).
+const idTag = "ofxid"
+
+func (*Importer) Name() string { return "ofx" }
+
+func (*Importer) Accepts(fileName string, head []byte) bool {
+	return importer.HasExtension(fileName, ".ofx", ".qfx") ||
+		bytes.Contains(head, []byte("OFXHEADER")) ||
+		bytes.Contains(head, []byte("<OFX>"))
+}
+
+func (imp *Importer) Read(r io.Reader) (*importer.Statement, error) {
+	if imp.BMO {
+		r = newBMOReader(r)
+	}
+	responses, err := ofxgo.ParseResponse(r)
+	if err != nil {
+		return nil, err
+	}
+	red, err := redact.New(imp.Rules.Redact...)
+	if err != nil {
+		return nil, err
+	}
+	s := &importer.Statement{Redactor: red}
+
+	// read bank transactions
+	for _, resp := range responses.Bank {
+		resp := resp.(*ofxgo.StatementResponse)
+		rules := imp.Rules.AccountRulesFor(resp.BankAcctFrom.AcctID.String())
+		last := len(resp.BankTranList.Transactions) - 1
+		for i, t := range resp.BankTranList.Transactions {
+			var balance *big.Rat
+			if i == last {
+				balance = &(resp.BalAmt.Rat)
+			}
+			s.Entries = append(s.Entries, newEntry(rules,
+				t,
+				importer.Trim(t.Name.String()+t.Memo.String()),
+				balance,
+			))
+		}
+	}
+	// read credit card transactions
+	for _, resp := range responses.CreditCard {
+		resp := resp.(*ofxgo.CCStatementResponse)
+		rules := imp.Rules.AccountRulesFor(resp.CCAcctFrom.AcctID.String())
+		for _, t := range resp.BankTranList.Transactions {
+			s.Entries = append(s.Entries, newEntry(rules, t, t.Name.String(), nil))
+		}
+	}
+
+	// read investment transactions and positions
+	secs := readSecurities(responses)
+	for _, resp := range responses.InvStmt {
+		resp := resp.(*ofxgo.InvStatementResponse)
+		if err := readInvestments(s, resp, secs, imp.Rules); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+func newEntry(ars *coin.AccountRules, t ofxgo.Transaction, payee string, balance *big.Rat) *importer.Entry {
+	return &importer.Entry{
+		Rules:       ars,
+		Id:          t.FiTID.String(),
+		Posted:      t.DtPosted.Time,
+		Description: payee,
+		Amount:      &t.TrnAmt.Rat,
+		Balance:     balance,
+	}
+}
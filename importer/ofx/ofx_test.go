@@ -1,4 +1,4 @@
-package main
+package ofx
 
 import (
 	"io"
@@ -9,6 +9,7 @@ import (
 
 	"github.com/mkobetic/coin"
 	"github.com/mkobetic/coin/assert"
+	"github.com/mkobetic/coin/importer"
 )
 
 func init() {
@@ -19,6 +20,10 @@ commodity CAD
 commodity USD
   format 1.00 USD
 
+commodity VTI
+  note Vanguard Total Stock Market ETF
+  format 1.000 VTI
+
 account Assets:Bank:Checking
 account Assets:Bank:Savings
 account Expenses:Groceries
@@ -28,6 +33,12 @@ account Expenses:Miscellaneous
 account Income:Salary
 account Income:Interest
 account Liabilities:Credit:MC
+account Assets:Broker
+  commodity USD
+account Assets:Broker:VTI
+  commodity VTI
+account Income:Dividends
+  commodity USD
 `)
 	coin.Load(r, "")
 	coin.ResolveAccounts()
@@ -46,17 +57,43 @@ func Test_Classification(t *testing.T) {
 		{"479347938749398", "[TR] COSTCO WHOLESALE #9239", "Expenses:Groceries"},
 		{"479347938749398", "JOE'S DINER", "Expenses:Miscellaneous"},
 	} {
-		tran := newTransaction(rules.Accounts[fix.from], date, fix.payee, *big.NewRat(-10000, 100), nil)
+		tran := importer.Classify(&importer.Entry{
+			Rules:       rules.Accounts[fix.from],
+			Posted:      date,
+			Description: fix.payee,
+			Amount:      big.NewRat(-10000, 100),
+		}, idTag, false)
 		if account := tran.Postings[0].Account.FullName; account != fix.to {
 			t.Errorf("mismatched\nexp: %s\ngot: %s\n", fix.to, account)
 		}
 	}
-	whatever := newTransaction(rules.Accounts["479347938749398"], date, "TO BE IGNORED WHEN WATSWR", *big.NewRat(-10000, 100), nil)
+	whatever := importer.Classify(&importer.Entry{
+		Rules:       rules.Accounts["479347938749398"],
+		Posted:      date,
+		Description: "TO BE IGNORED WHEN WATSWR",
+		Amount:      big.NewRat(-10000, 100),
+	}, idTag, false)
 	if whatever != nil {
 		t.Error("should be nil")
 	}
 }
 
+// readTransactions reads the statement and classifies its entries like the import pipeline.
+func readTransactions(r io.Reader, rules *coin.RuleIndex) ([]*coin.Transaction, []*coin.Price, error) {
+	imp := &Importer{Rules: rules}
+	s, err := imp.Read(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	var txs []*coin.Transaction
+	for _, e := range s.Entries {
+		if t := importer.Classify(e, importer.IdTag(imp), s.SubAccounts); t != nil {
+			txs = append(txs, t)
+		}
+	}
+	return append(txs, s.Transactions...), s.Prices, nil
+}
+
 func Test_ReadTransactions(t *testing.T) {
 	var r io.Reader
 	r = strings.NewReader(sample)
@@ -75,7 +112,7 @@ func Test_ReadTransactions(t *testing.T) {
 
 	r = strings.NewReader(txsSample)
 	r = newBMOReader(r)
-	txs, err := readTransactions(r, rules)
+	txs, _, err := readTransactions(r, rules)
 	if !assert.NoError(t, err) {
 		t.FailNow()
 	}
@@ -83,39 +120,39 @@ func Test_ReadTransactions(t *testing.T) {
 	for i, tx := range []string{
 		`2019/01/04 [CK]NO.272
   Unbalanced             704.00 CAD
-  Assets:Bank:Checking  -704.00 CAD
+  Assets:Bank:Checking  -704.00 CAD ; #ofxid: 20190104192100
 `,
 		`2019/01/14 [CW]ROGERS CABLE TV
   Unbalanced             114.12 CAD
-  Assets:Bank:Checking  -114.12 CAD
+  Assets:Bank:Checking  -114.12 CAD ; #ofxid: 20190113162900
 `,
 		`2019/01/14 [CW]PRIMUS
   Unbalanced             54.18 CAD
-  Assets:Bank:Checking  -54.18 CAD
+  Assets:Bank:Checking  -54.18 CAD ; #ofxid: 20190113162901
 `,
 		`2019/01/14 [CW]ENBRIDGE
   Unbalanced             96.33 CAD
-  Assets:Bank:Checking  -96.33 CAD
+  Assets:Bank:Checking  -96.33 CAD ; #ofxid: 20190113162902
 `,
 		`2019/01/14 [CW]HYDRO
   Unbalanced             66.40 CAD
-  Assets:Bank:Checking  -66.40 CAD
+  Assets:Bank:Checking  -66.40 CAD ; #ofxid: 20190113162903
 `,
 		`2019/01/15 [DN]ACME PAY
-  Assets:Bank:Checking   1211.04 CAD
+  Assets:Bank:Checking   1211.04 CAD ; #ofxid: 20190114214400
   Income:Salary         -1211.04 CAD
 `,
 		`2019/01/15 [CW] TF
   Unbalanced             200.00 CAD
-  Assets:Bank:Checking  -200.00 CAD
+  Assets:Bank:Checking  -200.00 CAD ; #ofxid: 20190115064000
 `,
 		`2019/01/22 [DN]MEDICARE
-  Assets:Bank:Checking   704.00 CAD
+  Assets:Bank:Checking   704.00 CAD ; #ofxid: 20190122172300
   Unbalanced            -704.00 CAD
 `,
 		`2019/01/28 [CW] TF
   Unbalanced             1059.51 CAD
-  Assets:Bank:Checking  -1059.51 CAD = 11462.95 CAD
+  Assets:Bank:Checking  -1059.51 CAD = 11462.95 CAD ; #ofxid: 20190127181200
 `,
 	} {
 		assert.Equal(t, txs[i].String(), tx)
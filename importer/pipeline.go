@@ -0,0 +1,355 @@
+package importer
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/mkobetic/coin"
+	"github.com/mkobetic/coin/classify"
+	"github.com/mkobetic/coin/dedup"
+)
+
+// Pipeline converts statements read by importers into transactions.
+type Pipeline struct {
+	KeepDupes  bool    // keep duplicate transactions and prices
+	Days       int     // maximum date difference in days of duplicates and transfers
+	Similarity float64 // minimum description similarity of duplicates (see dedup.Matcher)
+	// Classifier predicts the counter accounts of the transactions that didn't match any rule, optional
+	Classifier *classify.Classifier
+
+	// Report receives the explanations of merged transfers and duplicate decisions
+	Report io.Writer
+	// Review receives the ambiguous duplicate cases, if set
+	Review io.Writer
+
+	transactions coin.TransactionsByTime
+	prices       []*coin.Price
+	tags         []string
+}
+
+// NewPipeline returns a pipeline with the default duplicate detection settings reporting to stderr.
+func NewPipeline() *Pipeline {
+	return &Pipeline{
+		Days:       3,
+		Similarity: 0.5,
+		Report:     os.Stderr,
+	}
+}
+
+// Read reads a statement using the importer and converts its entries into transactions,
+// the descriptions and notes are masked by the statement Redactor.
+func (p *Pipeline) Read(imp Importer, r io.Reader) error {
+	s, err := imp.Read(r)
+	if err != nil {
+		return err
+	}
+	tag := IdTag(imp)
+	p.addTag(tag)
+	p.transactions = append(p.transactions, s.Classify(tag)...)
+	p.prices = append(p.prices, s.Prices...)
+	return nil
+}
+
+// Classify converts the statement entries into transactions and appends the statement transactions,
+// the descriptions and notes are masked by the Redactor after the rules are matched.
+// The transactions of consecutive entries with the same Group are combined into the first one.
+func (s *Statement) Classify(tag string) (transactions []*coin.Transaction) {
+	var group string
+	var grouped *coin.Transaction
+	for _, e := range s.Entries {
+		t := Classify(e, tag, s.SubAccounts)
+		if e.Group == "" || e.Group != group {
+			group, grouped = e.Group, nil
+		}
+		if t == nil {
+			continue
+		}
+		// the rules match the unmasked description, the entry id in the posting notes is kept as is
+		t.Description = s.Redactor.String(t.Description)
+		for i, n := range t.Notes {
+			t.Notes[i] = s.Redactor.String(n)
+		}
+		if grouped != nil {
+			grouped.Combine(t)
+			continue
+		}
+		if group != "" {
+			grouped = t
+		}
+		transactions = append(transactions, t)
+	}
+	for _, t := range s.Transactions {
+		s.Redactor.Transaction(t)
+	}
+	return append(transactions, s.Transactions...)
+}
+
+func (p *Pipeline) addTag(tag string) {
+	for _, t := range p.tags {
+		if t == tag {
+			return
+		}
+	}
+	p.tags = append(p.tags, tag)
+}
+
+// Classify converts the entry into a transaction, the counter account is picked by the rules
+// (or the amount is split across several accounts, see coin.Rule.Parts),
+// the entry id is tagged with the tag on the statement account posting.
+// Returns nil if the entry should be dropped.
+func Classify(e *Entry, tag string, subAccounts bool) *coin.Transaction {
+	in := &coin.RuleInput{Payee: e.Description, Posted: e.Posted, Account: e.Rules.Account}
+	if e.Quantity == nil {
+		in.Amount = e.Amount
+	}
+	rule := e.Rules.RuleForInput(in)
+	notes := e.Notes
+	if rule != nil {
+		if rule.Account == nil {
+			// drop the transaction
+			return nil
+		}
+		notes = append(append([]string{}, e.Notes...), rule.Notes...)
+	}
+	accountFor := func(root *coin.Account, c *coin.Commodity) *coin.Account {
+		if root.Commodity == c && (!subAccounts || root == coin.Unbalanced) {
+			return root
+		}
+		return FindAccountForCommodity(c, root)
+	}
+	amountOf := func(v *big.Rat, c *coin.Commodity) *coin.Amount {
+		return coin.NewAmountFrac(v.Num(), v.Denom(), c)
+	}
+	t := &coin.Transaction{
+		Posted:      e.Posted,
+		Description: e.Description,
+		Notes:       notes,
+	}
+	to := coin.Unbalanced
+	if rule != nil {
+		to = rule.Account
+	}
+	var from, cash *coin.Account // cash is the account of the Amount
+	var currency *coin.Commodity
+	if e.Quantity == nil {
+		currency = e.Currency
+		if currency == nil {
+			currency = e.Rules.Account.Commodity
+		}
+		from = accountFor(e.Rules.Account, currency)
+		cash = from
+		var balance *coin.Amount
+		if e.Balance != nil {
+			balance = amountOf(e.Balance, currency)
+		}
+		amount := amountOf(e.Amount, currency)
+		accounts, amounts := []*coin.Account{to}, []*coin.Amount{amount.Negated()}
+		if rule != nil {
+			accounts, amounts = rule.Parts(amount)
+		}
+		for i, a := range accounts {
+			accounts[i] = accountFor(a, currency)
+		}
+		t.PostSplit(from, amount, balance, accounts, amounts)
+	} else {
+		currency = e.Currency
+		if currency == nil {
+			currency = to.Commodity
+		}
+		amount := amountOf(e.Amount, currency)
+		quantity := amountOf(e.Quantity, e.Commodity)
+		// Quantity and amount cannot be both positive or negative,
+		// if they are amount wins, make quantity the opposite.
+		if quantity.Sign()*amount.Sign() > 0 {
+			quantity = quantity.Negated()
+		}
+		from = accountFor(e.Rules.Account, e.Commodity)
+		cash = accountFor(to, currency)
+		t.PostConversion(from, quantity, nil, cash, amount, nil)
+	}
+	if e.Fee != nil && e.Fee.Sign() != 0 {
+		// the fee is deducted from the cash posting and posted to the fee account
+		fee := amountOf(new(big.Rat).Abs(e.Fee), currency)
+		for _, p := range t.Postings {
+			if p.Account == cash {
+				p.Quantity = coin.NewAmount(new(big.Int).Sub(p.Quantity.Int, fee.Int), currency)
+				break
+			}
+		}
+		feeAccount := e.FeeAccount
+		if feeAccount == nil {
+			feeAccount = coin.Unbalanced
+		}
+		t.AddPosting(accountFor(feeAccount, currency), fee, nil)
+	}
+	SetId(t, from, tag, e.Id)
+	return t
+}
+
+// Transactions returns the imported transactions sorted by time,
+// with transfers merged, unmatched transactions classified and duplicates removed (unless KeepDupes is set).
+func (p *Pipeline) Transactions() coin.TransactionsByTime {
+	transactions := append(coin.TransactionsByTime{}, p.transactions...)
+	sort.Stable(transactions)
+	transactions = p.mergeTransfers(transactions)
+	p.classify(transactions)
+	if p.KeepDupes {
+		return transactions
+	}
+	m := dedup.NewMatcher(coin.Transactions, p.Days, p.Similarity)
+	m.Tags = p.tags
+	m.Report = p.Report
+	m.Review = p.Review
+	return m.Filter(transactions)
+}
+
+// mergeTransfers merges the transfers between the imported accounts (see FindTransfers)
+// into a single transaction, e.g. a credit card payment imported from both the checking and the credit card statement.
+func (p *Pipeline) mergeTransfers(transactions coin.TransactionsByTime) (merged coin.TransactionsByTime) {
+	dropped := map[*coin.Transaction]bool{}
+	for _, tr := range FindTransfers(transactions, p.Days) {
+		if p.Report != nil {
+			fmt.Fprintf(p.Report, "MERGING TRANSFER:\n%s\n%s\n", tr.First, tr.Second)
+		}
+		tr.Merge()
+		dropped[tr.Second] = true
+	}
+	for _, t := range transactions {
+		if !dropped[t] {
+			merged = append(merged, t)
+		}
+	}
+	return merged
+}
+
+// Transfer is a pair of transactions moving the same amount between two accounts within a few days,
+// that were not classified, i.e. the other side of both transactions is Unbalanced.
+type Transfer struct {
+	First, Second *coin.Transaction
+}
+
+// FindTransfers pairs the transactions (sorted by time) with two postings, one of them to Unbalanced,
+// with opposite amounts posted to different accounts within days.
+// Each transaction is paired at most once, with the first matching transaction.
+func FindTransfers(transactions coin.TransactionsByTime, days int) (transfers []Transfer) {
+	paired := map[*coin.Transaction]bool{}
+	for i, t := range transactions {
+		if paired[t] {
+			continue
+		}
+		u, s := unbalanced(t)
+		if u == nil {
+			continue
+		}
+		until := t.Posted.Add(time.Duration(days) * 24 * time.Hour)
+		for _, t2 := range transactions[i+1:] {
+			if t2.Posted.After(until) {
+				break
+			}
+			if paired[t2] {
+				continue
+			}
+			u2, s2 := unbalanced(t2)
+			if u2 == nil || s2.Account == s.Account ||
+				s2.Quantity.Commodity != u.Quantity.Commodity || !s2.Quantity.IsEqual(u.Quantity) {
+				continue
+			}
+			transfers = append(transfers, Transfer{t, t2})
+			paired[t], paired[t2] = true, true
+			break
+		}
+	}
+	return transfers
+}
+
+// Merge replaces the Unbalanced posting of the First transaction with the statement account posting
+// of the Second transaction, including its balance assertion, notes and tags.
+// The Second transaction should be dropped.
+func (tr Transfer) Merge() {
+	u, _ := unbalanced(tr.First)
+	_, s2 := unbalanced(tr.Second)
+	u.MoveTo(s2.Account)
+	u.Balance, u.BalanceAsserted = s2.Balance, s2.BalanceAsserted
+	u.Notes, u.Tags = s2.Notes, s2.Tags
+}
+
+// ConfidenceTag is the tag with the confidence of the predicted counter account,
+// e.g. #confidence: 0.87, the predictions should be reviewed.
+const ConfidenceTag = "confidence"
+
+// classify moves the Unbalanced postings to the accounts predicted by the Classifier.
+// This is done after merging transfers, so that the transfers aren't classified.
+func (p *Pipeline) classify(transactions coin.TransactionsByTime) {
+	if p.Classifier == nil {
+		return
+	}
+	for _, t := range transactions {
+		u, s := unbalanced(t)
+		if u == nil {
+			continue
+		}
+		account, confidence := p.Classifier.Predict(t.Description, s.Quantity, s.Account)
+		if account == nil {
+			continue
+		}
+		u.MoveTo(account)
+		u.Notes = append(u.Notes, fmt.Sprintf("#%s: %.2f", ConfidenceTag, confidence))
+		u.Tags = coin.ParseTags(u.Notes...)
+	}
+}
+
+// unbalanced returns the Unbalanced posting and the other posting of a transaction with two postings.
+func unbalanced(t *coin.Transaction) (u, s *coin.Posting) {
+	if len(t.Postings) != 2 {
+		return nil, nil
+	}
+	for _, p := range t.Postings {
+		if p.Account == coin.Unbalanced {
+			return p, t.Other(p)
+		}
+	}
+	return nil, nil
+}
+
+// Prices returns the imported prices that are not already in the ledger (unless KeepDupes is set),
+// a commodity gets at most one price per day, e.g. when overlapping statements are imported.
+func (p *Pipeline) Prices() (prices []*coin.Price) {
+	for _, pr := range p.prices {
+		if !p.KeepDupes && (hasPrice(pr, pr.Commodity.Prices[pr.Currency]) || hasPrice(pr, prices)) {
+			continue
+		}
+		prices = append(prices, pr)
+	}
+	return prices
+}
+
+// hasPrice returns true if prices have a price of the same commodity for the same day.
+func hasPrice(p *coin.Price, prices []*coin.Price) bool {
+	y, m, d := p.Time.Date()
+	for _, p2 := range prices {
+		if y2, m2, d2 := p2.Time.Date(); y2 == y && m2 == m && d2 == d &&
+			p2.Commodity == p.Commodity && p2.Currency == p.Currency {
+			return true
+		}
+	}
+	return false
+}
+
+// Write writes the imported prices followed by the transactions.
+func (p *Pipeline) Write(w io.Writer) {
+	prices := p.Prices()
+	for _, pr := range prices {
+		pr.Write(w, false)
+	}
+	if len(prices) > 0 {
+		fmt.Fprintln(w)
+	}
+	for _, t := range p.Transactions() {
+		t.Write(w, false)
+		fmt.Fprintln(w)
+	}
+}
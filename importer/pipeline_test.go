@@ -0,0 +1,257 @@
+package importer
+
+import (
+	"io"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mkobetic/coin"
+	"github.com/mkobetic/coin/assert"
+	"github.com/mkobetic/coin/classify"
+	"github.com/mkobetic/coin/redact"
+)
+
+func init() {
+	coin.Load(strings.NewReader(`
+commodity CAD
+  format 1.00 CAD
+
+account Assets:Bank:Checking
+  ofx_acctid 111
+account Assets:Bank:Savings
+  ofx_acctid 222
+account Expenses:Phone
+account Expenses:Fees
+
+2019/01/10 ROGERS TOP UP
+  Expenses:Phone         25.00 CAD
+  Assets:Bank:Checking   -25.00 CAD ; #stubid: 1
+`), "ledger.coin")
+	coin.ResolveAll()
+}
+
+type stubImporter struct {
+	entries  []*Entry
+	redactor *redact.Redactor
+}
+
+func (*stubImporter) Name() string                { return "stub" }
+func (*stubImporter) Accepts(string, []byte) bool { return true }
+func (imp *stubImporter) Read(io.Reader) (*Statement, error) {
+	return &Statement{Entries: imp.entries, Redactor: imp.redactor}, nil
+}
+
+func Test_Pipeline(t *testing.T) {
+	rules, err := coin.ReadRules(strings.NewReader(`
+111 Assets:Bank:Checking
+  Expenses:Phone  ROGERS
+  --  IGNORE
+`))
+	assert.NoError(t, err)
+	checking := rules.AccountRulesFor("111")
+	savings := rules.AccountRulesFor("222")
+	entry := func(ars *coin.AccountRules, id, date, description string, amount int64) *Entry {
+		posted, err := time.Parse(coin.DateFormat, date)
+		if err != nil {
+			panic(err)
+		}
+		return &Entry{
+			Rules:       ars,
+			Id:          id,
+			Posted:      posted.Add(12 * time.Hour),
+			Description: description,
+			Amount:      big.NewRat(amount, 100),
+		}
+	}
+	var report strings.Builder
+	p := NewPipeline()
+	p.Report = &report
+	assert.NoError(t, p.Read(&stubImporter{[]*Entry{
+		entry(checking, "1", "2019/01/10", "ROGERS TOP UP", -2500),
+		entry(checking, "2", "2019/01/14", "ROGERS TOP UP", -2500),
+		entry(checking, "3", "2019/01/14", "ROGERS TOP UP", -2500),
+		entry(checking, "4", "2019/01/15", "TRANSFER", -20000),
+		entry(checking, "5", "2019/01/15", "IGNORE ME", -100),
+	}, nil}, nil))
+	assert.NoError(t, p.Read(&stubImporter{[]*Entry{
+		entry(savings, "1", "2019/01/16", "TRANSFER FROM 111", 20000),
+		entry(checking, "3", "2019/01/14", "ROGERS TOP UP", -2500),
+	}, nil}, nil))
+	var b strings.Builder
+	for _, t := range p.Transactions() {
+		t.Write(&b, false)
+	}
+	assert.Equal(t, b.String(), `2019/01/14 ROGERS TOP UP
+  Expenses:Phone         25.00 CAD
+  Assets:Bank:Checking  -25.00 CAD ; #stubid: 2
+2019/01/14 ROGERS TOP UP
+  Expenses:Phone         25.00 CAD
+  Assets:Bank:Checking  -25.00 CAD ; #stubid: 3
+2019/01/15 TRANSFER
+  Assets:Bank:Savings    200.00 CAD ; #stubid: 1
+  Assets:Bank:Checking  -200.00 CAD ; #stubid: 4
+`)
+	assert.Equal(t, strings.Count(report.String(), "MERGING TRANSFER"), 1)
+	assert.Equal(t, strings.Count(report.String(), "DROPPING DUPLICATE TRANSACTION (same stubid"), 2)
+}
+
+func Test_PipelineRedact(t *testing.T) {
+	rules, err := coin.ReadRules(strings.NewReader(`redact
+  card
+  phone
+111 Assets:Bank:Checking
+  Expenses:Phone  PAYMENT 4111 1111
+`))
+	assert.NoError(t, err)
+	red, err := redact.New(rules.Redact...)
+	assert.NoError(t, err)
+	p := NewPipeline()
+	p.KeepDupes = true
+	assert.NoError(t, p.Read(&stubImporter{[]*Entry{{
+		Rules:       rules.AccountRulesFor("111"),
+		Id:          "4111111111111111",
+		Posted:      time.Date(2019, 1, 20, 12, 0, 0, 0, time.UTC),
+		Description: "PAYMENT 4111 1111 1111 1111",
+		Notes:       []string{"call 416-555-1234"},
+		Amount:      big.NewRat(-10, 1),
+	}}, red}, nil))
+	var b strings.Builder
+	for _, t := range p.Transactions() {
+		t.Write(&b, false)
+	}
+	// the rules match the description before it is masked
+	assert.Equal(t, b.String(), `2019/01/20 PAYMENT XXXX XXXX XXXX 1111 ; call XXX-XXX-XXXX
+  Expenses:Phone         10.00 CAD
+  Assets:Bank:Checking  -10.00 CAD ; #stubid: 4111111111111111
+`)
+}
+
+func Test_ClassifySplit(t *testing.T) {
+	rules, err := coin.ReadRules(strings.NewReader(`
+111 Assets:Bank:Checking
+  Expenses:Phone  ROGERS
+  ? amount < 0
+  + Expenses:Fees 2.50
+`))
+	assert.NoError(t, err)
+	e := &Entry{
+		Rules:       rules.AccountRulesFor("111"),
+		Id:          "7",
+		Posted:      time.Date(2019, 1, 20, 12, 0, 0, 0, time.UTC),
+		Description: "ROGERS",
+		Amount:      big.NewRat(-30, 1),
+	}
+	assert.Equal(t, Classify(e, "stubid", false).String(), `2019/01/20 ROGERS
+  Expenses:Phone         27.50 CAD
+  Expenses:Fees           2.50 CAD
+  Assets:Bank:Checking  -30.00 CAD ; #stubid: 7
+`)
+	e.Amount = big.NewRat(30, 1)
+	assert.Equal(t, Classify(e, "stubid", false).String(), `2019/01/20 ROGERS
+  Assets:Bank:Checking   30.00 CAD ; #stubid: 7
+  Unbalanced            -30.00 CAD
+`)
+}
+
+func Test_PipelineClassify(t *testing.T) {
+	rules, err := coin.ReadRules(strings.NewReader(`
+111 Assets:Bank:Checking
+`))
+	assert.NoError(t, err)
+	entry := func(ars *coin.AccountRules, day int, description string, amount int64) *Entry {
+		return &Entry{
+			Rules:       ars,
+			Posted:      time.Date(2019, 2, day, 12, 0, 0, 0, time.UTC),
+			Description: description,
+			Amount:      big.NewRat(amount, 100),
+		}
+	}
+	p := NewPipeline()
+	p.Report = nil
+	p.KeepDupes = true
+	p.Classifier = classify.New(coin.Transactions, 0.5)
+	assert.NoError(t, p.Read(&stubImporter{[]*Entry{
+		entry(rules.AccountRulesFor("111"), 1, "ROGERS WIRELESS", -3000),
+		entry(rules.AccountRulesFor("111"), 2, "TRANSFER", -10000),
+		entry(rules.AccountRulesFor("222"), 2, "TRANSFER", 10000),
+	}, nil}, nil))
+	var b strings.Builder
+	for _, t := range p.Transactions() {
+		t.Write(&b, false)
+	}
+	assert.Equal(t, b.String(), `2019/02/01 ROGERS WIRELESS
+  Expenses:Phone         30.00 CAD ; #confidence: 1.00
+  Assets:Bank:Checking  -30.00 CAD
+2019/02/02 TRANSFER
+  Assets:Bank:Savings    100.00 CAD
+  Assets:Bank:Checking  -100.00 CAD
+`)
+}
+
+func Test_PipelineTransfers(t *testing.T) {
+	rules, err := coin.ReadRules(strings.NewReader(`
+111 Assets:Bank:Checking
+222 Assets:Bank:Savings
+`))
+	assert.NoError(t, err)
+	entry := func(ars *coin.AccountRules, id string, day int, amount, balance int64) *Entry {
+		return &Entry{
+			Rules:       ars,
+			Id:          id,
+			Posted:      time.Date(2019, 3, day, 12, 0, 0, 0, time.UTC),
+			Description: "TRANSFER",
+			Amount:      big.NewRat(amount, 100),
+			Balance:     big.NewRat(balance, 100),
+		}
+	}
+	p := NewPipeline()
+	p.Report = nil
+	p.KeepDupes = true
+	assert.NoError(t, p.Read(&stubImporter{[]*Entry{
+		entry(rules.AccountRulesFor("111"), "1", 1, -10000, 50000),
+		entry(rules.AccountRulesFor("111"), "2", 2, -5000, 45000),
+		entry(rules.AccountRulesFor("111"), "3", 3, 5000, 50000),
+		entry(rules.AccountRulesFor("222"), "1", 3, 10000, 10000),
+		entry(rules.AccountRulesFor("222"), "2", 9, 5000, 15000),
+	}, nil}, nil))
+	var b strings.Builder
+	for _, t := range p.Transactions() {
+		t.Write(&b, false)
+	}
+	assert.Equal(t, b.String(), `2019/03/01 TRANSFER
+  Assets:Bank:Savings    100.00 CAD = 100.00 CAD ; #stubid: 1
+  Assets:Bank:Checking  -100.00 CAD = 500.00 CAD ; #stubid: 1
+2019/03/02 TRANSFER
+  Unbalanced             50.00 CAD
+  Assets:Bank:Checking  -50.00 CAD = 450.00 CAD ; #stubid: 2
+2019/03/03 TRANSFER
+  Assets:Bank:Checking   50.00 CAD = 500.00 CAD ; #stubid: 3
+  Unbalanced            -50.00 CAD
+2019/03/09 TRANSFER
+  Assets:Bank:Savings   50.00 CAD = 150.00 CAD ; #stubid: 2
+  Unbalanced           -50.00 CAD
+`)
+}
+
+func Test_PipelinePrices(t *testing.T) {
+	usd := &coin.Commodity{Id: "USD", Decimals: 2}
+	vti := &coin.Commodity{Id: "VTI", Decimals: 3}
+	price := func(day int, cents int64) *coin.Price {
+		return &coin.Price{
+			Commodity: vti,
+			Currency:  usd,
+			Value:     coin.NewAmountFrac(big.NewInt(cents), big.NewInt(100), usd),
+			Time:      time.Date(2020, 1, day, 12, 0, 0, 0, time.UTC),
+		}
+	}
+	vti.AddPrice(price(10, 16000))
+	p := NewPipeline()
+	p.prices = []*coin.Price{price(10, 16050), price(11, 16100), price(11, 16100)}
+	prices := p.Prices()
+	assert.Equal(t, len(prices), 1)
+	assert.Equal(t, prices[0].String(), "P 2020/01/11 VTI 161.00 USD\n")
+	p.KeepDupes = true
+	assert.Equal(t, len(p.Prices()), 3)
+}
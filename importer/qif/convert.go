@@ -0,0 +1,300 @@
+package qif
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/mkobetic/coin"
+	"github.com/mkobetic/coin/importer"
+)
+
+// convert builds coin transactions from the records,
+// using the rules to pick the counter accounts based on the payee.
+func (q *qif) convert(rules *coin.RuleIndex) (transactions []*coin.Transaction, err error) {
+	if rules == nil {
+		rules = &coin.RuleIndex{}
+	}
+	accounts := map[string]*coin.AccountRules{}
+	for _, rec := range q.records {
+		if rec.account == "" {
+			return nil, fmt.Errorf("%s: unknown account, the account id must be specified", rec.location)
+		}
+		ars := accounts[rec.account]
+		if ars == nil {
+			ars = accountRules(rules, rec.account)
+			accounts[rec.account] = ars
+		}
+		var t *coin.Transaction
+		if rec.kind == "invst" {
+			t = q.investment(ars, rec)
+		} else {
+			t = q.transaction(ars, rec)
+		}
+		if t != nil {
+			transactions = append(transactions, t)
+		}
+	}
+	return transactions, nil
+}
+
+// accountRules finds the rules for the account id,
+// which is either an import id of the account or its full name.
+func accountRules(rules *coin.RuleIndex, id string) *coin.AccountRules {
+	if ars := rules.Accounts[id]; ars != nil {
+		return ars
+	}
+	if a := coin.AccountsByName[id]; a != nil {
+		for _, ars := range rules.Accounts {
+			if ars.Account == a {
+				return ars
+			}
+		}
+		return &coin.AccountRules{Account: a}
+	}
+	return rules.AccountRulesFor(id)
+}
+
+// header returns the transaction for the record without postings.
+// Returns nil if the record should be dropped.
+func (q *qif) header(ars *coin.AccountRules, rec *record, payee string) (*coin.Transaction, *coin.Rule) {
+	date, err := q.parseDate(rec.fields['D'])
+	if err != nil {
+		q.warn(rec.location, "skipping transaction: %s", err)
+		return nil, nil
+	}
+	t := &coin.Transaction{
+		Posted:      date,
+		Description: payee,
+	}
+	rule := ars.RuleForInput(&coin.RuleInput{Payee: payee, Posted: date})
+	if rule != nil {
+		if rule.Account == nil {
+			// drop the transaction
+			return nil, nil
+		}
+		t.Notes = append(t.Notes, rule.Notes...)
+	}
+	if memo := importer.Trim(rec.fields['M']); memo != "" {
+		t.Notes = append(t.Notes, memo)
+	}
+	return t, rule
+}
+
+// transaction converts bank, cash and credit card records.
+func (q *qif) transaction(ars *coin.AccountRules, rec *record) *coin.Transaction {
+	t, rule := q.header(ars, rec, importer.Trim(rec.fields['P']))
+	if t == nil {
+		return nil
+	}
+	t.Code = rec.fields['N']
+	amount := q.amount(rec, 'T', ars.Account.Commodity)
+	if amount == nil {
+		amount = q.amount(rec, 'U', ars.Account.Commodity)
+	}
+	if amount == nil {
+		q.warn(rec.location, "skipping transaction without amount")
+		return nil
+	}
+	to := coin.Unbalanced
+	if rule != nil {
+		to = rule.Account
+	}
+	if len(rec.splits) == 0 {
+		if rule == nil {
+			to = q.category(t, rec.fields['L'], to)
+		}
+		t.Post(ars.Account, to, amount, nil)
+		return t
+	}
+	var splits []*coin.Amount
+	for _, s := range rec.splits {
+		v, err := parseNumber(s.amount)
+		if err != nil {
+			q.warn(rec.location, "skipping transaction: %s", err)
+			return nil
+		}
+		splits = append(splits, coin.NewAmountFrac(v.Num(), v.Denom(), ars.Account.Commodity))
+	}
+	t.AddPosting(ars.Account, amount, nil)
+	for i, s := range rec.splits {
+		var notes []string
+		account := categoryAccount(s.category)
+		if account == nil {
+			account = to
+			if s.category != "" {
+				notes = append(notes, "category: "+s.category)
+			}
+		}
+		if memo := importer.Trim(s.memo); memo != "" {
+			notes = append(notes, memo)
+		}
+		t.AddPosting(account, splits[i].Negated(), nil).Notes = notes
+	}
+	return t
+}
+
+// investment converts investment account records.
+// Security transactions are posted to the sub-account holding the security commodity,
+// cash is posted to the account itself (or to the transfer account for the X actions).
+// Totals (T or U) are expected to include any commissions.
+func (q *qif) investment(ars *coin.AccountRules, rec *record) *coin.Transaction {
+	action, security := rec.fields['N'], rec.fields['Y']
+	payee := importer.Trim(rec.fields['P'])
+	if payee == "" {
+		payee = importer.Trim(action + " " + security)
+	}
+	t, rule := q.header(ars, rec, payee)
+	if t == nil {
+		return nil
+	}
+	counter := coin.Unbalanced
+	if rule != nil {
+		counter = rule.Account
+	}
+	cash := ars.Account
+	switch action {
+	case "XIn", "XOut", "ContribX", "WithdrwX":
+		if rule == nil {
+			counter = q.category(t, rec.fields['L'], counter)
+		}
+	default:
+		if base, ok := strings.CutSuffix(action, "X"); ok {
+			action = base
+			cash = q.category(t, rec.fields['L'], coin.Unbalanced)
+		}
+	}
+
+	total := q.amount(rec, 'T', ars.Account.Commodity)
+	if total == nil {
+		total = q.amount(rec, 'U', ars.Account.Commodity)
+	}
+	var c *coin.Commodity
+	var quantity *coin.Amount
+	if security != "" {
+		if c = q.commodity(security); c == nil {
+			q.warn(rec.location, "skipping transaction with unknown security %s", security)
+			return nil
+		}
+		quantity = q.amount(rec, 'Q', c)
+		if total == nil && quantity != nil {
+			if price, err := parseNumber(rec.fields['I']); err == nil {
+				v, _ := parseNumber(rec.fields['Q'])
+				v.Mul(v, price)
+				total = coin.NewAmountFrac(v.Num(), v.Denom(), ars.Account.Commodity)
+			}
+		}
+	}
+	if total != nil && total.Sign() < 0 {
+		total = total.Negated()
+	}
+	if quantity != nil && quantity.Sign() < 0 {
+		quantity = quantity.Negated()
+	}
+
+	var needsQuantity, needsTotal bool
+	switch action {
+	case "Buy", "Sell", "ReinvDiv", "ReinvInt", "ReinvLg", "ReinvSh", "ReinvMd":
+		needsQuantity, needsTotal = true, true
+	case "ShrsIn", "ShrsOut":
+		needsQuantity = true
+	case "Div", "IntInc", "CGLong", "CGMid", "CGShort", "MiscInc", "RtrnCap",
+		"MiscExp", "MargInt", "XIn", "XOut", "ContribX", "WithdrwX":
+		needsTotal = true
+	default:
+		q.warn(rec.location, "skipping unsupported investment action %s", rec.fields['N'])
+		return nil
+	}
+	if (needsQuantity && quantity == nil) || (needsTotal && total == nil) {
+		q.warn(rec.location, "skipping %s transaction without quantity or amount", rec.fields['N'])
+		return nil
+	}
+	var holding *coin.Account
+	if needsQuantity {
+		holding = importer.FindAccountForCommodity(c, ars.Account)
+	}
+
+	switch action {
+	case "Buy":
+		t.PostConversion(holding, quantity, nil, cash, total.Negated(), nil)
+	case "Sell":
+		t.PostConversion(holding, quantity.Negated(), nil, cash, total, nil)
+	case "ReinvDiv", "ReinvInt", "ReinvLg", "ReinvSh", "ReinvMd":
+		t.PostConversion(holding, quantity, nil, counter, total.Negated(), nil)
+	case "ShrsIn":
+		t.Post(holding, counter, quantity, nil)
+	case "ShrsOut":
+		t.Post(holding, counter, quantity.Negated(), nil)
+	case "Div", "IntInc", "CGLong", "CGMid", "CGShort", "MiscInc", "RtrnCap", "XIn", "ContribX":
+		t.Post(cash, counter, total, nil)
+	case "MiscExp", "MargInt", "XOut", "WithdrwX":
+		t.Post(cash, counter, total.Negated(), nil)
+	}
+	return t
+}
+
+// amount parses the amount field of the record in commodity c, nil if missing or invalid.
+func (q *qif) amount(rec *record, field byte, c *coin.Commodity) *coin.Amount {
+	s, ok := rec.fields[field]
+	if !ok || s == "" {
+		return nil
+	}
+	v, err := parseNumber(s)
+	if err != nil {
+		q.warn(rec.location, "%s", err)
+		return nil
+	}
+	return coin.NewAmountFrac(v.Num(), v.Denom(), c)
+}
+
+// category returns the account for the category, or the fallback if it can't be found.
+// Unresolved categories are recorded in the transaction notes.
+func (q *qif) category(t *coin.Transaction, category string, fallback *coin.Account) *coin.Account {
+	if a := categoryAccount(category); a != nil {
+		return a
+	}
+	if category != "" {
+		t.Notes = append(t.Notes, "category: "+category)
+	}
+	return fallback
+}
+
+// categoryAccount finds the coin account for a QIF category (Expenses:Food/Class)
+// or transfer account ([Checking]). Matches the full account name first,
+// then account name patterns (see coin.FindAccounts) if the match is unique.
+func categoryAccount(category string) *coin.Account {
+	category, _, _ = strings.Cut(category, "/")
+	category = strings.TrimSuffix(strings.TrimPrefix(category, "["), "]")
+	category = strings.ReplaceAll(category, " ", "")
+	if category == "" {
+		return nil
+	}
+	if a := coin.AccountsByName[category]; a != nil {
+		return a
+	}
+	if as := coin.FindAccounts(regexp.QuoteMeta(category)); len(as) == 1 {
+		return as[0]
+	}
+	return nil
+}
+
+// commodity finds the commodity for the security name,
+// which can be mapped to a symbol in a !Type:Security section.
+func (q *qif) commodity(security string) *coin.Commodity {
+	if symbol := q.securities[security]; symbol != "" {
+		security = symbol
+	}
+	if c := coin.Commodities[security]; c != nil {
+		return c
+	}
+	if c := coin.CommoditiesBySymbol[security]; c != nil {
+		return c
+	}
+	var found *coin.Commodity
+	coin.CommoditiesDo(func(c *coin.Commodity) {
+		if found == nil && c.Name == security {
+			found = c
+		}
+	})
+	return found
+}
@@ -0,0 +1,228 @@
+// Package qif imports QIF (Quicken Interchange Format) files.
+package qif
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mkobetic/coin"
+	"github.com/mkobetic/coin/importer"
+	"github.com/mkobetic/coin/redact"
+)
+
+// Importer reads QIF files. QIF files don't carry account ids, so the account id
+// is taken from the !Account section name or the Account field, it is either an import id
+// of the account or its full name. The records are converted into transactions by the importer,
+// the rules are matched by the payee only (see README).
+type Importer struct {
+	Rules   *coin.RuleIndex
+	Account string    // account id of files without !Account sections
+	DMY     bool      // dates are day first (D/M/Y)
+	Log     io.Writer // receives the warnings about the skipped sections and records, optional
+}
+
+func (*Importer) Name() string { return "qif" }
+
+func (*Importer) Accepts(fileName string, head []byte) bool {
+	head = bytes.TrimPrefix(head, []byte("\ufeff"))
+	return importer.HasExtension(fileName, ".qif") ||
+		bytes.HasPrefix(head, []byte("!Type:")) ||
+		bytes.HasPrefix(head, []byte("!Account")) ||
+		bytes.HasPrefix(head, []byte("!Option"))
+}
+
+func (imp *Importer) Read(r io.Reader) (*importer.Statement, error) {
+	red, err := redact.New(imp.Rules.Redact...)
+	if err != nil {
+		return nil, err
+	}
+	log := imp.Log
+	if log == nil {
+		log = io.Discard
+	}
+	q := newQIF(imp.Account, imp.DMY, log)
+	if err := q.read(r); err != nil {
+		return nil, err
+	}
+	transactions, err := q.convert(imp.Rules)
+	if err != nil {
+		return nil, err
+	}
+	return &importer.Statement{Transactions: transactions, Redactor: red}, nil
+}
+
+// QIF files consist of sections introduced by !Type headers.
+// Each section is a list of records terminated by ^,
+// each line of a record is a single letter field code followed by the value.
+
+// record is a single QIF record.
+type record struct {
+	kind     string // lowercased section type, e.g. bank, ccard or invst
+	account  string // account id of the record
+	location string
+	fields   map[byte]string
+	splits   []*split
+}
+
+// split is a part of a split bank transaction (S, E and $ fields).
+type split struct {
+	category string
+	memo     string
+	amount   string
+}
+
+type qif struct {
+	log        io.Writer
+	account    string            // account id of the records being read
+	dmy        bool              // dates are day first
+	securities map[string]string // security name => symbol
+	records    []*record
+}
+
+func newQIF(account string, dmy bool, log io.Writer) *qif {
+	return &qif{
+		log:        log,
+		account:    account,
+		dmy:        dmy,
+		securities: map[string]string{},
+	}
+}
+
+func (q *qif) warn(location string, format string, args ...interface{}) {
+	fmt.Fprintf(q.log, "%s: "+format+"\n", append([]interface{}{location}, args...)...)
+}
+
+// supported section types, the rest is skipped
+var kinds = map[string]bool{
+	"account":  true,
+	"security": true,
+	"bank":     true,
+	"cash":     true,
+	"ccard":    true,
+	"oth a":    true,
+	"oth l":    true,
+	"invst":    true,
+}
+
+// read parses QIF records from r.
+func (q *qif) read(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	var lineNr int
+	var kind string
+	var rec *record
+	for scanner.Scan() {
+		lineNr++
+		location := fmt.Sprintf("line %d", lineNr)
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if lineNr == 1 {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+		if line == "" {
+			continue
+		}
+		if line[0] == '!' {
+			header, arg, _ := strings.Cut(line[1:], ":")
+			switch strings.ToLower(header) {
+			case "type":
+				kind = strings.ToLower(strings.TrimSpace(arg))
+			case "account":
+				kind = "account"
+			case "option", "clear":
+				continue
+			default:
+				kind = strings.ToLower(header)
+			}
+			if !kinds[kind] {
+				q.warn(location, "ignoring %s section", line)
+			}
+			rec = nil
+			continue
+		}
+		if !kinds[kind] {
+			continue
+		}
+		if rec == nil {
+			rec = &record{kind: kind, account: q.account, location: location, fields: map[byte]string{}}
+		}
+		code, value := line[0], strings.TrimSpace(line[1:])
+		switch {
+		case code == '^':
+			q.add(rec)
+			rec = nil
+		case code == 'S' && kind != "invst" && kind != "security":
+			rec.splits = append(rec.splits, &split{category: value})
+		case code == 'E' && len(rec.splits) > 0:
+			rec.splits[len(rec.splits)-1].memo = value
+		case code == '$' && len(rec.splits) > 0:
+			rec.splits[len(rec.splits)-1].amount = value
+		default:
+			rec.fields[code] = value
+		}
+	}
+	if rec != nil {
+		q.add(rec)
+	}
+	return scanner.Err()
+}
+
+func (q *qif) add(rec *record) {
+	switch rec.kind {
+	case "account":
+		if name := rec.fields['N']; name != "" {
+			q.account = name
+		}
+	case "security":
+		if name, symbol := rec.fields['N'], rec.fields['S']; name != "" && symbol != "" {
+			q.securities[name] = symbol
+		}
+	default:
+		q.records = append(q.records, rec)
+	}
+}
+
+// parseDate parses QIF dates, M/D/Y by default or D/M/Y if dmy is set.
+// Dates starting with a 4 digit year are Y/M/D.
+// Two digit years are 20YY when following an apostrophe (Quicken style) or if less than 70.
+func (q *qif) parseDate(s string) (time.Time, error) {
+	parts := strings.FieldsFunc(s, func(r rune) bool { return r < '0' || r > '9' })
+	if len(parts) != 3 {
+		return time.Time{}, fmt.Errorf("invalid date: %s", s)
+	}
+	var n [3]int
+	for i, p := range parts {
+		n[i], _ = strconv.Atoi(p)
+	}
+	y, m, d := n[2], n[0], n[1]
+	switch {
+	case len(parts[0]) == 4:
+		y, m, d = n[0], n[1], n[2]
+	case q.dmy:
+		d, m = n[0], n[1]
+	}
+	if y < 100 {
+		if strings.Contains(s, "'") || y < 70 {
+			y += 2000
+		} else {
+			y += 1900
+		}
+	}
+	if m < 1 || m > 12 || d < 1 || d > 31 {
+		return time.Time{}, fmt.Errorf("invalid date: %s", s)
+	}
+	return time.Date(y, time.Month(m), d, 12, 0, 0, 0, time.UTC), nil
+}
+
+// parseNumber parses QIF amounts like -1,234.56
+func parseNumber(s string) (*big.Rat, error) {
+	v, ok := new(big.Rat).SetString(strings.ReplaceAll(strings.TrimSpace(s), ",", ""))
+	if !ok {
+		return nil, fmt.Errorf("invalid amount: %s", s)
+	}
+	return v, nil
+}
@@ -0,0 +1,184 @@
+package qif
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/mkobetic/coin"
+	"github.com/mkobetic/coin/assert"
+	"github.com/mkobetic/coin/importer"
+)
+
+func init() {
+	r := strings.NewReader(`
+commodity CAD
+  format 1.00 CAD
+
+commodity VFV
+  note Vanguard S&P 500 Index ETF
+  format 1.000 VFV
+
+account Assets:Bank:Checking
+  ofx_acctid 392843029797099
+account Assets:Broker
+account Assets:Broker:VFV
+  commodity VFV
+account Expenses:Groceries
+account Expenses:Household
+account Expenses:Fees
+account Income:Salary
+account Income:Dividends
+`)
+	coin.Load(r, "")
+	coin.ResolveAccounts()
+}
+
+const rulesSample = `392843029797099 Assets:Bank:Checking
+  Income:Salary       ACME PAY
+  Expenses:Groceries  FRESHCO
+    ; #groceries
+  Income:Dividends    Div
+`
+
+const sample = `!Type:Bank
+D01/15'20
+T-45.67
+PFRESHCO #123
+MMilk and bread
+^
+D1/16/2020
+T-100.00
+N101
+PCOSTCO
+LGroceries
+^
+D01/17/20
+T-80.00
+PCANADIAN TIRE
+SGroceries
+ECandy
+$-20.00
+SHousehold/Home
+$-60.00
+^
+D01/20/2020
+T1,500.00
+PACME PAY
+^
+D01/21/2020
+T-10.00
+PSOMEWHERE
+LMisc Spending
+^
+!Type:Cat
+NGroceries
+^
+!Account
+NAssets:Broker
+TInvst
+^
+!Type:Security
+NVanguard S&P 500
+SVFV
+TETF
+^
+!Type:Invst
+D01/22/2020
+NBuy
+YVanguard S&P 500
+I100.00
+Q10
+T1,009.99
+O9.99
+^
+D01/23/2020
+NDiv
+YVanguard S&P 500
+T12.34
+^
+D01/24/2020
+NStkSplit
+YVanguard S&P 500
+Q2
+^
+`
+
+func Test_Read(t *testing.T) {
+	rules, err := coin.ReadRules(strings.NewReader(rulesSample))
+	assert.NoError(t, err)
+	var log bytes.Buffer
+	imp := &Importer{Rules: rules, Account: "392843029797099", Log: &log}
+	assert.True(t, imp.Accepts("export.txt", []byte(sample)))
+	s, err := imp.Read(strings.NewReader(sample))
+	assert.NoError(t, err)
+	transactions := s.Classify(importer.IdTag(imp))
+	assert.EqualStrings(t, strings.Split(strings.TrimSpace(log.String()), "\n"),
+		"line 31: ignoring !Type:Cat section",
+		"line 57: skipping unsupported investment action StkSplit",
+	)
+	var b bytes.Buffer
+	for _, t := range transactions {
+		t.Write(&b, false)
+		b.WriteString("\n")
+	}
+	assert.Equal(t, b.String(), `2020/01/15 FRESHCO #123 ; #groceries
+  ; Milk and bread
+  Expenses:Groceries     45.67 CAD
+  Assets:Bank:Checking  -45.67 CAD
+
+2020/01/16 (101) COSTCO
+  Expenses:Groceries     100.00 CAD
+  Assets:Bank:Checking  -100.00 CAD
+
+2020/01/17 CANADIAN TIRE
+  Assets:Bank:Checking  -80.00 CAD
+  Expenses:Groceries     20.00 CAD ; Candy
+  Expenses:Household     60.00 CAD
+
+2020/01/20 ACME PAY
+  Assets:Bank:Checking   1500.00 CAD
+  Income:Salary         -1500.00 CAD
+
+2020/01/21 SOMEWHERE ; category: Misc Spending
+  Unbalanced             10.00 CAD
+  Assets:Bank:Checking  -10.00 CAD
+
+2020/01/22 Buy Vanguard S&P 500
+  Assets:Broker:VFV    10.000 VFV
+  Assets:Broker      -1009.99 CAD
+
+2020/01/23 Div Vanguard S&P 500
+  Assets:Broker   12.34 CAD
+  Unbalanced     -12.34 CAD
+
+`)
+}
+
+func Test_UnknownAccount(t *testing.T) {
+	_, err := (&Importer{Rules: &coin.RuleIndex{}}).Read(strings.NewReader(sample))
+	assert.Equal(t, err.Error(), "line 2: unknown account, the account id must be specified")
+}
+
+func Test_ParseDate(t *testing.T) {
+	q := newQIF("", false, nil)
+	for _, fix := range []struct {
+		in, out string
+		dmy     bool
+	}{
+		{"01/15/2020", "2020/01/15", false},
+		{"1/ 5'05", "2005/01/05", false},
+		{"12/31/99", "1999/12/31", false},
+		{"2020-01-15", "2020/01/15", false},
+		{"15/01/2020", "2020/01/15", true},
+		{"15.01.20", "2020/01/15", true},
+	} {
+		q.dmy = fix.dmy
+		d, err := q.parseDate(fix.in)
+		assert.NoError(t, err)
+		assert.Equal(t, d.Format(coin.DateFormat), fix.out, fix.in)
+		assert.Equal(t, d.Hour(), 12)
+	}
+	_, err := q.parseDate("13/15/2020")
+	assert.True(t, err != nil)
+}
@@ -49,6 +49,8 @@ func (p *Parser) Next(fn string) (Item, error) {
 		return p.parseCommodity(fn)
 	case bytes.HasPrefix(line, []byte("test ")):
 		return p.parseTest(fn)
+	case bytes.HasPrefix(line, []byte("rule ")):
+		return p.parseRuleGroup(fn)
 	case bytes.HasPrefix(line, []byte("P ")):
 		return p.parsePrice(fn)
 	case '0' <= line[0] && line[0] <= '9':
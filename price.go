@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"math/big"
 	"strings"
 	"time"
 
@@ -66,6 +67,12 @@ func (p *Parser) parsePrice(fn string) (*Price, error) {
 	}, nil
 }
 
+// apply converts the amount of p.Commodity into p.Currency.
+func (p *Price) apply(amount *Amount) *Amount {
+	num := new(big.Int).Mul(amount.Int, p.Value.Int)
+	return NewAmountFrac(num, bigPow10(amount.Decimals+p.Value.Decimals), p.Currency)
+}
+
 func (p *Price) String() string {
 	var b strings.Builder
 	p.Write(&b, false)
@@ -0,0 +1,204 @@
+// Package redact masks sensitive information (card, account and phone numbers)
+// in the descriptions and notes of imported transactions, so that it doesn't end up in the ledger.
+package redact
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/mkobetic/coin"
+)
+
+// Redactor masks the matches of its rules.
+// Tag values (e.g. #ofxid: 20190113162900) are never masked.
+// A nil Redactor doesn't mask anything.
+type Redactor struct {
+	rules []*rule
+}
+
+type rule struct {
+	kind  string
+	re    *regexp.Regexp
+	valid func(digits string) bool // optional check of the digits of the match
+	keep  int                      // number of trailing digits left unmasked
+}
+
+// Kinds are the built-in rule kinds, in the order they are applied.
+var Kinds = []string{"card", "phone", "account"}
+
+var builtin = map[string]*rule{
+	// 13-19 digits passing the Luhn check, optionally grouped by spaces or dashes
+	"card": {
+		kind:  "card",
+		re:    regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`),
+		valid: luhn,
+		keep:  4,
+	},
+	// North American phone numbers, e.g. 416-555-1234, (416) 555 1234, +1 416.555.1234
+	"phone": {
+		kind: "phone",
+		re:   regexp.MustCompile(`(?:\+\d{1,3}[ .-]?)?(?:\(\d{3}\) ?|\b\d{3}[ .-]?)\d{3}[ .-]?\d{4}\b`),
+	},
+	// 7-17 digits in groups of at least 3, e.g. 12345-678-9012345
+	"account": {
+		kind:  "account",
+		re:    regexp.MustCompile(`\b\d{3,}(?:[ -]\d{3,})*\b`),
+		valid: func(digits string) bool { return 7 <= len(digits) && len(digits) <= 17 },
+		keep:  4,
+	},
+}
+
+// New returns a redactor with the rules described by the specs,
+// which are either a built-in kind (card, phone or account) or a custom regex prefixed with "pattern ",
+// e.g. `pattern SIN \d{3} ?\d{3} ?\d{3}`. The rules are applied in the order of the specs,
+// masked text doesn't match the following rules.
+func New(specs ...string) (*Redactor, error) {
+	r := &Redactor{}
+	for _, spec := range specs {
+		if rl := builtin[spec]; rl != nil {
+			r.rules = append(r.rules, rl)
+			continue
+		}
+		ex := strings.TrimPrefix(spec, "pattern ")
+		if ex == spec {
+			return nil, fmt.Errorf("invalid redact rule: %s", spec)
+		}
+		re, err := regexp.Compile(strings.TrimSpace(ex))
+		if err != nil {
+			return nil, fmt.Errorf("invalid redact pattern %s: %w", ex, err)
+		}
+		r.rules = append(r.rules, &rule{kind: "pattern", re: re})
+	}
+	return r, nil
+}
+
+// Builtin returns a redactor with all the built-in rules.
+func Builtin() *Redactor {
+	r, _ := New(Kinds...)
+	return r
+}
+
+// String returns s with the matches masked, i.e. letters and digits replaced with X,
+// except the trailing digits of card and account numbers.
+func (r *Redactor) String(s string) string {
+	if r == nil {
+		return s
+	}
+	for _, rl := range r.rules {
+		out := []byte(s)
+		for _, idx := range r.find(rl, s) {
+			mask(out[idx[0]:idx[1]], rl.keep)
+		}
+		s = string(out)
+	}
+	return s
+}
+
+// Transaction masks the description and the notes of the transaction and its postings.
+func (r *Redactor) Transaction(t *coin.Transaction) {
+	if r == nil {
+		return
+	}
+	t.Description = r.String(t.Description)
+	for i, n := range t.Notes {
+		t.Notes[i] = r.String(n)
+	}
+	for _, p := range t.Postings {
+		for i, n := range p.Notes {
+			p.Notes[i] = r.String(n)
+		}
+	}
+}
+
+// Match is an unredacted match of a rule.
+type Match struct {
+	Kind string // card, phone, account or pattern
+	Text string
+}
+
+// Find returns the matches of the rules in s, masked text doesn't match.
+func (r *Redactor) Find(s string) (matches []Match) {
+	if r == nil {
+		return nil
+	}
+	for _, rl := range r.rules {
+		// mask the matches so that they don't match the following rules as well
+		out := []byte(s)
+		for _, idx := range r.find(rl, s) {
+			matches = append(matches, Match{Kind: rl.kind, Text: s[idx[0]:idx[1]]})
+			mask(out[idx[0]:idx[1]], 0)
+		}
+		s = string(out)
+	}
+	return matches
+}
+
+// find returns the indexes of the valid matches of the rule outside of tags,
+// ignoring matches that are only a part of a longer number, e.g. a phone number in an account number.
+func (r *Redactor) find(rl *rule, s string) (idxs [][]int) {
+	tags := coin.TagIndexes(s)
+next:
+	for _, idx := range rl.re.FindAllStringIndex(s, -1) {
+		if partOfNumber(s, idx[0]-2, idx[0]-1) || partOfNumber(s, idx[1]+1, idx[1]) {
+			continue
+		}
+		for _, t := range tags {
+			if idx[0] < t[1] && t[0] < idx[1] {
+				continue next
+			}
+		}
+		if rl.valid != nil && !rl.valid(digits(s[idx[0]:idx[1]])) {
+			continue
+		}
+		idxs = append(idxs, idx)
+	}
+	return idxs
+}
+
+// partOfNumber returns true if s has a digit at index i connected by a dash at index sep.
+func partOfNumber(s string, i, sep int) bool {
+	return 0 <= i && i < len(s) && s[sep] == '-' && '0' <= s[i] && s[i] <= '9'
+}
+
+// mask replaces letters and digits with X, except the last keep digits.
+func mask(b []byte, keep int) {
+	for i := len(b) - 1; i >= 0; i-- {
+		c := b[i]
+		isDigit := '0' <= c && c <= '9'
+		if isDigit && keep > 0 {
+			keep--
+			continue
+		}
+		if isDigit || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' {
+			b[i] = 'X'
+		}
+	}
+}
+
+func digits(s string) string {
+	var b strings.Builder
+	for _, c := range s {
+		if '0' <= c && c <= '9' {
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+// luhn returns true if the digits pass the Luhn checksum used by payment card numbers.
+func luhn(digits string) bool {
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
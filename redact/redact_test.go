@@ -0,0 +1,49 @@
+package redact
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/mkobetic/coin/assert"
+)
+
+func Test_String(t *testing.T) {
+	r, err := New(`pattern SIN \d{3} ?\d{3} ?\d{3}`, "card", "phone", "account")
+	assert.NoError(t, err)
+	for i, tt := range []struct {
+		in, out string
+	}{
+		{"PAYMENT 4111 1111 1111 1111 THANK YOU", "PAYMENT XXXX XXXX XXXX 1111 THANK YOU"},
+		{"PAYMENT 5500000000000004", "PAYMENT XXXXXXXXXXXX0004"},
+		{"REF 4111 1111 1111 1112", "REF XXXX XXXX XXXX 1112"}, // not a card, but still a long account number
+		{"ROGERS 416-555-1234", "ROGERS XXX-XXX-XXXX"},
+		{"ROGERS (416) 555 1234", "ROGERS (XXX) XXX XXXX"},
+		{"ROGERS +1 416.555.1234", "ROGERS +X XXX.XXX.XXXX"},
+		{"TRANSFER TO 12345-678-9012345", "TRANSFER TO XXXXX-XXX-XXX2345"},
+		{"CRA SIN 123 456 789", "CRA XXX XXX XXX XXX"},
+		{"FRESHCO #123 2019-01-13", "FRESHCO #123 2019-01-13"},
+		{"STORE 123456", "STORE 123456"},
+		{"ROGERS #ofxid: 4111111111111111", "ROGERS #ofxid: 4111111111111111"},
+		{"PAYMENT XXXX1111", "PAYMENT XXXX1111"},
+	} {
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			assert.Equal(t, r.String(tt.in), tt.out)
+		})
+	}
+}
+
+func Test_Find(t *testing.T) {
+	matches := Builtin().Find("PAYMENT 4111 1111 1111 1111 CALL 416-555-1234 OR XXXX1111")
+	assert.Equal(t, len(matches), 2)
+	assert.Equal(t, matches[0], Match{Kind: "card", Text: "4111 1111 1111 1111"})
+	assert.Equal(t, matches[1], Match{Kind: "phone", Text: "416-555-1234"})
+}
+
+func Test_New(t *testing.T) {
+	var r *Redactor
+	assert.Equal(t, r.String("4111111111111111"), "4111111111111111")
+	_, err := New("cc")
+	assert.True(t, err != nil)
+	_, err = New("pattern (")
+	assert.True(t, err != nil)
+}
@@ -5,8 +5,11 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"math/big"
 	"regexp"
 	"sort"
+	"time"
+	"unicode"
 
 	"github.com/mkobetic/coin/check"
 )
@@ -15,27 +18,114 @@ import (
 
 type Rules interface {
 	RuleFor(payee string) *Rule
+	RuleForInput(in *RuleInput) *Rule
 	Name() string
 	Write(w io.Writer, max int) error
 }
 
+// RuleInput describes the imported transaction for rule matching.
+// Rule conditions on values that are not provided don't match.
+type RuleInput struct {
+	Payee   string
+	Amount  *big.Rat  // amount posted to the statement account, optional
+	Posted  time.Time // optional
+	Account *Account  // the statement account, optional
+}
+
 type RuleIndex struct {
 	Accounts   map[string]*AccountRules // Maps ID to a set of rules for that account
 	Sets       []*RuleSet
 	SetsByName map[string]*RuleSet
+	Redact     []string // lines of the redact section, e.g. card, phone, pattern SIN \d{9} (see redact package)
 }
 
+// AccountRulesFor returns the rules of the group with the acctId,
+// or of the group of the account with the import id (see Account.HasImportId).
 func (rs *RuleIndex) AccountRulesFor(acctId string) *AccountRules {
 	ars := rs.Accounts[acctId]
 	if ars != nil {
 		return ars
 	}
-	account := FindAccountOfxId(acctId)
+	account := FindAccountImportId(acctId)
 	check.If(account != nil, "could not find account for Acct ID %s", acctId)
+	if ars := rs.accountRules(account); ars != nil {
+		return ars
+	}
 	return &AccountRules{Account: account}
 }
 
+// accountRules returns the rules of the first group (by id) of the account or nil.
+func (rs *RuleIndex) accountRules(account *Account) *AccountRules {
+	var ids []string
+	for id, ars := range rs.Accounts {
+		if ars.Account == account {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	sort.Strings(ids)
+	return rs.Accounts[ids[0]]
+}
+
+// Merge returns a new index with the rules of both indexes.
+// The rules of the other index follow the rules of the same account,
+// the rule sets of the index take precedence over the other rule sets with the same name.
+func (rs *RuleIndex) Merge(other *RuleIndex) *RuleIndex {
+	merged := newRuleIndex()
+	for _, ri := range []*RuleIndex{rs, other} {
+		if ri == nil {
+			continue
+		}
+		merged.Redact = append(merged.Redact, ri.Redact...)
+		for _, set := range ri.Sets {
+			if merged.SetsByName[set.Name()] == nil {
+				merged.Sets = append(merged.Sets, set)
+				merged.SetsByName[set.Name()] = set
+			}
+		}
+	}
+	var own []*AccountRules
+	if rs != nil {
+		for id, ars := range rs.Accounts {
+			ars = &AccountRules{Account: ars.Account, Rules: append([]Rules(nil), ars.Rules...)}
+			merged.Accounts[id] = ars
+			own = append(own, ars)
+		}
+	}
+	if other != nil {
+		for id, ars := range other.Accounts {
+			found := false
+			for _, ars2 := range own {
+				if ars2.Account == ars.Account {
+					ars2.Rules = append(ars2.Rules, ars.Rules...)
+					if !found && merged.Accounts[id] == nil {
+						// keep the other id resolvable, e.g. a legacy import id
+						merged.Accounts[id] = ars2
+					}
+					found = true
+				}
+			}
+			if !found {
+				merged.Accounts[id] = &AccountRules{Account: ars.Account, Rules: append([]Rules(nil), ars.Rules...)}
+			}
+		}
+	}
+	return merged
+}
+
 func (rs *RuleIndex) Write(w io.Writer) error {
+	if len(rs.Redact) > 0 {
+		if _, err := fmt.Fprintln(w, "redact"); err != nil {
+			return err
+		}
+		for _, r := range rs.Redact {
+			if _, err := fmt.Fprintf(w, "  %s\n", r); err != nil {
+				return err
+			}
+		}
+	}
 	for _, r := range rs.Sets {
 		if _, err := fmt.Fprintf(w, "%s\n", r.Name()); err != nil {
 			return err
@@ -48,8 +138,17 @@ func (rs *RuleIndex) Write(w io.Writer) error {
 	ids := map[*AccountRules]string{}
 	var max int
 	for id, ars := range rs.Accounts {
+		// a merged group can be registered under several ids, write it once under the first one
+		if id2, ok := ids[ars]; ok {
+			if id < id2 {
+				ids[ars] = id
+			}
+			continue
+		}
 		ids[ars] = id
 		accounts = append(accounts, ars)
+	}
+	for _, id := range ids {
 		if len(id) > max {
 			max = len(id)
 		}
@@ -91,14 +190,67 @@ type AccountRules struct {
 }
 
 func (ars *AccountRules) RuleFor(payee string) *Rule {
+	return ars.RuleForInput(&RuleInput{Payee: payee, Account: ars.Account})
+}
+
+// RuleForInput returns the first rule matching the input, the input account defaults to the rules account.
+func (ars *AccountRules) RuleForInput(in *RuleInput) *Rule {
+	if in.Account == nil {
+		in2 := *in
+		in2.Account = ars.Account
+		in = &in2
+	}
 	for _, r := range ars.Rules {
-		if pr := r.RuleFor(payee); pr != nil {
+		if pr := r.RuleForInput(in); pr != nil {
 			return pr
 		}
 	}
 	return nil
 }
 
+// Matches returns all the rules matching the input in the order they are tried,
+// the first one is the one returned by RuleForInput, the others are shadowed by it.
+func (ars *AccountRules) Matches(in *RuleInput) (matches []*RuleMatch) {
+	if in.Account == nil {
+		in2 := *in
+		in2.Account = ars.Account
+		in = &in2
+	}
+	order := 0
+	ars.Walk(func(r *Rule, path []*RuleSet) {
+		order++
+		if r.RuleForInput(in) != nil {
+			matches = append(matches, &RuleMatch{Rule: r, Path: append([]*RuleSet(nil), path...), Order: order})
+		}
+	})
+	return matches
+}
+
+// Walk calls fn with every rule in the order they are tried by RuleForInput,
+// along with the rule set references leading to the rule (outermost first).
+// The path is reused between the calls.
+func (ars *AccountRules) Walk(fn func(r *Rule, path []*RuleSet)) {
+	walkRules(ars.Rules, nil, fn)
+}
+
+func walkRules(rules []Rules, path []*RuleSet, fn func(r *Rule, path []*RuleSet)) {
+	for _, r := range rules {
+		switch r := r.(type) {
+		case *Rule:
+			fn(r, path)
+		case *RuleSet:
+			walkRules(r.Rules, append(path, r), fn)
+		}
+	}
+}
+
+// RuleMatch is a rule matching a RuleInput.
+type RuleMatch struct {
+	Rule  *Rule
+	Path  []*RuleSet // the rule set references leading to the rule (outermost first)
+	Order int        // position of the rule in the order the rules are tried, starting at 1
+}
+
 type RuleSet struct {
 	name  string
 	Rules []Rules
@@ -113,44 +265,247 @@ func (rs *RuleSet) Write(w io.Writer, max int) error {
 	return err
 }
 
+// Walk calls fn with every rule of the set in the order they are tried (see AccountRules.Walk).
+func (rs *RuleSet) Walk(fn func(r *Rule, path []*RuleSet)) {
+	walkRules(rs.Rules, nil, fn)
+}
+
 func (rs *RuleSet) RuleFor(payee string) *Rule {
+	return rs.RuleForInput(&RuleInput{Payee: payee})
+}
+
+func (rs *RuleSet) RuleForInput(in *RuleInput) *Rule {
 	for _, r := range rs.Rules {
-		if pr := r.RuleFor(payee); pr != nil {
+		if pr := r.RuleForInput(in); pr != nil {
 			return pr
 		}
 	}
 	return nil
 }
 
-// If this rule matches the transaction description,
-// use Account as the other side of the transaction
+// If this rule matches the transaction description (and all the Conditions),
+// use Account as the other side of the transaction.
+// The Splits carve out parts of the transaction amount for other accounts,
+// Account gets the rest.
 type Rule struct {
-	Account *Account
+	Account *Account // nil drops the transaction
 	*regexp.Regexp
-	Notes []string
+	Notes      []string
+	Conditions []*Condition
+	Splits     []*Split
 }
 
 func (r *Rule) Name() string {
+	if r.Account == nil {
+		return "--"
+	}
 	return r.Account.FullName
 }
 
 func (r *Rule) Write(w io.Writer, max int) error {
-	_, err := fmt.Fprintf(w, "  %-*s %s\n", max, r.Name(), r.String())
-	return err
+	if _, err := fmt.Fprintf(w, "  %-*s %s\n", max, r.Name(), r.String()); err != nil {
+		return err
+	}
+	for _, c := range r.Conditions {
+		if _, err := fmt.Fprintf(w, "  ? %s\n", c); err != nil {
+			return err
+		}
+	}
+	for _, sp := range r.Splits {
+		if _, err := fmt.Fprintf(w, "  + %s\n", sp); err != nil {
+			return err
+		}
+	}
+	for _, n := range r.Notes {
+		if _, err := fmt.Fprintf(w, "  ; %s\n", n); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (r *Rule) RuleFor(payee string) *Rule {
-	if r.MatchString(payee) {
-		return r
+	return r.RuleForInput(&RuleInput{Payee: payee})
+}
+
+func (r *Rule) RuleForInput(in *RuleInput) *Rule {
+	if !r.MatchString(in.Payee) {
+		return nil
 	}
-	return nil
+	for _, c := range r.Conditions {
+		if !c.Match(in) {
+			return nil
+		}
+	}
+	return r
+}
+
+// Parts returns the counter postings of the amount posted to the statement account:
+// each split account gets its part of the negated amount (rounded to the commodity decimals)
+// and the rule account gets the rest (unless it's zero).
+func (r *Rule) Parts(amount *Amount) (accounts []*Account, amounts []*Amount) {
+	rest := amount.Negated()
+	for _, sp := range r.Splits {
+		part := sp.Of(amount.Negated())
+		rest.Sub(rest.Int, part.Int)
+		accounts = append(accounts, sp.Account)
+		amounts = append(amounts, part)
+	}
+	if rest.Sign() != 0 || len(accounts) == 0 {
+		accounts = append([]*Account{r.Account}, accounts...)
+		amounts = append([]*Amount{rest}, amounts...)
+	}
+	return accounts, amounts
+}
+
+// Condition restricts the rule to transactions with specific amount, day of month or statement account,
+// e.g. `amount < 0`, `amount -200..-50`, `day 1..5` or `account Assets:Bank:Checking`.
+type Condition struct {
+	Field    string   // amount, day or account
+	Op       string   // =, <, <=, >, >= or .. (inclusive range) for amount and day
+	Value    *big.Rat // the lower bound for ranges
+	To       *big.Rat // the upper bound for ranges
+	Account  *Account // the account or its parent for account
+	from, to string   // the values as written
+}
+
+var conditionRE = regexp.MustCompile(`^(amount|day)\s+(?:(=|<=|>=|<|>)\s*(-?\d+(?:\.\d+)?)|(-?\d+(?:\.\d+)?)\.\.(-?\d+(?:\.\d+)?)|(-?\d+(?:\.\d+)?))$|` +
+	`^(account)\s+(\S+)$`)
+
+// ParseCondition parses a condition, e.g. `amount < 0`.
+func ParseCondition(s string) (*Condition, error) {
+	match := conditionRE.FindStringSubmatch(s)
+	if match == nil {
+		return nil, fmt.Errorf("invalid rule condition: %s", s)
+	}
+	if match[7] != "" {
+		account, err := FindAccount(match[8])
+		if err != nil {
+			return nil, fmt.Errorf("invalid rule condition: %s: %w", s, err)
+		}
+		return &Condition{Field: match[7], Account: account}, nil
+	}
+	c := &Condition{Field: match[1], Op: match[2], from: match[3]}
+	if match[4] != "" {
+		c.Op, c.from, c.to = "..", match[4], match[5]
+	} else if match[6] != "" {
+		c.Op, c.from = "=", match[6]
+	}
+	c.Value, _ = new(big.Rat).SetString(c.from)
+	if c.to != "" {
+		c.To, _ = new(big.Rat).SetString(c.to)
+	}
+	return c, nil
+}
+
+func (c *Condition) String() string {
+	switch {
+	case c.Field == "account":
+		return c.Field + " " + c.Account.FullName
+	case c.Op == "..":
+		return c.Field + " " + c.from + ".." + c.to
+	default:
+		return c.Field + " " + c.Op + " " + c.from
+	}
+}
+
+func (c *Condition) Match(in *RuleInput) bool {
+	var v *big.Rat
+	switch c.Field {
+	case "account":
+		for a := in.Account; a != nil; a = a.Parent {
+			if a == c.Account {
+				return true
+			}
+		}
+		return false
+	case "amount":
+		if in.Amount == nil {
+			return false
+		}
+		v = in.Amount
+	case "day":
+		if in.Posted.IsZero() {
+			return false
+		}
+		v = big.NewRat(int64(in.Posted.Day()), 1)
+	}
+	cmp := v.Cmp(c.Value)
+	switch c.Op {
+	case "=":
+		return cmp == 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	default: // ..
+		return cmp >= 0 && v.Cmp(c.To) <= 0
+	}
+}
+
+// Split posts a part of the transaction amount to Account,
+// either a percentage, e.g. `Expenses:Household 30%`, or a fixed amount, e.g. `Expenses:Deposit 5.00`.
+type Split struct {
+	Account *Account
+	Value   *big.Rat // absolute value
+	Percent bool
+	value   string // the value as written
+}
+
+var splitRE = regexp.MustCompile(`^` + patternRE + `\s+(\d+(?:\.\d+)?)(%?)$`)
+
+// ParseSplit parses a split, e.g. `Expenses:Household 30%`.
+func ParseSplit(s string) (*Split, error) {
+	match := splitRE.FindStringSubmatch(s)
+	if match == nil {
+		return nil, fmt.Errorf("invalid rule split: %s", s)
+	}
+	account, err := FindAccount(match[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid rule split: %s: %w", s, err)
+	}
+	sp := &Split{Account: account, Percent: match[3] == "%", value: match[2]}
+	sp.Value, _ = new(big.Rat).SetString(sp.value)
+	return sp, nil
+}
+
+func (sp *Split) String() string {
+	if sp.Percent {
+		return sp.Account.FullName + " " + sp.value + "%"
+	}
+	return sp.Account.FullName + " " + sp.value
+}
+
+// Of returns the part of the amount for the split account, with the same sign as the amount.
+func (sp *Split) Of(amount *Amount) *Amount {
+	v := new(big.Rat).SetInt(amount.Magnitude())
+	if sp.Percent {
+		v.Mul(v, sp.Value)
+		v.Quo(v, big.NewRat(100, 1))
+	} else {
+		v.Mul(sp.Value, new(big.Rat).SetInt(bigPow10(amount.Decimals)))
+	}
+	// round half up
+	v.Add(v, big.NewRat(1, 2))
+	part := new(big.Int).Quo(v.Num(), v.Denom())
+	if amount.Sign() < 0 {
+		part.Neg(part)
+	}
+	return NewAmount(part, amount.Commodity)
 }
 
 var patternRE = `([\w:$^\\-]+)`
 var headerRE = regexp.MustCompile(`^(\w+)\s+` + patternRE + `|^(\w+)`)
 var bodyRE = regexp.MustCompile(`^\s+` + patternRE + `(\s+(\S.*\S))?|` +
 	`^\s+@(\w+)|` +
-	`^\s+;\s+(.*)`)
+	`^\s+;\s+(.*)|` +
+	`^\s+\?\s+(\S.*\S)|` +
+	`^\s+\+\s+(\S.*\S)`)
+var redactRE = regexp.MustCompile(`^\s+(\S.*\S|\S)\s*$`)
 
 func ReadRules(r io.Reader) (*RuleIndex, error) {
 	s := bufio.NewScanner(r)
@@ -160,58 +515,57 @@ func ReadRules(r io.Reader) (*RuleIndex, error) {
 	return ScanRules(s.Bytes(), s)
 }
 
-func ScanRules(line []byte, s *bufio.Scanner) (*RuleIndex, error) {
-	ri := &RuleIndex{
+func newRuleIndex() *RuleIndex {
+	return &RuleIndex{
 		Accounts:   make(map[string]*AccountRules),
 		SetsByName: make(map[string]*RuleSet),
 	}
+}
+
+func ScanRules(line []byte, s *bufio.Scanner) (*RuleIndex, error) {
+	ri := newRuleIndex()
 	for {
 		match := headerRE.FindSubmatch(line)
-		if match != nil {
-			var setRules func(rules []Rules)
-			if len(match[1]) > 0 {
-				ar := &AccountRules{Account: MustFindAccount(string(match[2]))}
-				ri.Accounts[string(match[1])] = ar
-				setRules = func(rules []Rules) { ar.Rules = rules }
-			} else {
-				rs := &RuleSet{name: string(match[3])}
-				ri.Sets = append(ri.Sets, rs)
-				ri.SetsByName[rs.Name()] = rs
-				setRules = func(rules []Rules) { rs.Rules = rules }
-			}
-			var rules []Rules
-			var lastRule *Rule
+		if match != nil && string(match[3]) == "redact" {
+			// the redact section lists what to mask in the imported descriptions and notes
 			for {
 				if !s.Scan() {
-					setRules(rules)
 					return ri, s.Err()
 				}
 				line = s.Bytes()
-				match = bodyRE.FindSubmatch(line)
+				match = redactRE.FindSubmatch(line)
 				if match == nil {
 					break
 				}
-				if len(match[1]) > 0 {
-					var account *Account
-					if string(match[1]) != "--" {
-						account = MustFindAccount(string(match[1]))
-					}
-					lastRule = &Rule{
-						Account: account,
-						Regexp:  regexp.MustCompile(string(match[3]))}
-					rules = append(rules, lastRule)
-				} else if len(match[4]) > 0 {
-					r := ri.SetsByName[string(match[4])]
-					if r == nil {
-						panic(fmt.Errorf("invalid rule set ref: %s", string(match[4])))
+				if !bytes.HasPrefix(match[1], []byte(";")) {
+					ri.Redact = append(ri.Redact, string(match[1]))
+				}
+			}
+		} else if match != nil {
+			rules, next, err := ri.scanRules(s)
+			if err != nil {
+				return nil, err
+			}
+			if len(match[1]) > 0 {
+				// repeated groups of the same account id extend the first one,
+				// e.g. rules appended by coin categorize
+				ar := ri.Accounts[string(match[1])]
+				if ar == nil {
+					account, err := FindAccount(string(match[2]))
+					if err != nil {
+						return nil, fmt.Errorf("invalid rule group: %s: %w", line, err)
 					}
-					rules = append(rules, r)
-					lastRule = nil
-				} else if lastRule != nil {
-					lastRule.Notes = append(lastRule.Notes, string(match[5]))
+					ar = &AccountRules{Account: account}
+					ri.Accounts[string(match[1])] = ar
 				}
+				ar.Rules = append(ar.Rules, rules...)
+			} else {
+				ri.addSet(string(match[3]), rules)
 			}
-			setRules(rules)
+			if next == nil {
+				return ri, nil
+			}
+			line = next
 		} else {
 			if !s.Scan() {
 				return ri, s.Err()
@@ -221,6 +575,147 @@ func ScanRules(line []byte, s *bufio.Scanner) (*RuleIndex, error) {
 	}
 }
 
+func (ri *RuleIndex) addSet(name string, rules []Rules) {
+	rs := &RuleSet{name: name, Rules: rules}
+	ri.Sets = append(ri.Sets, rs)
+	ri.SetsByName[rs.Name()] = rs
+}
+
+// scanRules parses the rule lines of a group up to the first line that isn't a rule line,
+// which is returned as next (nil at the end of the input).
+func (ri *RuleIndex) scanRules(s *bufio.Scanner) (rules []Rules, next []byte, err error) {
+	var lastRule *Rule
+	for s.Scan() {
+		line := s.Bytes()
+		match := bodyRE.FindSubmatch(line)
+		if match == nil {
+			return rules, line, nil
+		}
+		if len(match[1]) > 0 {
+			var account *Account
+			if string(match[1]) != "--" {
+				if account, err = FindAccount(string(match[1])); err != nil {
+					return nil, nil, fmt.Errorf("invalid rule: %s: %w", line, err)
+				}
+			}
+			lastRule = &Rule{
+				Account: account,
+				Regexp:  regexp.MustCompile(string(match[3]))}
+			rules = append(rules, lastRule)
+		} else if len(match[4]) > 0 {
+			r := ri.SetsByName[string(match[4])]
+			if r == nil {
+				panic(fmt.Errorf("invalid rule set ref: %s", string(match[4])))
+			}
+			rules = append(rules, r)
+			lastRule = nil
+		} else if len(match[6]) > 0 {
+			if lastRule == nil {
+				return nil, nil, fmt.Errorf("condition without a rule: %s", line)
+			}
+			c, err := ParseCondition(string(match[6]))
+			if err != nil {
+				return nil, nil, err
+			}
+			lastRule.Conditions = append(lastRule.Conditions, c)
+		} else if len(match[7]) > 0 {
+			if lastRule == nil {
+				return nil, nil, fmt.Errorf("split without a rule: %s", line)
+			}
+			sp, err := ParseSplit(string(match[7]))
+			if err != nil {
+				return nil, nil, err
+			}
+			lastRule.Splits = append(lastRule.Splits, sp)
+		} else if lastRule != nil {
+			lastRule.Notes = append(lastRule.Notes, string(match[5]))
+		}
+	}
+	return rules, nil, s.Err()
+}
+
+// RuleGroups are the rule groups loaded from coin files.
+var RuleGroups []*RuleGroup
+
+// ImportRules are the rules of the loaded rule groups (see ResolveRules).
+var ImportRules *RuleIndex
+
+// RuleGroup is a group of import rules in a coin file,
+// either a rule set (`rule @name`) or the rules of an account (`rule Assets:Bank:Checking`).
+// The rule lines are the same as in the rules files (see ofx2coin README),
+// they are parsed by ResolveRules, once all the accounts are loaded.
+//
+//	rule @groceries
+//	  Expenses:Groceries  FRESHCO|COSTCO WHOLESALE
+//	rule Liabilities:Credit:MC
+//	  @groceries
+//	  Expenses:Auto       HUYNDAI|TOYOTA
+type RuleGroup struct {
+	SetName     string // name of the rule set
+	AccountName string // account of the rules (if not a rule set)
+
+	lines []byte
+
+	line uint
+	file string
+}
+
+var ruleHeadRE = regexp.MustCompile(`^rule\s+(?:@(\w+)|(\S+))\s*$`)
+
+func (p *Parser) parseRuleGroup(fn string) (*RuleGroup, error) {
+	match := ruleHeadRE.FindSubmatch(p.Bytes())
+	if match == nil {
+		return nil, fmt.Errorf("invalid rule group: %s", p.Text())
+	}
+	g := &RuleGroup{SetName: string(match[1]), AccountName: string(match[2]), line: p.lineNr, file: fn}
+	for p.Scan() {
+		line := p.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 || !unicode.IsSpace(rune(line[0])) {
+			return g, nil
+		}
+		g.lines = append(g.lines, line...)
+		g.lines = append(g.lines, '\n')
+	}
+	return g, p.Err()
+}
+
+func (g *RuleGroup) Location() string {
+	return fmt.Sprintf("%s:%d", g.file, g.line)
+}
+
+// ResolveRules parses the loaded rule groups into ImportRules, the rule sets first.
+// The account groups are indexed by the first import id of the account, or the account name if it has none.
+func ResolveRules() {
+	ri := newRuleIndex()
+	for _, sets := range []bool{true, false} {
+		for _, g := range RuleGroups {
+			if (g.SetName != "") != sets {
+				continue
+			}
+			s := bufio.NewScanner(bytes.NewReader(g.lines))
+			rules, next, err := ri.scanRules(s)
+			check.NoError(err, "invalid rule group %s", g.Location())
+			check.If(next == nil, "invalid rule line %s: %s\n", g.Location(), next)
+			if sets {
+				ri.addSet(g.SetName, rules)
+				continue
+			}
+			account := MustFindAccount(g.AccountName)
+			id := account.ImportId()
+			if id == "" {
+				id = account.FullName
+			}
+			ars := ri.Accounts[id]
+			if ars == nil {
+				ars = &AccountRules{Account: account}
+				ri.Accounts[id] = ars
+			}
+			ars.Rules = append(ars.Rules, rules...)
+		}
+	}
+	ImportRules = ri
+}
+
 func stringify(m [][]byte) (o []string) {
 	for _, b := range m {
 		o = append(o, string(b))
@@ -1,8 +1,10 @@
 package coin
 
 import (
+	"math/big"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/mkobetic/coin/assert"
 )
@@ -24,9 +26,21 @@ account Expenses:Miscellaneous
 account Income:Salary
 account Income:Interest
 account Liabilities:Credit:MC
+account Assets:Bank:Cash
+  import-id 555
+  import-id CASH
+
+rule @fees
+  Expenses:Miscellaneous  FEE|CHARGE
+
+rule Assets:Bank:Cash
+  @fees
+  Income:Interest         INTEREST
+  ? amount > 0
 `)
 	Load(r, "")
 	ResolveAccounts()
+	ResolveRules()
 }
 
 var sample = `
@@ -79,3 +93,194 @@ func Test_Classification(t *testing.T) {
 		}
 	}
 }
+
+func Test_ReadRulesRedact(t *testing.T) {
+	r := strings.NewReader(`redact
+  card
+  ; mask the social insurance numbers too
+  pattern SIN \d{9}
+` + sample)
+	rules, err := ReadRules(r)
+	assert.NoError(t, err)
+	assert.EqualStrings(t, rules.Redact, "card", `pattern SIN \d{9}`)
+	assert.Equal(t, len(rules.Accounts), 3)
+	assert.Equal(t, len(rules.Sets), 1)
+	var b strings.Builder
+	assert.NoError(t, rules.Write(&b))
+	assert.True(t, strings.HasPrefix(b.String(), "redact\n  card\n  pattern SIN \\d{9}\ncommon\n"))
+}
+
+var sampleConditions = `479347938749398 Liabilities:Credit:MC
+  Expenses:Groceries COSTCO WHOLESALE
+  ? amount -200..-50
+  + Expenses:Miscellaneous 30%
+  + Expenses:Auto 5.00
+  ; costco split
+  Expenses:Groceries COSTCO
+  ? amount < 0
+  ? day 1..15
+  ? account Liabilities:Credit
+  --                 COSTCO
+`
+
+func Test_ReadRulesConditions(t *testing.T) {
+	rules, err := ReadRules(strings.NewReader(sampleConditions))
+	assert.NoError(t, err)
+	mc := rules.Accounts["479347938749398"]
+	r1 := mc.Rules[0].(*Rule)
+	assert.Equal(t, len(r1.Conditions), 1)
+	assert.Equal(t, len(r1.Splits), 2)
+	assert.EqualStrings(t, r1.Notes, "costco split")
+	var b strings.Builder
+	assert.NoError(t, rules.Write(&b))
+	assert.Equal(t, b.String(), sampleConditions)
+
+	_, err = ReadRules(strings.NewReader("common\n  ? amount < 0\n"))
+	assert.True(t, err != nil)
+	_, err = ReadRules(strings.NewReader("common\n  Expenses:Auto  TOYOTA\n  ? amount ~ 0\n"))
+	assert.True(t, err != nil)
+	_, err = ReadRules(strings.NewReader("common\n  Expenses:Auto  TOYOTA\n  ? account Assets:Unknown\n"))
+	assert.Equal(t, err.Error(), "invalid rule condition: account Assets:Unknown: cannot find account Assets:Unknown")
+	_, err = ReadRules(strings.NewReader("common\n  Expenses:Auto  TOYOTA\n  + Expenses:Unknown 30%\n"))
+	assert.Equal(t, err.Error(), "invalid rule split: Expenses:Unknown 30%: cannot find account Expenses:Unknown")
+}
+
+func Test_RuleForInput(t *testing.T) {
+	rules, err := ReadRules(strings.NewReader(sampleConditions))
+	assert.NoError(t, err)
+	mc := rules.Accounts["479347938749398"]
+	day := func(d int) time.Time { return time.Date(2020, 1, d, 12, 0, 0, 0, time.UTC) }
+	for i, fix := range []struct {
+		payee  string
+		amount *big.Rat
+		posted time.Time
+		rule   int // index of the matching rule, -1 for none
+	}{
+		{"COSTCO WHOLESALE #9239", big.NewRat(-100, 1), day(20), 0},
+		{"COSTCO WHOLESALE #9239", big.NewRat(-30, 1), day(10), 1},
+		{"COSTCO WHOLESALE #9239", big.NewRat(-30, 1), day(20), 2},
+		{"COSTCO WHOLESALE #9239", big.NewRat(30, 1), day(10), 2},
+		{"COSTCO WHOLESALE #9239", nil, time.Time{}, 2},
+		{"FRESHCO", big.NewRat(-30, 1), day(10), -1},
+	} {
+		rule := mc.RuleForInput(&RuleInput{Payee: fix.payee, Amount: fix.amount, Posted: fix.posted})
+		if fix.rule < 0 {
+			assert.True(t, rule == nil, i)
+		} else {
+			assert.True(t, rule == mc.Rules[fix.rule], i)
+		}
+	}
+	// the account condition doesn't match other accounts
+	rule := mc.RuleForInput(&RuleInput{
+		Payee:   "COSTCO",
+		Amount:  big.NewRat(-30, 1),
+		Posted:  day(10),
+		Account: MustFindAccount("Assets:Bank:Checking"),
+	})
+	assert.True(t, rule == mc.Rules[2])
+}
+
+func Test_RuleParts(t *testing.T) {
+	rules, err := ReadRules(strings.NewReader(sampleConditions))
+	assert.NoError(t, err)
+	rule := rules.Accounts["479347938749398"].Rules[0].(*Rule)
+	accounts, amounts := rule.Parts(MustParseAmount("-100.01", Commodities["CAD"]))
+	assert.Equal(t, len(accounts), 3)
+	assert.Equal(t, accounts[0].FullName, "Expenses:Groceries")
+	assert.Equal(t, amounts[0].String(), "65.01")
+	assert.Equal(t, accounts[1].FullName, "Expenses:Miscellaneous")
+	assert.Equal(t, amounts[1].String(), "30.00")
+	assert.Equal(t, accounts[2].FullName, "Expenses:Auto")
+	assert.Equal(t, amounts[2].String(), "5.00")
+
+	tx := &Transaction{Description: "COSTCO WHOLESALE"}
+	tx.PostSplit(MustFindAccount("Liabilities:Credit:MC"), MustParseAmount("-100.01", Commodities["CAD"]), nil, accounts, amounts)
+	assert.Equal(t, tx.String(), `0001/01/01 COSTCO WHOLESALE
+  Expenses:Groceries        65.01 CAD
+  Expenses:Miscellaneous    30.00 CAD
+  Expenses:Auto              5.00 CAD
+  Liabilities:Credit:MC   -100.01 CAD
+`)
+}
+
+func Test_Matches(t *testing.T) {
+	rules, err := ReadRules(strings.NewReader(sample))
+	assert.NoError(t, err)
+	mc := rules.Accounts["479347938749398"]
+	var names []string
+	mc.Walk(func(r *Rule, path []*RuleSet) {
+		names = append(names, r.Name())
+		if r.Name() == "Expenses:Groceries" {
+			assert.Equal(t, len(path), 1)
+			assert.Equal(t, path[0].Name(), "common")
+		}
+	})
+	assert.EqualStrings(t, names, "Expenses:Auto", "Expenses:Groceries", "Expenses:Auto:Gas", "Expenses:Miscellaneous")
+
+	matches := mc.Matches(&RuleInput{Payee: "COSTCO GAS"})
+	assert.Equal(t, len(matches), 2)
+	assert.Equal(t, matches[0].Rule.Name(), "Expenses:Auto:Gas")
+	assert.Equal(t, matches[0].Order, 3)
+	assert.Equal(t, matches[0].Path[0].Name(), "common")
+	assert.True(t, matches[0].Rule == mc.RuleFor("COSTCO GAS"))
+	assert.Equal(t, matches[1].Rule.Name(), "Expenses:Miscellaneous")
+	assert.Equal(t, matches[1].Order, 4)
+	assert.Equal(t, len(matches[1].Path), 0)
+}
+
+func Test_ResolveRules(t *testing.T) {
+	cash := MustFindAccount("Assets:Bank:Cash")
+	assert.EqualStrings(t, cash.ImportIds, "555", "CASH")
+	assert.Equal(t, cash.ImportId(), "555")
+	assert.True(t, FindAccountImportId("CASH") == cash)
+	var b strings.Builder
+	assert.NoError(t, cash.Write(&b, false))
+	assert.Equal(t, b.String(), `account Assets:Bank:Cash
+  commodity CAD
+  import-id 555
+  import-id CASH
+`)
+
+	ars := ImportRules.AccountRulesFor("CASH")
+	assert.True(t, ars == ImportRules.AccountRulesFor("555"))
+	assert.Equal(t, ars.RuleFor("MONTHLY FEE").Account.FullName, "Expenses:Miscellaneous")
+	rule := ars.RuleForInput(&RuleInput{Payee: "INTEREST", Amount: big.NewRat(1, 1)})
+	assert.Equal(t, rule.Account.FullName, "Income:Interest")
+	assert.True(t, ars.RuleFor("INTEREST") == nil)
+
+	// rules files are merged after the coin file rules
+	rules, err := ReadRules(strings.NewReader(`
+555 Assets:Bank:Cash
+  Expenses:Groceries  FRESHCO
+`))
+	assert.NoError(t, err)
+	merged := ImportRules.Merge(rules)
+	ars = merged.AccountRulesFor("CASH")
+	assert.Equal(t, ars.RuleFor("FRESHCO FEE").Account.FullName, "Expenses:Miscellaneous")
+	assert.Equal(t, ars.RuleFor("FRESHCO").Account.FullName, "Expenses:Groceries")
+	assert.True(t, ImportRules.AccountRulesFor("CASH").RuleFor("FRESHCO") == nil)
+	b.Reset()
+	assert.NoError(t, merged.Write(&b))
+	assert.Equal(t, b.String(), `fees
+  Expenses:Miscellaneous FEE|CHARGE
+555 Assets:Bank:Cash
+  @fees              
+  Income:Interest    INTEREST
+  ? amount > 0
+  Expenses:Groceries FRESHCO
+`)
+
+	// the other ids of merged groups stay resolvable, e.g. a legacy account number
+	rules, err = ReadRules(strings.NewReader(`
+987654 Assets:Bank:Cash
+  Expenses:Groceries  FRESHCO
+`))
+	assert.NoError(t, err)
+	merged = ImportRules.Merge(rules)
+	ars = merged.AccountRulesFor("987654")
+	assert.True(t, ars == merged.AccountRulesFor("CASH"))
+	assert.Equal(t, ars.RuleFor("FRESHCO").Account.FullName, "Expenses:Groceries")
+	b.Reset()
+	assert.NoError(t, merged.Write(&b))
+	assert.Equal(t, strings.Count(b.String(), "Assets:Bank:Cash"), 1)
+}
@@ -111,3 +111,8 @@ func (m *TagMatcher) Match(tags Tags) bool {
 	}
 	return false
 }
+
+// TagIndexes returns the start/end index pairs of the tags in the line.
+func TagIndexes(line string) [][]int {
+	return tagREX.FindAllStringIndex(line, -1)
+}
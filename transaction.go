@@ -235,6 +235,38 @@ func (t *Transaction) PostConversion(
 	}
 }
 
+// PostSplit posts the amount from the account and the counter amounts to the counter accounts (see Rule.Parts).
+// As with Post, the negative side of the transaction is listed last.
+func (t *Transaction) PostSplit(
+	from *Account,
+	amount *Amount,
+	balance *Amount,
+	to []*Account,
+	amounts []*Amount,
+) {
+	if len(to) == 1 {
+		t.PostConversion(from, amount, balance, to[0], amounts[0], nil)
+		return
+	}
+	if amount.Sign() >= 0 {
+		t.AddPosting(from, amount, balance)
+	}
+	for i, a := range to {
+		t.AddPosting(a, amounts[i], nil)
+	}
+	if amount.Sign() < 0 {
+		t.AddPosting(from, amount, balance)
+	}
+}
+
+// AddPosting appends a posting to the transaction, e.g. for transactions split across multiple accounts.
+func (t *Transaction) AddPosting(account *Account, quantity *Amount, balance *Amount) *Posting {
+	s := &Posting{Account: account, Transaction: t, Quantity: quantity, Balance: balance, BalanceAsserted: balance != nil}
+	account.addPosting(s)
+	t.Postings = append(t.Postings, s)
+	return s
+}
+
 func (t *Transaction) Other(s *Posting) *Posting {
 	for _, ss := range t.Postings {
 		if ss != s {
@@ -244,6 +276,37 @@ func (t *Transaction) Other(s *Posting) *Posting {
 	return nil
 }
 
+// Values returns the values of the postings in commodity c.
+// The postings in other commodities are converted at the posted date,
+// the last of them takes the difference so that the values add up to zero.
+func (t *Transaction) Values(c *Commodity) ([]*Amount, error) {
+	values := make([]*Amount, len(t.Postings))
+	total := NewZeroAmount(c)
+	var others []int
+	for i, p := range t.Postings {
+		if p.Quantity.Commodity == c {
+			values[i] = p.Quantity
+			total.AddIn(p.Quantity) // same commodity, can't fail
+		} else {
+			others = append(others, i)
+		}
+	}
+	for k, i := range others {
+		if k == len(others)-1 {
+			values[i] = total.Negated()
+			break
+		}
+		p := t.Postings[i]
+		value, _, err := c.ConvertAt(p.Quantity, p.Quantity.Commodity, t.Posted)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", t.Location(), err)
+		}
+		values[i] = value
+		total.AddIn(value)
+	}
+	return values, nil
+}
+
 func (t *Transaction) HasBalanceAssertions() bool {
 	for _, p := range t.Postings {
 		if p.BalanceAsserted {
@@ -271,15 +334,58 @@ func (t *Transaction) IsEqual(t2 *Transaction) bool {
 	return true
 }
 
+// MergeDuplicate copies the balances missing in t from the matching postings of the duplicate t2.
 func (t *Transaction) MergeDuplicate(t2 *Transaction) {
 	for i, p := range t.Postings {
-		if p2 := t2.Postings[i]; p.Balance == nil && p2.Balance != nil {
+		if i >= len(t2.Postings) {
+			return
+		}
+		if p2 := t2.Postings[i]; p.Account == p2.Account && p.Balance == nil && p2.Balance != nil {
 			p.Balance = p2.Balance
 			p.BalanceAsserted = p2.BalanceAsserted
 		}
 	}
 }
 
+// Combine moves the postings and notes of t2 into t.
+// Postings of t2 with the same account and commodity as a posting of t are added to it,
+// the balance of the later posting wins, postings adding up to zero are removed.
+func (t *Transaction) Combine(t2 *Transaction) {
+	t.Notes = append(t.Notes, t2.Notes...)
+	for _, p2 := range t2.Postings {
+		var p *Posting
+		for _, s := range t.Postings {
+			if s.Account == p2.Account && s.Quantity.Commodity == p2.Quantity.Commodity {
+				p = s
+				break
+			}
+		}
+		if p == nil {
+			p2.Transaction = t
+			t.Postings = append(t.Postings, p2)
+			continue
+		}
+		p.Quantity = p.Quantity.Copy()
+		p.Quantity.AddIn(p2.Quantity) // same commodity, can't fail
+		if p2.Balance != nil {
+			p.Balance, p.BalanceAsserted = p2.Balance, p2.BalanceAsserted
+		}
+		p.Notes = append(p.Notes, p2.Notes...)
+		p.Tags = ParseTags(p.Notes...)
+		p2.drop()
+		if p.Quantity.IsZero() && p.Balance == nil {
+			p.drop()
+			for i, s := range t.Postings {
+				if s == p {
+					t.Postings = append(t.Postings[:i], t.Postings[i+1:]...)
+					break
+				}
+			}
+		}
+	}
+	t2.Postings = nil
+}
+
 func (t *Transaction) drop() {
 	for _, p := range t.Postings {
 		p.drop()
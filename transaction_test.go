@@ -151,3 +151,28 @@ func Test_TransactionsByTimeDay(t *testing.T) {
 		check(t, transactions[10].Posted.AddDate(0, 0, 10), 0)
 	})
 }
+
+func Test_TransactionCombine(t *testing.T) {
+	cad, usd := Commodities["CAD"], Commodities["USD"]
+	cash, fees, fx := &Account{Name: "Cash", FullName: "Cash"}, &Account{Name: "Fees", FullName: "Fees"}, &Account{Name: "FX", FullName: "FX"}
+	t1 := &Transaction{Description: "trade"}
+	t1.AddPosting(cash, MustParseAmount("-100.00", cad), nil)
+	t1.AddPosting(fx, MustParseAmount("100.00", cad), nil)
+	t2 := &Transaction{Description: "commission", Notes: []string{"ref 123"}}
+	t2.AddPosting(cash, MustParseAmount("-1.50", cad), MustParseAmount("898.50", cad))
+	t2.AddPosting(fees, MustParseAmount("1.50", cad), nil)
+	t3 := &Transaction{Description: "fx"}
+	t3.AddPosting(fx, MustParseAmount("-100.00", cad), nil)
+	t3.AddPosting(fx, MustParseAmount("75.00", usd), nil)
+	t1.Combine(t2)
+	t1.Combine(t3)
+	assert.Equal(t, len(t1.Postings), 3)
+	assert.Equal(t, fmt.Sprintf("%a", t1.Postings[0].Quantity), "-101.50")
+	assert.Equal(t, fmt.Sprintf("%a", t1.Postings[0].Balance), "898.50")
+	assert.Equal(t, t1.Postings[1].Account, fees)
+	assert.Equal(t, fmt.Sprintf("%a", t1.Postings[2].Quantity), "75.00")
+	assert.EqualStrings(t, t1.Notes, "ref 123")
+	assert.Equal(t, len(t2.Postings)+len(t3.Postings), 0)
+	assert.Equal(t, len(fx.Postings), 1)
+	assert.Equal(t, len(cash.Postings), 1)
+}